@@ -1,13 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"math/rand/v2"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,6 +36,45 @@ import (
 
 var nickNames = make(chan string, 1000)
 
+// defaultMaxCommittees is the default MaxCommittees validator param
+const defaultMaxCommittees = 15
+
+// defaultMaxCommitteeSize is the default MaxCommitteeSize validator param
+const defaultMaxCommitteeSize = 100
+
+const (
+	defaultUnstakingBlocks         = 2 // default UnstakingBlocks validator param
+	defaultDelegateUnstakingBlocks = 2 // default DelegateUnstakingBlocks validator param, the node's minimum
+)
+
+const (
+	defaultBlockSize           = 1000000 // default BlockSize consensus param
+	defaultMaxTotalBytes       = 1000000 // default mempool MaxTotalBytes
+	defaultIndividualMaxTxSize = 4000    // default mempool IndividualMaxTxSize
+)
+
+const (
+	defaultWriterBuffer = 1024      // jwriter streaming buffer size in bytes
+	largeWriterBuffer   = 64 * 1024 // jwriter streaming buffer size for chains with many entries
+	largeChainEntries   = 10000     // number of genesis entries above which the large buffer is used
+)
+
+const (
+	// passwordShared encrypts every keystore entry with the configured password
+	passwordShared = "shared"
+	// passwordPerNode encrypts each node's keystore entry with a password derived from the configured password and its
+	// keystore nickname (node-{id} or delegator-{id}), not from general.seed. passwords.json is keyed by the nicknames
+	// and main accounts keep the configured password
+	passwordPerNode = "perNode"
+)
+
+const (
+	// keyTypeBLS12381 creates BLS12-381 node keys, the default and the only type canopy accepts for validators
+	keyTypeBLS12381 = "bls12381"
+	// keyTypeEd25519 creates Ed25519 node keys, for delegators and full nodes that don't sign consensus messages
+	keyTypeEd25519 = "ed25519"
+)
+
 const (
 	validatorNick = "validator"
 	delegatorNick = "delegator"
@@ -29,13 +82,26 @@ const (
 	fullNodeNick  = "fullnode"
 )
 
+// keystoreTypes are the node types keystore.json can include, account being the main accounts
+var keystoreTypes = []string{validatorNick, delegatorNick, fullNodeNick, accountNick}
+
 // GeneralConfig holds general configuration
 type GeneralConfig struct {
-	Concurrency      int64  `yaml:"concurrency"`
+	Concurrency      int64  `yaml:"concurrency"` // key generation goroutines, at least 1
 	Password         string `yaml:"password"`
-	Buffer           int    `yaml:"buffer"`
+	PasswordStrategy string `yaml:"passwordStrategy,omitempty"` // Optional: "shared" (default) or "perNode"
+	ProtocolVersion  string `yaml:"protocolVersion,omitempty"`  // Optional: genesis protocol version "<version>/<height>" (default: "1/0")
+	Buffer           int    `yaml:"buffer"`                     // account channel size, at least 1 and raised to concurrency when smaller
 	NetAddressSuffix string `yaml:"netAddressSuffix"`
 	JsonBeautify     bool   `yaml:"jsonBeautify"`
+	WriterBuffer     int    `yaml:"writerBuffer,omitempty"`  // Optional: jwriter streaming buffer size in bytes (default: sized by entries)
+	IdsChainNames    bool   `yaml:"idsChainNames,omitempty"` // Optional: embed a chain id -> config name map in ids.json (default: false)
+	// Optional: also write an ids-root-<id>.json per root chain with only its and its nested chains' nodes (default: false)
+	IdsPerRootChain bool `yaml:"idsPerRootChain,omitempty"`
+	// Optional: node types written to keystore.json, of validator, delegator, fullnode and account (default: all)
+	KeystoreInclude []string `yaml:"keystoreInclude,omitempty"`
+	// Optional: derive every node key from this seed and the node id, so reruns yield the same keys (default: random keys)
+	Seed string `yaml:"seed,omitempty"`
 }
 
 // NodesConfig holds the total node count
@@ -45,28 +111,38 @@ type NodesConfig struct {
 
 // ValidatorsConfig holds validator-specific configuration
 type ValidatorsConfig struct {
-	Count        int    `yaml:"count"`
-	StakedAmount uint64 `yaml:"stakedAmount"`
-	Amount       uint64 `yaml:"amount"`
+	Count        int         `yaml:"count"`
+	StakedAmount uint64      `yaml:"stakedAmount"`
+	Amount       NodeAmounts `yaml:"amount"`
+	SleepUntil   int         `yaml:"sleepUntil,omitempty"` // Optional: epoch timestamp the validators sleep until (default: chain sleepUntil)
+	KeyType      string      `yaml:"keyType,omitempty"`    // Optional: key type of the validators, only bls12381 is accepted by canopy (default: bls12381)
+	// Optional: vary the validators' stakes instead of staking stakedAmount each (default: none)
+	StakeDistribution *StakeDistribution `yaml:"stakeDistribution,omitempty"`
+
+	stakes *NodeAmounts // the stakes drawn from StakeDistribution, set by validateStakeDistribution
 }
 
 // FullNodesConfig holds full node-specific configuration
 type FullNodesConfig struct {
-	Count  int    `yaml:"count"`
-	Amount uint64 `yaml:"amount"`
+	Count      int         `yaml:"count"`
+	Amount     NodeAmounts `yaml:"amount"`
+	SleepUntil int         `yaml:"sleepUntil,omitempty"` // Optional: epoch timestamp the full nodes sleep until (default: chain sleepUntil)
+	Tracks     []int       `yaml:"tracks,omitempty"`     // Optional: ids of other chains the full nodes follow through an extra rootChain entry (default: none)
+	KeyType    string      `yaml:"keyType,omitempty"`    // Optional: key type of the full nodes, bls12381 or ed25519 (default: validators' keyType)
 }
 
 // AccountsConfig holds account-specific configuration
 type AccountsConfig struct {
-	Count  int    `yaml:"count"`
-	Amount uint64 `yaml:"amount"`
+	Count  int         `yaml:"count"`
+	Amount NodeAmounts `yaml:"amount"`
 }
 
 // DelegatorsConfig holds delegator-specific configuration
 type DelegatorsConfig struct {
-	Count        int    `yaml:"count"`
-	StakedAmount uint64 `yaml:"stakedAmount"`
-	Amount       uint64 `yaml:"amount"`
+	Count        int         `yaml:"count"`
+	StakedAmount uint64      `yaml:"stakedAmount"`
+	Amount       NodeAmounts `yaml:"amount"`
+	KeyType      string      `yaml:"keyType,omitempty"` // Optional: key type of the delegators, bls12381 or ed25519 (default: validators' keyType)
 }
 
 // CommitteeAssignment defines cross-chain committee participation
@@ -88,6 +164,256 @@ type CommitteeAssignment struct {
 	// Accounts/Keystore: appear in TARGET chain
 	// In ids.json they would have chainId = target committee ID (if included)
 	DelegatorCount int `yaml:"delegatorCount"`
+	// CrossChainAccountAmount: balance of the participants' accounts in the target chain (default: their home chain amount)
+	CrossChainAccountAmount uint64 `yaml:"crossChainAccountAmount,omitempty"`
+}
+
+// NodeAmounts is the account balance of the nodes of a type: a single amount for every node, a list with
+// one amount per node index, or a map of node index to amount whose "default" key funds the unlisted nodes.
+// Nodes outside the indexed ones (committee-only nodes, main accounts) get the default
+type NodeAmounts struct {
+	// Default funds every node without an amount of its own
+	Default uint64
+	// PerIndex are the amounts of individual nodes by their index among the nodes of the type
+	PerIndex map[int]uint64
+
+	hasDefault bool // a single amount or a map with a default key
+	list       int  // length of the list the amounts were given as, -1 for a single amount or a map
+}
+
+// NewNodeAmounts returns amounts funding every node with amount
+func NewNodeAmounts(amount uint64) NodeAmounts {
+	return NodeAmounts{Default: amount, hasDefault: true, list: -1}
+}
+
+// UnmarshalYAML decodes a scalar amount, a list of amounts or a map of index (or "default") to amount
+func (a *NodeAmounts) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var amount uint64
+		if err := node.Decode(&amount); err != nil {
+			return err
+		}
+		*a = NewNodeAmounts(amount)
+	case yaml.SequenceNode:
+		var amounts []uint64
+		if err := node.Decode(&amounts); err != nil {
+			return err
+		}
+		*a = NodeAmounts{PerIndex: make(map[int]uint64, len(amounts)), list: len(amounts)}
+		for i, amount := range amounts {
+			a.PerIndex[i] = amount
+		}
+	case yaml.MappingNode:
+		var amounts map[string]uint64
+		if err := node.Decode(&amounts); err != nil {
+			return err
+		}
+		*a = NodeAmounts{PerIndex: make(map[int]uint64, len(amounts)), list: -1}
+		for key, amount := range amounts {
+			if key == "default" {
+				a.Default, a.hasDefault = amount, true
+				continue
+			}
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 {
+				return fmt.Errorf("amount key '%s' is neither a node index nor default", key)
+			}
+			a.PerIndex[index] = amount
+		}
+	default:
+		return fmt.Errorf("amount must be a number, a list or a map")
+	}
+	return nil
+}
+
+// At returns the amount of the node at index
+func (a NodeAmounts) At(index int) uint64 {
+	if amount, ok := a.PerIndex[index]; ok {
+		return amount
+	}
+	return a.Default
+}
+
+// Total returns the sum of the amounts of count nodes
+func (a NodeAmounts) Total(count int) uint64 {
+	total := uint64(0)
+	for i := range count {
+		total += a.At(i)
+	}
+	return total
+}
+
+// check returns why the amounts don't fit count nodes: a list not of count amounts, map indices past
+// the nodes or, without a default, unfunded nodes
+func (a NodeAmounts) check(count int) error {
+	if a.list >= 0 && a.list != count {
+		return fmt.Errorf("has %d amounts for %d nodes", a.list, count)
+	}
+	for index := range a.PerIndex {
+		if index >= count {
+			return fmt.Errorf("has an amount for node index %d of %d nodes", index, count)
+		}
+	}
+	if !a.hasDefault && a.list < 0 && len(a.PerIndex) < count {
+		return fmt.Errorf("has amounts for %d of %d nodes and no default", len(a.PerIndex), count)
+	}
+	return nil
+}
+
+// scale repeats the amounts of a list of count nodes factor times, so a scaled config keeps its per-node
+// pattern. Single amounts and maps are kept
+func (a *NodeAmounts) scale(count int, factor int) {
+	if a.list < 0 || a.list != count {
+		return
+	}
+	for i := count; i < count*factor; i++ {
+		a.PerIndex[i] = a.PerIndex[i%count]
+	}
+	a.list = count * factor
+}
+
+// Stake distribution kinds
+const (
+	stakeUniform = "uniform"
+	stakeZipf    = "zipf"
+)
+
+// StakeDistribution draws a stake between Min and Max for every validator of a chain: uniform draws them at
+// random, zipf gives the validator at index i Max/(i+1)^Exponent, so few validators hold most of the stake
+type StakeDistribution struct {
+	Kind     string  `yaml:"kind"`
+	Min      uint64  `yaml:"min"`
+	Max      uint64  `yaml:"max"`
+	Exponent float64 `yaml:"exponent,omitempty"` // Optional: zipf exponent (default: 1)
+}
+
+// check returns why the distribution can't be drawn from
+func (d StakeDistribution) check() error {
+	switch {
+	case d.Kind != stakeUniform && d.Kind != stakeZipf:
+		return fmt.Errorf("kind '%s' is neither %s nor %s", d.Kind, stakeUniform, stakeZipf)
+	case d.Max == 0:
+		return fmt.Errorf("max must be above 0")
+	case d.Min > d.Max:
+		return fmt.Errorf("min %d is above max %d", d.Min, d.Max)
+	case d.Exponent < 0:
+		return fmt.Errorf("exponent %g is negative", d.Exponent)
+	}
+	return nil
+}
+
+// draw returns the stakes of count validators. Committee-only validators, which are outside the indexed
+// ones, stake defaultStake. With a seed the uniform stakes are derived from it and the chain id, so reruns
+// yield the same stakes
+func (d StakeDistribution) draw(count int, defaultStake uint64, seed string, chainID int) NodeAmounts {
+	stakes := NodeAmounts{Default: defaultStake, PerIndex: make(map[int]uint64, count), hasDefault: true, list: -1}
+	var rng *rand.Rand
+	if seed == "" {
+		rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	} else {
+		digest := sha256.Sum256(fmt.Appendf(nil, "%s/stake/%d", seed, chainID))
+		rng = rand.New(rand.NewPCG(binary.BigEndian.Uint64(digest[:8]), binary.BigEndian.Uint64(digest[8:16])))
+	}
+	exponent := d.Exponent
+	if exponent == 0 {
+		exponent = 1
+	}
+	for i := range count {
+		switch d.Kind {
+		case stakeUniform:
+			stakes.PerIndex[i] = d.Min + rng.Uint64N(d.Max-d.Min+1)
+		case stakeZipf:
+			stakes.PerIndex[i] = max(d.Min, uint64(float64(d.Max)/math.Pow(float64(i+1), exponent)))
+		}
+	}
+	return stakes
+}
+
+// stakeAmounts returns the stakes of the validators, stakedAmount for each without a stake distribution
+func (c ValidatorsConfig) stakeAmounts() NodeAmounts {
+	if c.stakes != nil {
+		return *c.stakes
+	}
+	return NewNodeAmounts(c.StakedAmount)
+}
+
+// crossChainAmount returns the target chain account balance for a participant of the assignment
+func (ca CommitteeAssignment) crossChainAmount(homeAmount uint64) uint64 {
+	if ca.CrossChainAccountAmount == 0 {
+		return homeAmount
+	}
+	return ca.CrossChainAccountAmount
+}
+
+// crossChainAccountAmount returns the target chain account balance for a participant of chainID's
+// committee assignment to committee, defaulting to its home chain amount
+func crossChainAccountAmount(cfg *AppConfig, chainID int, committee uint64, homeAmount uint64) uint64 {
+	for _, chainCfg := range cfg.Chains {
+		if chainCfg.ID != chainID {
+			continue
+		}
+		for _, ca := range chainCfg.Committees {
+			if uint64(ca.ID) == committee {
+				return ca.crossChainAmount(homeAmount)
+			}
+		}
+	}
+	return homeAmount
+}
+
+// BorrowedValidators declares that a chain is secured by validators whose home is another chain
+// The first Count validators of Chain are staked for the borrowing chain's committee and appear in its
+// genesis and accounts, but they are not native nodes of the borrowing chain (no expanded ids.json entry)
+type BorrowedValidators struct {
+	Chain string `yaml:"chain" json:"chain"` // Name of the chain the validators are borrowed from
+	Count int    `yaml:"count" json:"count"`
+}
+
+// SharedCommittee declares that several chains are secured by the same validators
+// The first ValidatorCount validators (and DelegatorCount delegators) of Chain are assigned as repeatedIdentity
+// to the committee of every chain in Chains, so they appear with the same keys in each chain's genesis
+type SharedCommittee struct {
+	Name           string   `yaml:"name,omitempty"`           // Optional: label used in messages (default: sharedCommittee-<index>)
+	Chain          string   `yaml:"chain"`                    // Name of the home chain of the validators
+	ValidatorCount int      `yaml:"validatorCount"`           // The first ValidatorCount validators of Chain
+	DelegatorCount int      `yaml:"delegatorCount,omitempty"` // Optional: the first DelegatorCount delegators of Chain (default: 0)
+	Chains         []string `yaml:"chains"`                   // Names of the chains the validators secure
+}
+
+// SlashingConfig overrides the genesis slashing params of a chain, unset fields keep their defaults
+type SlashingConfig struct {
+	DoubleSignSlashPercentage *uint64 `yaml:"doubleSignSlashPercentage,omitempty"` // Optional: % slashed for double signing (default: 10)
+	NonSignSlashPercentage    *uint64 `yaml:"nonSignSlashPercentage,omitempty"`    // Optional: % slashed for non signing (default: 1)
+	MaxNonSign                *uint64 `yaml:"maxNonSign,omitempty"`                // Optional: blocks a validator may miss per window before being slashed (default: 4)
+	NonSignWindow             *uint64 `yaml:"nonSignWindow,omitempty"`             // Optional: blocks after which the non-sign count resets (default: 10)
+	MaxSlashPerCommittee      *uint64 `yaml:"maxSlashPerCommittee,omitempty"`      // Optional: max % slashed per committee per block (default: 15)
+}
+
+// RewardsConfig overrides the genesis reward params of a chain, unset fields keep their defaults
+type RewardsConfig struct {
+	DelegateRewardPercentage           *uint64 `yaml:"delegateRewardPercentage,omitempty"`           // Optional: % of the committee reward awarded to delegators (default: 10)
+	DaoRewardPercentage                *uint64 `yaml:"daoRewardPercentage,omitempty"`                // Optional: % of the minted reward sent to the DAO (default: 10)
+	StakePercentForSubsidizedCommittee *uint64 `yaml:"stakePercentForSubsidizedCommittee,omitempty"` // Optional: min % of total stake for a committee to be paid (default: 33)
+	EarlyWithdrawalPenalty             *uint64 `yaml:"earlyWithdrawalPenalty,omitempty"`             // Optional: % of rewards burned for stakes withdrawing early (non-compounding) (default: 20)
+}
+
+// OrdersConfig overrides the genesis order params of a chain, unset fields keep their defaults
+type OrdersConfig struct {
+	BuyDeadlineBlocks      *uint64 `yaml:"buyDeadlineBlocks,omitempty"`      // Optional: blocks a buyer has to close an order after locking it (default: 15)
+	LockOrderFeeMultiplier *uint64 `yaml:"lockOrderFeeMultiplier,omitempty"` // Optional: multiple of the send fee a lock order tx must pay (default: 2)
+}
+
+// IDRange pins the node ids of a chain, so changing the node counts of other chains doesn't renumber it
+// The chain's validators, committee-only validators and full nodes get the ids from Start on, in that order
+type IDRange struct {
+	Start int `yaml:"start"`
+}
+
+// lentCommittee is a committee the chain's first Count validators are lent to (resolved from BorrowedValidators)
+type lentCommittee struct {
+	ID    int
+	Count int
 }
 
 // ChainConfig represents a single chain's configuration
@@ -99,10 +425,15 @@ type ChainConfig struct {
 	Accounts                   AccountsConfig        `yaml:"accounts"`
 	Delegators                 DelegatorsConfig      `yaml:"delegators"`
 	Committees                 []CommitteeAssignment `yaml:"committees"`
+	BorrowedValidators         []BorrowedValidators  `yaml:"borrowedValidators,omitempty"`         // Optional: validators borrowed from other chains
 	GossipThreshold            uint                  `yaml:"gossipThreshold"`                      // Optional: gossip threshold (default: 0)
 	SleepUntil                 int                   `yaml:"sleepUntil,omitempty"`                 // Optional: epoch timestamp for sleepUntil
 	MaxCommitteeSize           int                   `yaml:"maxCommitteeSize,omitempty"`           // Optional: max committee size (default: 100)
+	MaxCommittees              int                   `yaml:"maxCommittees,omitempty"`              // Optional: max committees per validator (default: 15)
+	UnstakingBlocks            uint64                `yaml:"unstakingBlocks,omitempty"`            // Optional: blocks a validator unstakes for (default: 2)
+	DelegateUnstakingBlocks    uint64                `yaml:"delegateUnstakingBlocks,omitempty"`    // Optional: blocks a delegator unstakes for, at least 2 (default: 2)
 	BlockSize                  uint64                `yaml:"blockSize,omitempty"`                  // Optional: block size (default: 1000000)
+	Retired                    uint64                `yaml:"retired,omitempty"`                    // Optional: retirement height, non-zero marks a nested chain retired at genesis (default: 0)
 	MinimumPeersToStart        int                   `yaml:"minimumPeersToStart,omitempty"`        // Optional: minimum peers to start (default: 0)
 	MaxInbound                 int                   `yaml:"maxInbound,omitempty"`                 // Optional: max inbound connections (default: 100)
 	MaxOutbound                int                   `yaml:"maxOutbound,omitempty"`                // Optional: max outbound connections (default: 100)
@@ -111,7 +442,15 @@ type ChainConfig struct {
 	DropPercentage             int                   `yaml:"dropPercentage,omitempty"`             // Optional: percentage of transactions to drop (default: 0)
 	MaxTransactionCount        uint32                `yaml:"maxTransactionCount,omitempty"`        // Optional: max transactions count (default: 1000)
 	MaxTotalBytes              uint64                `yaml:"maxTotalBytes,omitempty"`              // Optional: max total bytes (default: 1000000)
+	IndividualMaxTxSize        uint32                `yaml:"individualMaxTxSize,omitempty"`        // Optional: max bytes of a single mempool tx (default: 4000)
 	PoolAmount                 uint64                `yaml:"poolAmount,omitempty"`                 // Optional: Amount for the initial liquidity pool
+	Slashing                   *SlashingConfig       `yaml:"slashing,omitempty"`                   // Optional: slashing param overrides
+	Rewards                    *RewardsConfig        `yaml:"rewards,omitempty"`                    // Optional: reward param overrides
+	Orders                     *OrdersConfig         `yaml:"orders,omitempty"`                     // Optional: order param overrides
+	IDRange                    *IDRange              `yaml:"idRange,omitempty"`                    // Optional: pinned node id range (default: running index)
+
+	// lent is resolved from other chains' BorrowedValidators, used internally
+	lent []lentCommittee
 }
 
 // AppConfig represents the configuration structure
@@ -119,6 +458,8 @@ type AppConfig struct {
 	General GeneralConfig           `yaml:"general"`
 	Nodes   NodesConfig             `yaml:"nodes"`
 	Chains  map[string]*ChainConfig `yaml:"chains"`
+	// SharedCommittees are validator sets securing several chains, expanded into committee assignments
+	SharedCommittees []SharedCommittee `yaml:"sharedCommittees,omitempty"`
 }
 
 // NodeIdentity represents a node's identity for ids.json
@@ -132,7 +473,10 @@ type NodeIdentity struct {
 	PublicKey     string   `json:"publicKey"`
 	PrivateKey    string   `json:"privateKey"`
 	NodeType      string   `json:"nodeType"`
-	Committees    []uint64 `json:"-"` // Not exported to JSON, used internally
+	SleepUntil    int      `json:"sleepUntil,omitempty"` // Optional: overrides the chain's config.json sleepUntil for this node
+	Committees    []uint64 `json:"-"`                    // Not exported to JSON, used internally
+	// Tracks are the other chains a full node follows, each with the node init-node points its rootChain entry to
+	Tracks []TrackedChain `json:"tracks,omitempty"`
 	// ExpandingCommittees tracks which committees this validator should create expanded entries for
 	// (appears in other chain's genesis). Other committees are just staked but don't expand.
 	ExpandingCommittees map[uint64]bool `json:"-"` // Not exported to JSON, used internally
@@ -145,6 +489,12 @@ type NodeIdentity struct {
 	GenesisChainID int `json:"-"` // Not exported to JSON, used for genesis placement
 }
 
+// TrackedChain is a chain a full node follows besides its own, through the RPC of Node
+type TrackedChain struct {
+	ChainID int `json:"chainId"`
+	Node    int `json:"node"`
+}
+
 // MainAccount represents a main account identity for ids.json
 type MainAccount struct {
 	Address         string `json:"address" yaml:"address"`
@@ -161,20 +511,56 @@ type MainAccountsFile struct {
 
 // IdsFile represents the structure of ids.json
 type IdsFile struct {
+	Chains       map[int]string          `json:"chains,omitempty"` // chain id -> config chain name, with general.idsChainNames
 	MainAccounts map[string]*MainAccount `json:"main-accounts,omitempty"`
-	Keys         map[string]NodeIdentity `json:"keys"`
+	// KeystoreExcluded are the node types left out of the keystores with general.keystoreInclude
+	KeystoreExcluded []string `json:"keystore-excluded,omitempty"`
+	// GenesisHashes is the SHA-256 (hex) of each chain's genesis.json by chain id, to verify the mounted genesis
+	GenesisHashes map[int]string          `json:"genesis-hashes,omitempty"`
+	Keys          map[string]NodeIdentity `json:"keys"`
+}
+
+// AddressIndexEntry represents a single ids.json entry for an address in address-index.json
+// An address may map to multiple entries (repeatedIdentity validators appear once per committee)
+type AddressIndexEntry struct {
+	ID         int      `json:"id"`
+	Key        string   `json:"key"`
+	ChainID    int      `json:"chainId"`
+	NodeType   string   `json:"nodeType"`
+	Committees []uint64 `json:"committees"`
 }
 
 var configFile = "configs.yml"
 var accountsFile = "accounts.yml"
 
+const (
+	includeKey   = "include"   // Top-level list of config files merged into the including file
+	templatesKey = "templates" // Top-level map of chain templates
+	templateKey  = "template"  // Chain-level reference to a chain template
+)
+
+// configFilePath returns the path of the config file, -configFile or configs.yml under -path
+func configFilePath() string {
+	if *configFileFlag != "" {
+		return *configFileFlag
+	}
+	return filepath.Join(*configPath, configFile)
+}
+
 func loadConfigs() (map[string]*AppConfig, error) {
-	configFile = filepath.Join(*configPath, configFile)
-	data, err := os.ReadFile(configFile)
+	tree, err := loadConfigTree(configFilePath(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file '%s': %w", configFile, err)
+		return nil, err
+	}
+	if err := resolveChainTemplates(tree); err != nil {
+		return nil, err
 	}
 
+	// Re-encode the resolved tree so the configs unmarshal as if written in a single file
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resolved config: %w", err)
+	}
 	configs := make(map[string]*AppConfig)
 	if err := yaml.Unmarshal(data, &configs); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
@@ -183,6 +569,123 @@ func loadConfigs() (map[string]*AppConfig, error) {
 	return configs, nil
 }
 
+// loadConfigTree reads a config file and merges its includes into it. Includes are resolved relative to
+// the including file and merged in order, each one overriding the previous, with the including file's
+// own entries taking precedence. stack holds the files being included, to detect circular includes
+func loadConfigTree(path string, stack []string) (map[string]any, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config file '%s': %w", path, err)
+	}
+	for i, included := range stack {
+		if included == absPath {
+			return nil, fmt.Errorf("circular include: %s", strings.Join(append(stack[i:], absPath), " -> "))
+		}
+	}
+	stack = append(stack, absPath)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file '%s': %w", path, err)
+	}
+	tree := make(map[string]any)
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse config file '%s': %w", path, err)
+	}
+
+	includes, err := stringList(tree[includeKey])
+	if err != nil {
+		return nil, fmt.Errorf("config file '%s': %s: %w", path, includeKey, err)
+	}
+	delete(tree, includeKey)
+
+	merged := make(map[string]any)
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		included, err := loadConfigTree(include, stack)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeConfigTrees(merged, included)
+	}
+	return mergeConfigTrees(merged, tree), nil
+}
+
+// resolveChainTemplates replaces every chain's template reference with the template merged with the
+// chain's own fields, which take precedence. The templates are removed from the tree
+func resolveChainTemplates(tree map[string]any) error {
+	templates, ok := tree[templatesKey].(map[string]any)
+	if tree[templatesKey] != nil && !ok {
+		return fmt.Errorf("%s must be a map of chain templates", templatesKey)
+	}
+	delete(tree, templatesKey)
+
+	for configName, rawConfig := range tree {
+		config, _ := rawConfig.(map[string]any)
+		chains, _ := config["chains"].(map[string]any)
+		for chainName, rawChain := range chains {
+			chain, _ := rawChain.(map[string]any)
+			if chain == nil || chain[templateKey] == nil {
+				continue
+			}
+			name, ok := chain[templateKey].(string)
+			if !ok {
+				return fmt.Errorf("config %s chain %s: %s must be a string", configName, chainName, templateKey)
+			}
+			template, exists := templates[name].(map[string]any)
+			if !exists {
+				return fmt.Errorf("config %s chain %s: unknown %s '%s'", configName, chainName, templateKey, name)
+			}
+			delete(chain, templateKey)
+			chains[chainName] = mergeConfigTrees(template, chain)
+		}
+	}
+	return nil
+}
+
+// mergeConfigTrees returns base deep-merged with override: maps are merged key by key, any other
+// value (scalars and lists) in override replaces the one in base. Neither argument is modified
+func mergeConfigTrees(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		baseMap, baseIsMap := merged[k].(map[string]any)
+		overrideMap, overrideIsMap := v.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			merged[k] = mergeConfigTrees(baseMap, overrideMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// stringList converts a YAML string or list of strings into a slice
+func stringList(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %v", item)
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a string or list of strings, got %v", value)
+	}
+}
+
 func loadMainAccounts() (map[string]*MainAccount, error) {
 	accountsFilePath := filepath.Join(*configPath, accountsFile)
 	data, err := os.ReadFile(accountsFilePath)
@@ -199,18 +702,49 @@ func loadMainAccounts() (map[string]*MainAccount, error) {
 		return nil, fmt.Errorf("failed to parse accounts file: %w", err)
 	}
 
-	// Decode private key bytes for each account
-	for name, account := range accountsData.Accounts {
+	// Decode private key bytes and normalize the address of each account, in name order so the
+	// duplicate reported is stable
+	names := make([]string, 0, len(accountsData.Accounts))
+	for name := range accountsData.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	owners := make(map[string]string, len(names))
+	for _, name := range names {
+		account := accountsData.Accounts[name]
 		privateKeyBytes, err := hex.DecodeString(account.PrivateKey)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode private key for account '%s': %w", name, err)
 		}
 		account.PrivateKeyBytes = privateKeyBytes
+		address, err := normalizeAddress(account.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address for account '%s': %w", name, err)
+		}
+		if owner, ok := owners[address]; ok {
+			return nil, fmt.Errorf("accounts '%s' and '%s' share address %s", owner, name, address)
+		}
+		owners[address] = name
+		account.Address = address
 	}
 
 	return accountsData.Accounts, nil
 }
 
+// normalizeAddress returns a hex address as lowercase hex without 0x, failing when it doesn't decode
+// to a canopy address
+func normalizeAddress(address string) (string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(address), "0x"), "0X")
+	decoded, err := hex.DecodeString(trimmed)
+	if err != nil {
+		return "", fmt.Errorf("%q is not hex: %w", address, err)
+	}
+	if len(decoded) != crypto.AddressSize {
+		return "", fmt.Errorf("%q is %d bytes, expected %d", address, len(decoded), crypto.AddressSize)
+	}
+	return hex.EncodeToString(decoded), nil
+}
+
 func getConfig(name string) (*AppConfig, error) {
 	configs, err := loadConfigs()
 	if err != nil {
@@ -225,9 +759,64 @@ func getConfig(name string) (*AppConfig, error) {
 		}
 		return nil, fmt.Errorf("unknown config '%s'. Available configs: %s", name, strings.Join(availableConfigs, ", "))
 	}
+	if err := expandSharedCommittees(config); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
+// expandSharedCommittees adds a repeatedIdentity committee assignment to the home chain of every shared
+// committee for each of the chains it secures
+func expandSharedCommittees(cfg *AppConfig) error {
+	for i, shared := range cfg.SharedCommittees {
+		name := shared.Name
+		if name == "" {
+			name = fmt.Sprintf("sharedCommittee-%d", i)
+		}
+		home, exists := cfg.Chains[shared.Chain]
+		if !exists {
+			return fmt.Errorf("shared committee %s: chain '%s' does not exist", name, shared.Chain)
+		}
+		if shared.ValidatorCount <= 0 || shared.ValidatorCount > home.Validators.Count {
+			return fmt.Errorf("shared committee %s: validatorCount (%d) must be between 1 and chain %s validators (%d)",
+				name, shared.ValidatorCount, shared.Chain, home.Validators.Count)
+		}
+		if shared.DelegatorCount < 0 || shared.DelegatorCount > home.Delegators.Count {
+			return fmt.Errorf("shared committee %s: delegatorCount (%d) must be between 0 and chain %s delegators (%d)",
+				name, shared.DelegatorCount, shared.Chain, home.Delegators.Count)
+		}
+		if len(shared.Chains) == 0 {
+			return fmt.Errorf("shared committee %s: no chains to secure", name)
+		}
+		listed := make(map[string]bool, len(shared.Chains))
+		for _, chainName := range shared.Chains {
+			target, exists := cfg.Chains[chainName]
+			if !exists {
+				return fmt.Errorf("shared committee %s: chain '%s' does not exist", name, chainName)
+			}
+			if listed[chainName] {
+				return fmt.Errorf("shared committee %s: chain %s is listed more than once", name, chainName)
+			}
+			listed[chainName] = true
+			if chainName == shared.Chain {
+				return fmt.Errorf("shared committee %s: chain %s is the home chain of its validators", name, chainName)
+			}
+			for _, ca := range home.Committees {
+				if ca.ID == target.ID {
+					return fmt.Errorf("shared committee %s: chain %s already has a committee assignment to chain %s (ID %d)",
+						name, shared.Chain, chainName, target.ID)
+				}
+			}
+			home.Committees = append(home.Committees, CommitteeAssignment{
+				ID:                             target.ID,
+				RepeatedIdentityValidatorCount: shared.ValidatorCount,
+				RepeatedIdentityDelegatorCount: shared.DelegatorCount,
+			})
+		}
+	}
+	return nil
+}
+
 func listAvailableConfigs() []string {
 	configs, err := loadConfigs()
 	if err != nil {
@@ -240,6 +829,37 @@ func listAvailableConfigs() []string {
 	return availableConfigs
 }
 
+// scaleConfig multiplies every count of the config by factor: nodes.count, the validators, delegators, full
+// nodes and accounts of every chain, and its committee assignments (shared committees included) and borrowed
+// validators, so a balanced config stays balanced
+func scaleConfig(cfg *AppConfig, factor int) {
+	cfg.Nodes.Count *= factor
+	for _, chainCfg := range cfg.Chains {
+		chainCfg.Validators.Amount.scale(chainCfg.Validators.Count, factor)
+		chainCfg.Delegators.Amount.scale(chainCfg.Delegators.Count, factor)
+		chainCfg.FullNodes.Amount.scale(chainCfg.FullNodes.Count, factor)
+		chainCfg.Accounts.Amount.scale(chainCfg.Accounts.Count, factor)
+		chainCfg.Validators.Count *= factor
+		chainCfg.Delegators.Count *= factor
+		chainCfg.FullNodes.Count *= factor
+		chainCfg.Accounts.Count *= factor
+		for i := range chainCfg.Committees {
+			ca := &chainCfg.Committees[i]
+			ca.RepeatedIdentityValidatorCount *= factor
+			ca.RepeatedIdentityDelegatorCount *= factor
+			ca.ValidatorCount *= factor
+			ca.DelegatorCount *= factor
+		}
+		for i := range chainCfg.BorrowedValidators {
+			chainCfg.BorrowedValidators[i].Count *= factor
+		}
+	}
+	for i := range cfg.SharedCommittees {
+		cfg.SharedCommittees[i].ValidatorCount *= factor
+		cfg.SharedCommittees[i].DelegatorCount *= factor
+	}
+}
+
 // validateConfig checks that the sum of all validators, delegators, and full nodes equals nodes.count
 // Multi-committee validators (not delegators) count once per committee they participate in
 func validateConfig(cfg *AppConfig) error {
@@ -261,13 +881,9 @@ func validateConfig(cfg *AppConfig) error {
 		chainNodes := baseNodes + repeatedIdentityExpansions + committeeOnlyValidators
 		totalNodes += chainNodes
 
-		if repeatedIdentityExpansions > 0 || committeeOnlyValidators > 0 {
-			fmt.Printf("  Chain %s: %d validators + %d full nodes + %d repeatedIdentity expansions + %d committee-only validators = %d entries (+ %d delegators)\n",
-				chainName, chainCfg.Validators.Count, chainCfg.FullNodes.Count, repeatedIdentityExpansions, committeeOnlyValidators, chainNodes, chainCfg.Delegators.Count)
-		} else {
-			fmt.Printf("  Chain %s: %d validators + %d full nodes = %d entries (+ %d delegators)\n",
-				chainName, chainCfg.Validators.Count, chainCfg.FullNodes.Count, chainNodes, chainCfg.Delegators.Count)
-		}
+		log.Info("chain node count", "chain", chainName, "validators", chainCfg.Validators.Count,
+			"full_nodes", chainCfg.FullNodes.Count, "repeated_identity_expansions", repeatedIdentityExpansions,
+			"committee_only_validators", committeeOnlyValidators, "entries", chainNodes, "delegators", chainCfg.Delegators.Count)
 	}
 
 	if totalNodes != cfg.Nodes.Count {
@@ -275,10 +891,82 @@ func validateConfig(cfg *AppConfig) error {
 			totalNodes, cfg.Nodes.Count)
 	}
 
-	fmt.Printf("  Total entries: %d (matches nodes.count: %d) ✓\n", totalNodes, cfg.Nodes.Count)
+	log.Info("total entries match nodes.count", "entries", totalNodes)
+	return nil
+}
+
+// validateRootChains checks that every chain's rootChain is either its own ID (root chain) or a defined chain ID
+func validateRootChains(cfg *AppConfig) error {
+	validChainIDs := make(map[int]bool)
+	for _, chainCfg := range cfg.Chains {
+		validChainIDs[chainCfg.ID] = true
+	}
+
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		if chainCfg.RootChain == chainCfg.ID {
+			log.Info("root chain", "chain", chainName, "chain_id", chainCfg.ID)
+			continue
+		}
+		if !validChainIDs[chainCfg.RootChain] {
+			return fmt.Errorf("chain %s: rootChain %d does not match any chain ID (available chain IDs: %v)",
+				chainName, chainCfg.RootChain, getChainIDs(cfg))
+		}
+		log.Info("nested chain", "chain", chainName, "chain_id", chainCfg.ID, "root_chain", chainCfg.RootChain)
+	}
+	return nil
+}
+
+// resolveBorrowedValidators validates every chain's borrowedValidators and records them on the lending chain
+func resolveBorrowedValidators(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		for _, borrowed := range chainCfg.BorrowedValidators {
+			lender, exists := cfg.Chains[borrowed.Chain]
+			if !exists {
+				return fmt.Errorf("chain %s: borrowedValidators chain '%s' does not exist", chainName, borrowed.Chain)
+			}
+			if borrowed.Chain == chainName {
+				return fmt.Errorf("chain %s: cannot borrow validators from itself", chainName)
+			}
+			if borrowed.Count <= 0 || borrowed.Count > lender.Validators.Count {
+				return fmt.Errorf("chain %s: borrowedValidators count (%d) from chain %s must be between 1 and its validators (%d)",
+					chainName, borrowed.Count, borrowed.Chain, lender.Validators.Count)
+			}
+			for _, ca := range lender.Committees {
+				if ca.ID == chainCfg.ID && ca.RepeatedIdentityValidatorCount > 0 {
+					return fmt.Errorf("chain %s: chain %s already assigns repeatedIdentity validators to committee %d, cannot also lend them",
+						chainName, borrowed.Chain, chainCfg.ID)
+				}
+			}
+			lender.lent = append(lender.lent, lentCommittee{ID: chainCfg.ID, Count: borrowed.Count})
+			log.Info("borrowed validators", "chain", chainName, "validators", borrowed.Count, "from", borrowed.Chain)
+		}
+	}
 	return nil
 }
 
+// borrowedValidatorCount returns the total validators a chain borrows from other chains
+func borrowedValidatorCount(chainCfg *ChainConfig) int {
+	total := 0
+	for _, borrowed := range chainCfg.BorrowedValidators {
+		total += borrowed.Count
+	}
+	return total
+}
+
 // validateCommitteeAssignments checks that committee assignments don't exceed available validators/delegators
 // and that committee IDs reference valid chain IDs
 func validateCommitteeAssignments(cfg *AppConfig) error {
@@ -299,7 +987,7 @@ func validateCommitteeAssignments(cfg *AppConfig) error {
 	if rootChainValidatorCount == 0 {
 		return fmt.Errorf("no validators found on any root chain; at least one root chain must have validators for rootChainNode assignment")
 	}
-	fmt.Printf("  Root chain validators: %d ✓\n", rootChainValidatorCount)
+	log.Info("root chain validators", "validators", rootChainValidatorCount)
 
 	for chainName, chainCfg := range cfg.Chains {
 		for _, ca := range chainCfg.Committees {
@@ -319,8 +1007,9 @@ func validateCommitteeAssignments(cfg *AppConfig) error {
 				return fmt.Errorf("chain %s: committee %d repeatedIdentityDelegatorCount (%d) exceeds total delegators (%d)",
 					chainName, ca.ID, ca.RepeatedIdentityDelegatorCount, chainCfg.Delegators.Count)
 			}
-			fmt.Printf("  Chain %s: committee %d assignment - %d repeatedIdentity validators + %d committee-only validators, %d repeatedIdentity delegators + %d committee-only delegators ✓\n",
-				chainName, ca.ID, ca.RepeatedIdentityValidatorCount, ca.ValidatorCount, ca.RepeatedIdentityDelegatorCount, ca.DelegatorCount)
+			log.Info("committee assignment", "chain", chainName, "committee", ca.ID,
+				"repeated_identity_validators", ca.RepeatedIdentityValidatorCount, "committee_only_validators", ca.ValidatorCount,
+				"repeated_identity_delegators", ca.RepeatedIdentityDelegatorCount, "committee_only_delegators", ca.DelegatorCount)
 		}
 	}
 
@@ -357,73 +1046,1169 @@ func validateCommitteeAssignments(cfg *AppConfig) error {
 		}
 
 		totalValidatorsForCommittee := repeatedIdentityValidatorCount + committeeOnlyValidatorCount
+		// A chain secured only by borrowed validators has no native nodes that need a peerNode
+		if totalValidatorsForCommittee == 0 && borrowedValidatorCount(chainCfg) > 0 &&
+			chainCfg.Validators.Count+chainCfg.FullNodes.Count == 0 {
+			log.Info("nested chain secured by borrowed validators, no native nodes", "chain", chainName,
+				"borrowed_validators", borrowedValidatorCount(chainCfg))
+			continue
+		}
 		if totalValidatorsForCommittee == 0 {
 			return fmt.Errorf("nested chain %s (ID %d): root chain must have at least one validator assigned to committee %d "+
 				"(either via repeatedIdentityValidatorCount or validatorCount) for peerNode assignment",
 				chainName, chainCfg.ID, chainCfg.ID)
 		}
-		fmt.Printf("  Nested chain %s: root chain has %d validators in committee %d (%d repeatedIdentity + %d committee-only) ✓\n",
-			chainName, totalValidatorsForCommittee, chainCfg.ID, repeatedIdentityValidatorCount, committeeOnlyValidatorCount)
+		log.Info("nested chain committee validators", "chain", chainName, "committee", chainCfg.ID,
+			"validators", totalValidatorsForCommittee, "repeated_identity", repeatedIdentityValidatorCount,
+			"committee_only", committeeOnlyValidatorCount)
 	}
 
 	return nil
 }
 
-// getChainIDs returns a slice of all chain IDs in the config
-func getChainIDs(cfg *AppConfig) []int {
-	ids := make([]int, 0, len(cfg.Chains))
-	for _, chainCfg := range cfg.Chains {
-		ids = append(ids, chainCfg.ID)
+// validatePasswordStrategy checks that the configured password strategy is supported
+func validatePasswordStrategy(cfg *AppConfig) error {
+	switch cfg.General.PasswordStrategy {
+	case "", passwordShared, passwordPerNode:
+		log.Info("password strategy", "strategy", passwordStrategy(cfg.General))
+		return nil
+	default:
+		return fmt.Errorf("unknown passwordStrategy '%s' (available: %s, %s)",
+			cfg.General.PasswordStrategy, passwordShared, passwordPerNode)
 	}
-	sort.Ints(ids)
-	return ids
 }
 
-func logData() {
-	var accounts, validators, delegators, fullNodes int32
+// chainIDSize is the number of positive node ids a chain takes: its validators, committee-only
+// validators and full nodes (delegators use negative ids)
+func chainIDSize(chainCfg *ChainConfig) int {
+	committeeOnlyValidators := 0
+	for _, ca := range chainCfg.Committees {
+		committeeOnlyValidators += ca.ValidatorCount
+	}
+	return chainCfg.Validators.Count + committeeOnlyValidators + chainCfg.FullNodes.Count
+}
 
-	go func() {
-		for nickname := range nickNames {
-			switch nickname {
-			case accountNick:
-				atomic.AddInt32(&accounts, 1)
-			case validatorNick:
-				atomic.AddInt32(&validators, 1)
-			case delegatorNick:
-				atomic.AddInt32(&delegators, 1)
-			case fullNodeNick:
-				atomic.AddInt32(&fullNodes, 1)
-			default:
-				fmt.Println("Unknown data type received:", nickname)
-			}
-		}
-	}()
+// assignChainStartIndices returns the first node id of each chain. Chains with an idRange start at its Start,
+// the others continue the running index (from 1, in chain name order) past every range assigned so far
+func assignChainStartIndices(cfg *AppConfig) map[string]int {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for name := range cfg.Chains {
+		chainNames = append(chainNames, name)
+	}
+	sort.Strings(chainNames)
+
+	startIndices := make(map[string]int)
+	currentIdx := 1
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		start := currentIdx
+		if chainCfg.IDRange != nil {
+			start = chainCfg.IDRange.Start
+		}
+		startIndices[chainName] = start
+		currentIdx = max(currentIdx, start+chainIDSize(chainCfg))
+	}
+	return startIndices
+}
+
+// validateIDRanges checks that pinned id ranges start at 1 or above and that no two chains' id ranges overlap
+func validateIDRanges(cfg *AppConfig) error {
+	for chainName, chainCfg := range cfg.Chains {
+		if chainCfg.IDRange != nil && chainCfg.IDRange.Start < 1 {
+			return fmt.Errorf("chain %s: idRange.start %d must be at least 1", chainName, chainCfg.IDRange.Start)
+		}
+	}
+
+	type idRange struct {
+		chain      string
+		start, end int // end is exclusive
+	}
+	startIndices := assignChainStartIndices(cfg)
+	var ranges []idRange
+	for chainName, start := range startIndices {
+		if size := chainIDSize(cfg.Chains[chainName]); size > 0 {
+			ranges = append(ranges, idRange{chain: chainName, start: start, end: start + size})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+
+	for i, r := range ranges {
+		if i > 0 && r.start < ranges[i-1].end {
+			prev := ranges[i-1]
+			return fmt.Errorf("chain %s ids %d-%d overlap chain %s ids %d-%d",
+				r.chain, r.start, r.end-1, prev.chain, prev.start, prev.end-1)
+		}
+		log.Info("chain id range", "chain", r.chain, "start", r.start, "end", r.end-1,
+			"pinned", cfg.Chains[r.chain].IDRange != nil)
+	}
+	return nil
+}
+
+// validateFullNodePeers checks that every chain with full nodes has at least one validator on the same chain
+// for peerNode assignment (root chain: its own validators, nested chain: repeatedIdentity or committee-only validators)
+func validateFullNodePeers(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		if chainCfg.FullNodes.Count == 0 {
+			continue
+		}
+		peerCount := fullNodePeerCount(cfg, chainCfg)
+		if peerCount == 0 {
+			return fmt.Errorf("chain %s (ID %d): %d full nodes but no validators on the chain to assign as peerNode",
+				chainName, chainCfg.ID, chainCfg.FullNodes.Count)
+		}
+		log.Info("full node peers", "chain", chainName, "full_nodes", chainCfg.FullNodes.Count, "validators", peerCount)
+	}
+	return nil
+}
+
+// fullNodePeerCount returns the validators of a chain a full node can be pointed to: the chain's own for a
+// root chain, the repeatedIdentity and committee-only ones its root chain assigns for a nested chain
+func fullNodePeerCount(cfg *AppConfig, chainCfg *ChainConfig) int {
+	if chainCfg.ID == chainCfg.RootChain {
+		return chainCfg.Validators.Count
+	}
+	peerCount := 0
+	for _, c := range cfg.Chains {
+		if c.ID != chainCfg.RootChain {
+			continue
+		}
+		for _, ca := range c.Committees {
+			if ca.ID == chainCfg.ID {
+				peerCount += ca.RepeatedIdentityValidatorCount + ca.ValidatorCount
+			}
+		}
+	}
+	return peerCount
+}
+
+// validateFullNodeTracks checks every chain tracked by full nodes is another configured chain, not already
+// followed as their root chain, with validators to point the full nodes' rootChain entry to
+func validateFullNodeTracks(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	chainsByID := make(map[int]*ChainConfig, len(cfg.Chains))
+	for chainName, chainCfg := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+		chainsByID[chainCfg.ID] = chainCfg
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		tracks := chainCfg.FullNodes.Tracks
+		if len(tracks) == 0 {
+			continue
+		}
+		if chainCfg.FullNodes.Count == 0 {
+			invalid = append(invalid, fmt.Sprintf("chain %s tracks chains %v without full nodes", chainName, tracks))
+			continue
+		}
+		chainInvalid := len(invalid)
+		seen := make(map[int]bool, len(tracks))
+		for _, tracked := range tracks {
+			trackedCfg, ok := chainsByID[tracked]
+			switch {
+			case seen[tracked]:
+				invalid = append(invalid, fmt.Sprintf("chain %s tracks chain %d more than once", chainName, tracked))
+			case !ok:
+				invalid = append(invalid, fmt.Sprintf("chain %s tracks unknown chain %d", chainName, tracked))
+			case tracked == chainCfg.ID:
+				invalid = append(invalid, fmt.Sprintf("chain %s tracks itself", chainName))
+			case tracked == chainCfg.RootChain:
+				invalid = append(invalid, fmt.Sprintf("chain %s tracks its root chain %d, already in its rootChain", chainName, tracked))
+			case fullNodePeerCount(cfg, trackedCfg) == 0:
+				invalid = append(invalid, fmt.Sprintf("chain %s tracks chain %d, which has no validators to follow", chainName, tracked))
+			}
+			seen[tracked] = true
+		}
+		if len(invalid) == chainInvalid {
+			log.Info("full node tracks", "chain", chainName, "full_nodes", chainCfg.FullNodes.Count, "tracks", tracks)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid full node tracks: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// defaultProtocolVersion is the genesis protocol version when general.protocolVersion is unset
+const defaultProtocolVersion = "1/0"
+
+// validateProtocolVersion checks the configured protocol version parses as "<version>/<height>"
+func validateProtocolVersion(cfg *AppConfig) error {
+	consensus := &fsm.ConsensusParams{ProtocolVersion: protocolVersion(cfg.General)}
+	version, err := consensus.ParseProtocolVersion()
+	if err != nil {
+		return fmt.Errorf("invalid protocolVersion '%s', expected \"<version>/<height>\" (e.g. %s)",
+			consensus.ProtocolVersion, defaultProtocolVersion)
+	}
+	log.Info("protocol version", "version", version.Version, "height", version.Height)
+	return nil
+}
+
+// validateConcurrency checks the key generation concurrency and the account channel buffer are positive,
+// raising a buffer smaller than the concurrency to it so the generating goroutines don't queue on the
+// single collector
+func validateConcurrency(cfg *AppConfig) error {
+	if cfg.General.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", cfg.General.Concurrency)
+	}
+	if cfg.General.Buffer < 1 {
+		return fmt.Errorf("buffer must be at least 1, got %d", cfg.General.Buffer)
+	}
+	if int64(cfg.General.Buffer) < cfg.General.Concurrency {
+		log.Info("buffer raised to concurrency", "buffer", cfg.General.Buffer, "concurrency", cfg.General.Concurrency)
+		cfg.General.Buffer = int(cfg.General.Concurrency)
+	}
+	log.Info("concurrency", "concurrency", cfg.General.Concurrency, "buffer", cfg.General.Buffer)
+	return nil
+}
+
+// validateKeystoreInclude checks the node types listed in keystoreInclude
+func validateKeystoreInclude(cfg *AppConfig) error {
+	for _, nodeType := range cfg.General.KeystoreInclude {
+		if !slices.Contains(keystoreTypes, nodeType) {
+			return fmt.Errorf("unknown keystoreInclude node type '%s' (available: %s)", nodeType, strings.Join(keystoreTypes, ", "))
+		}
+	}
+	included := keystoreIncluded(cfg.General)
+	var types []string
+	for _, nodeType := range keystoreTypes {
+		if included[nodeType] {
+			types = append(types, nodeType)
+		}
+	}
+	log.Info("keystore node types", "types", types)
+	return nil
+}
+
+// keystoreIncluded returns the set of node types written to keystore.json, all of them when unset
+func keystoreIncluded(general GeneralConfig) map[string]bool {
+	list := general.KeystoreInclude
+	if len(list) == 0 {
+		list = keystoreTypes
+	}
+	included := make(map[string]bool, len(list))
+	for _, nodeType := range list {
+		included[nodeType] = true
+	}
+	return included
+}
+
+// protocolVersion returns the configured genesis protocol version, defaulting to defaultProtocolVersion
+func protocolVersion(general GeneralConfig) string {
+	if general.ProtocolVersion == "" {
+		return defaultProtocolVersion
+	}
+	return general.ProtocolVersion
+}
+
+// passwordStrategy returns the configured password strategy, defaulting to shared
+func passwordStrategy(general GeneralConfig) string {
+	if general.PasswordStrategy == "" {
+		return passwordShared
+	}
+	return general.PasswordStrategy
+}
+
+// keyTypes returns the key types of the chain's validators, delegators and full nodes. Validators default to
+// bls12381, delegators and full nodes without one default to the validators' key type
+func (c *ChainConfig) keyTypes() (validators, delegators, fullNodes string) {
+	validators, delegators, fullNodes = c.Validators.KeyType, c.Delegators.KeyType, c.FullNodes.KeyType
+	if validators == "" {
+		validators = keyTypeBLS12381
+	}
+	if delegators == "" {
+		delegators = validators
+	}
+	if fullNodes == "" {
+		fullNodes = validators
+	}
+	return validators, delegators, fullNodes
+}
+
+// validateKeyTypes checks every chain's node types use a known key type. Validators must use bls12381, as
+// canopy rejects a genesis with a non-delegate validator whose public key isn't a BLS12-381 one
+func validateKeyTypes(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		validators, delegators, fullNodes := cfg.Chains[chainName].keyTypes()
+		chainInvalid := len(invalid)
+		if validators != keyTypeBLS12381 {
+			invalid = append(invalid, fmt.Sprintf("chain %s validators keyType '%s' is not supported, canopy requires %s validator keys",
+				chainName, validators, keyTypeBLS12381))
+		}
+		for _, nodeType := range []struct{ name, keyType string }{{"delegators", delegators}, {"fullNodes", fullNodes}} {
+			if nodeType.keyType != keyTypeBLS12381 && nodeType.keyType != keyTypeEd25519 {
+				invalid = append(invalid, fmt.Sprintf("chain %s %s keyType '%s' is unknown (available: %s, %s)",
+					chainName, nodeType.name, nodeType.keyType, keyTypeBLS12381, keyTypeEd25519))
+			}
+		}
+		if len(invalid) == chainInvalid {
+			log.Info("key types", "chain", chainName, "validators", validators, "delegators", delegators, "full_nodes", fullNodes)
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid key types: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// derivePassword derives a node's keystore password from the configured password (seed) and its nickname
+func derivePassword(seed string, nickname string) string {
+	sum := sha256.Sum256([]byte(seed + ":" + nickname))
+	return hex.EncodeToString(sum[:16])
+}
+
+// validateCommitteeSize checks that no committee has more validators (native, repeatedIdentity, committee-only
+// and borrowed) than the maxCommitteeSize of the committee's chain, warning about oversized committees or
+// failing with -strict
+func validateCommitteeSize(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	// members counts each committee's validators, and sources the validators each chain adds to it
+	members := make(map[int]int)
+	sources := make(map[int][]string)
+	add := func(committee int, chainName string, count int) {
+		if count == 0 {
+			return
+		}
+		members[committee] += count
+		sources[committee] = append(sources[committee], fmt.Sprintf("%d from %s", count, chainName))
+	}
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		// Sum per committee first, as a chain may assign validators to a committee more than once
+		added := map[int]int{chainCfg.ID: chainCfg.Validators.Count}
+		for _, ca := range chainCfg.Committees {
+			added[ca.ID] += ca.RepeatedIdentityValidatorCount + ca.ValidatorCount
+		}
+		for _, lent := range chainCfg.lent {
+			added[lent.ID] += lent.Count
+		}
+		for _, committee := range slices.Sorted(maps.Keys(added)) {
+			add(committee, chainName, added[committee])
+		}
+	}
+
+	var oversized []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		maxSize := effectiveMaxCommitteeSize(chainCfg)
+		if validators := members[chainCfg.ID]; validators > maxSize {
+			oversized = append(oversized, fmt.Sprintf("chain %s: committee %d has %d validators (%s), more than maxCommitteeSize %d",
+				chainName, chainCfg.ID, validators, strings.Join(sources[chainCfg.ID], " + "), maxSize))
+		} else {
+			log.Info("committee within maxCommitteeSize", "chain", chainName, "committee", chainCfg.ID,
+				"validators", validators, "max_committee_size", maxSize)
+		}
+	}
+
+	if len(oversized) == 0 {
+		return nil
+	}
+	if *strict {
+		return fmt.Errorf("committees exceed maxCommitteeSize: %s", strings.Join(oversized, ", "))
+	}
+	for _, committee := range oversized {
+		log.Warn(committee + ", only the top staked validators will be in the committee")
+	}
+	return nil
+}
+
+// validateMaxCommittees checks that no validator or delegator is staked for more committees than its
+// chain's MaxCommittees param (own chain + repeatedIdentity assignments; committee-only entries have one committee)
+func validateMaxCommittees(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var offending []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		maxCommittees := effectiveMaxCommittees(chainCfg)
+		chainOffending := len(offending)
+		// The i-th validator/delegator participates in its own chain plus every committee whose
+		// repeatedIdentity count covers index i
+		countCommittees := func(i int, repeated func(CommitteeAssignment) int) int {
+			count := 1
+			for _, ca := range chainCfg.Committees {
+				if i < repeated(ca) {
+					count++
+				}
+			}
+			return count
+		}
+		for i := 0; i < chainCfg.Validators.Count; i++ {
+			count := countCommittees(i, func(ca CommitteeAssignment) int { return ca.RepeatedIdentityValidatorCount })
+			// Validators lent to other chains are also staked for the borrowing chain's committee
+			for _, lent := range chainCfg.lent {
+				if i < lent.Count {
+					count++
+				}
+			}
+			if count > maxCommittees {
+				offending = append(offending, fmt.Sprintf("chain %s validator #%d (%d committees)", chainName, i+1, count))
+			}
+		}
+		for i := 0; i < chainCfg.Delegators.Count; i++ {
+			count := countCommittees(i, func(ca CommitteeAssignment) int { return ca.RepeatedIdentityDelegatorCount })
+			if count > maxCommittees {
+				offending = append(offending, fmt.Sprintf("chain %s delegator #%d (%d committees)", chainName, i+1, count))
+			}
+		}
+		if len(offending) == chainOffending {
+			log.Info("committees per validator within maxCommittees", "chain", chainName, "max_committees", maxCommittees)
+		}
+	}
+
+	if len(offending) > 0 {
+		return fmt.Errorf("validators exceed maxCommittees: %s", strings.Join(offending, ", "))
+	}
+	return nil
+}
+
+// validateDelegatorCommittees checks that every committee delegators are staked for (own chain, repeatedIdentity and
+// committee-only assignments) has at least one validator, warning about orphan delegations or failing with -strict
+func validateDelegatorCommittees(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	// Committees with at least one validator: native, borrowed, repeatedIdentity or committee-only
+	withValidators := make(map[int]bool)
+	for _, chainCfg := range cfg.Chains {
+		if chainCfg.Validators.Count > 0 || borrowedValidatorCount(chainCfg) > 0 {
+			withValidators[chainCfg.ID] = true
+		}
+		for _, ca := range chainCfg.Committees {
+			if ca.RepeatedIdentityValidatorCount+ca.ValidatorCount > 0 {
+				withValidators[ca.ID] = true
+			}
+		}
+	}
+
+	var orphans []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		if chainCfg.Delegators.Count > 0 && !withValidators[chainCfg.ID] {
+			orphans = append(orphans, fmt.Sprintf("chain %s: %d delegators for committee %d",
+				chainName, chainCfg.Delegators.Count, chainCfg.ID))
+		}
+		for _, ca := range chainCfg.Committees {
+			delegators := min(ca.RepeatedIdentityDelegatorCount, chainCfg.Delegators.Count) + ca.DelegatorCount
+			if delegators > 0 && !withValidators[ca.ID] {
+				orphans = append(orphans, fmt.Sprintf("chain %s: %d delegators for committee %d",
+					chainName, delegators, ca.ID))
+			}
+		}
+	}
+
+	if len(orphans) == 0 {
+		log.Info("every delegated committee has validators")
+		return nil
+	}
+	if *strict {
+		return fmt.Errorf("delegators staked for committees without validators: %s", strings.Join(orphans, ", "))
+	}
+	for _, orphan := range orphans {
+		log.Warn(orphan + ", which has no validators")
+	}
+	return nil
+}
+
+// validateStakeDistribution checks the stakeDistribution of every chain and draws its validators' stakes,
+// so the staked supply check and the genesis see the same ones. Committee-only validators stake
+// stakedAmount, or min without one
+func validateStakeDistribution(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		distribution := chainCfg.Validators.StakeDistribution
+		if distribution == nil {
+			continue
+		}
+		if err := distribution.check(); err != nil {
+			invalid = append(invalid, fmt.Sprintf("chain %s: %v", chainName, err))
+			continue
+		}
+		defaultStake := chainCfg.Validators.StakedAmount
+		if defaultStake == 0 {
+			defaultStake = distribution.Min
+		}
+		stakes := distribution.draw(chainCfg.Validators.Count, defaultStake, cfg.General.Seed, chainCfg.ID)
+		chainCfg.Validators.stakes = &stakes
+		minStake, maxStake, totalStake := stakeRange(stakes, chainCfg.Validators.Count)
+		log.Info("validator stakes", "chain", chainName, "kind", distribution.Kind, "min", minStake, "max", maxStake,
+			"total", totalStake)
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid stake distributions: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// stakeRange returns the lowest, highest and total stake of count validators
+func stakeRange(stakes NodeAmounts, count int) (lowest, highest, total uint64) {
+	for i := range count {
+		stake := stakes.At(i)
+		if i == 0 || stake < lowest {
+			lowest = stake
+		}
+		highest = max(highest, stake)
+		total += stake
+	}
+	return lowest, highest, total
+}
+
+// validateStakedSupply compares per chain the tokens staked by its validators and delegators (own and
+// committee-only) with the balances funded to its nodes and accounts, warning when more is staked than
+// funded or failing with -strict. Main accounts aren't counted
+func validateStakedSupply(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for name := range cfg.Chains {
+		chainNames = append(chainNames, name)
+	}
+	sort.Strings(chainNames)
+
+	var overstaked []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		validators := uint64(chainCfg.Validators.Count)
+		delegators := uint64(chainCfg.Delegators.Count)
+		for _, ca := range chainCfg.Committees {
+			validators += uint64(ca.ValidatorCount)
+			delegators += uint64(ca.DelegatorCount)
+		}
+		stakes := chainCfg.Validators.stakeAmounts()
+		staked := stakes.Total(chainCfg.Validators.Count) +
+			(validators-uint64(chainCfg.Validators.Count))*stakes.Default + delegators*chainCfg.Delegators.StakedAmount
+		// Committee-only validators and delegators are funded with the default amount
+		balances := chainCfg.Validators.Amount.Total(chainCfg.Validators.Count) +
+			(validators-uint64(chainCfg.Validators.Count))*chainCfg.Validators.Amount.Default +
+			chainCfg.Delegators.Amount.Total(chainCfg.Delegators.Count) +
+			(delegators-uint64(chainCfg.Delegators.Count))*chainCfg.Delegators.Amount.Default +
+			chainCfg.FullNodes.Amount.Total(chainCfg.FullNodes.Count) + chainCfg.Accounts.Amount.Total(chainCfg.Accounts.Count)
+		if staked > balances {
+			overstaked = append(overstaked, fmt.Sprintf("chain %s: staked %d exceeds balances %d", chainName, staked, balances))
+			continue
+		}
+		log.Info("staked supply", "chain", chainName, "staked", staked, "balances", balances)
+	}
+
+	if len(overstaked) == 0 {
+		return nil
+	}
+	if *strict {
+		return fmt.Errorf("staked exceeds funded balances: %s", strings.Join(overstaked, ", "))
+	}
+	for _, chain := range overstaked {
+		log.Warn(chain)
+	}
+	return nil
+}
+
+// validateAmounts checks every chain's per-node amounts fit its node counts: lists have one amount per node,
+// map indices are below the count and maps without a default fund every node. Committee-only validators and
+// delegators, and main accounts, are funded with the default amount, so their types need one
+func validateAmounts(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		committeeOnlyValidators, committeeOnlyDelegators := 0, 0
+		for _, ca := range chainCfg.Committees {
+			committeeOnlyValidators += ca.ValidatorCount
+			committeeOnlyDelegators += ca.DelegatorCount
+		}
+		for _, nodeType := range []struct {
+			name          string
+			amounts       NodeAmounts
+			count         int
+			committeeOnly int
+		}{
+			{"validators", chainCfg.Validators.Amount, chainCfg.Validators.Count, committeeOnlyValidators},
+			{"delegators", chainCfg.Delegators.Amount, chainCfg.Delegators.Count, committeeOnlyDelegators},
+			{"fullNodes", chainCfg.FullNodes.Amount, chainCfg.FullNodes.Count, 0},
+			{"accounts", chainCfg.Accounts.Amount, chainCfg.Accounts.Count, 0},
+		} {
+			if err := nodeType.amounts.check(nodeType.count); err != nil {
+				invalid = append(invalid, fmt.Sprintf("chain %s %s amount %v", chainName, nodeType.name, err))
+			} else if nodeType.committeeOnly > 0 && !nodeType.amounts.hasDefault {
+				invalid = append(invalid, fmt.Sprintf("chain %s %s amount needs a default for its %d committee-only %s, use a map with a default key",
+					chainName, nodeType.name, nodeType.committeeOnly, nodeType.name))
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid amounts: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// validateSlashing checks that every chain's effective slashing params are within range: percentages
+// 0-100 (maxSlashPerCommittee 1-100), nonSignWindow > 0 and maxNonSign < nonSignWindow, as a validator is only
+// slashed once it misses more than maxNonSign blocks of a window
+func validateSlashing(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		if chainCfg.Slashing == nil {
+			continue
+		}
+		params := genesisParams(chainCfg, protocolVersion(cfg.General)).Validator
+		chainInvalid := len(invalid)
+		percentage := func(name string, value uint64) {
+			if value > 100 {
+				invalid = append(invalid, fmt.Sprintf("chain %s %s must be 0-100, got %d", chainName, name, value))
+			}
+		}
+		percentage("doubleSignSlashPercentage", params.DoubleSignSlashPercentage)
+		percentage("nonSignSlashPercentage", params.NonSignSlashPercentage)
+		if params.MaxSlashPerCommittee == 0 || params.MaxSlashPerCommittee > 100 {
+			invalid = append(invalid, fmt.Sprintf("chain %s maxSlashPerCommittee must be 1-100, got %d", chainName, params.MaxSlashPerCommittee))
+		}
+		if params.NonSignWindow == 0 {
+			invalid = append(invalid, fmt.Sprintf("chain %s nonSignWindow must be > 0", chainName))
+		} else if params.MaxNonSign >= params.NonSignWindow {
+			invalid = append(invalid, fmt.Sprintf("chain %s maxNonSign (%d) must be less than nonSignWindow (%d)",
+				chainName, params.MaxNonSign, params.NonSignWindow))
+		}
+		if len(invalid) == chainInvalid {
+			log.Info("slashing", "chain", chainName, "double_sign_percent", params.DoubleSignSlashPercentage,
+				"non_sign_percent", params.NonSignSlashPercentage, "max_non_sign", params.MaxNonSign,
+				"non_sign_window", params.NonSignWindow, "max_slash_per_committee_percent", params.MaxSlashPerCommittee)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid slashing params: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// validateRewards checks that every chain's effective reward params are within range: percentages 0-100,
+// stakePercentForSubsidizedCommittee 1-100
+func validateRewards(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		if chainCfg.Rewards == nil {
+			continue
+		}
+		params := genesisParams(chainCfg, protocolVersion(cfg.General))
+		chainInvalid := len(invalid)
+		if params.Validator.DelegateRewardPercentage > 100 {
+			invalid = append(invalid, fmt.Sprintf("chain %s delegateRewardPercentage must be 0-100, got %d",
+				chainName, params.Validator.DelegateRewardPercentage))
+		}
+		if params.Governance.DaoRewardPercentage > 100 {
+			invalid = append(invalid, fmt.Sprintf("chain %s daoRewardPercentage must be 0-100, got %d",
+				chainName, params.Governance.DaoRewardPercentage))
+		}
+		if params.Validator.StakePercentForSubsidizedCommittee == 0 || params.Validator.StakePercentForSubsidizedCommittee > 100 {
+			invalid = append(invalid, fmt.Sprintf("chain %s stakePercentForSubsidizedCommittee must be 1-100, got %d",
+				chainName, params.Validator.StakePercentForSubsidizedCommittee))
+		}
+		if params.Validator.EarlyWithdrawalPenalty > 100 {
+			invalid = append(invalid, fmt.Sprintf("chain %s earlyWithdrawalPenalty must be 0-100, got %d",
+				chainName, params.Validator.EarlyWithdrawalPenalty))
+		}
+		if len(invalid) == chainInvalid {
+			log.Info("rewards", "chain", chainName, "delegate_percent", params.Validator.DelegateRewardPercentage,
+				"dao_percent", params.Governance.DaoRewardPercentage,
+				"stake_percent_for_subsidized_committee", params.Validator.StakePercentForSubsidizedCommittee,
+				"early_withdrawal_penalty_percent", params.Validator.EarlyWithdrawalPenalty)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid reward params: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// validateOrders checks the order param overrides of every chain are accepted by the node, which rejects
+// a zero buy deadline or lock order fee multiplier
+func validateOrders(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		if chainCfg.Orders == nil {
+			continue
+		}
+		params := genesisParams(chainCfg, protocolVersion(cfg.General))
+		chainInvalid := len(invalid)
+		if params.Validator.BuyDeadlineBlocks == 0 {
+			invalid = append(invalid, fmt.Sprintf("chain %s buyDeadlineBlocks must be at least 1", chainName))
+		}
+		if params.Validator.LockOrderFeeMultiplier == 0 {
+			invalid = append(invalid, fmt.Sprintf("chain %s lockOrderFeeMultiplier must be at least 1", chainName))
+		}
+		if len(invalid) == chainInvalid {
+			log.Info("orders", "chain", chainName, "buy_deadline_blocks", params.Validator.BuyDeadlineBlocks,
+				"lock_order_fee_multiplier", params.Validator.LockOrderFeeMultiplier,
+				"lock_order_min_fee", params.Fee.SendFee*params.Validator.LockOrderFeeMultiplier)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid order params: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// validateUnstaking checks the unstaking blocks of every chain are accepted by the node, which requires
+// delegators to unstake for at least defaultDelegateUnstakingBlocks blocks
+func validateUnstaking(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		unstaking, delegateUnstaking := effectiveUnstakingBlocks(chainCfg), effectiveDelegateUnstakingBlocks(chainCfg)
+		if delegateUnstaking < defaultDelegateUnstakingBlocks {
+			invalid = append(invalid, fmt.Sprintf("chain %s delegateUnstakingBlocks must be at least %d, got %d",
+				chainName, defaultDelegateUnstakingBlocks, delegateUnstaking))
+			continue
+		}
+		log.Info("unstaking", "chain", chainName, "unstaking_blocks", unstaking, "delegate_unstaking_blocks", delegateUnstaking)
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid unstaking params: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// validateSizes checks every chain can include a full-size tx: the block has room for the largest
+// mempool tx past its header, and the mempool can hold at least one of them
+func validateSizes(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		blockSize, maxTotalBytes := effectiveBlockSize(chainCfg), effectiveMaxTotalBytes(chainCfg)
+		maxTxSize := uint64(effectiveIndividualMaxTxSize(chainCfg))
+		chainInvalid := len(invalid)
+		if blockSize <= lib.MaxBlockHeaderSize {
+			invalid = append(invalid, fmt.Sprintf("chain %s blockSize (%d) leaves no room for txs past the %d byte block header",
+				chainName, blockSize, lib.MaxBlockHeaderSize))
+		} else if maxTxSize > blockSize-lib.MaxBlockHeaderSize {
+			invalid = append(invalid, fmt.Sprintf("chain %s individualMaxTxSize (%d) exceeds the %d bytes of txs a block holds (blockSize %d - %d byte header)",
+				chainName, maxTxSize, blockSize-lib.MaxBlockHeaderSize, blockSize, lib.MaxBlockHeaderSize))
+		}
+		if maxTotalBytes < maxTxSize {
+			invalid = append(invalid, fmt.Sprintf("chain %s maxTotalBytes (%d) is smaller than individualMaxTxSize (%d), the mempool can't hold a full-size tx",
+				chainName, maxTotalBytes, maxTxSize))
+		}
+		if len(invalid) == chainInvalid {
+			log.Info("block and mempool sizes", "chain", chainName, "block_size", blockSize,
+				"max_total_bytes", maxTotalBytes, "individual_max_tx_size", maxTxSize)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("inconsistent block and mempool sizes: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// validateRetired checks only nested chains are retired: a chain's certificate marks its committee as
+// retired on the root chain, which ignores the flag in its own certificates
+func validateRetired(cfg *AppConfig) error {
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	var invalid []string
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		if chainCfg.Retired == 0 {
+			continue
+		}
+		if chainCfg.RootChain == chainCfg.ID {
+			invalid = append(invalid, fmt.Sprintf("chain %s is a root chain, only nested chains can be retired", chainName))
+			continue
+		}
+		log.Info("retired chain", "chain", chainName, "retired", chainCfg.Retired, "root_chain", chainCfg.RootChain)
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid retired chains: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// validationCheck is a named config validation, run in order before generating files
+type validationCheck struct {
+	Name      string
+	Title     string
+	ErrPrefix string
+	Run       func(cfg *AppConfig) error
+}
+
+// validationChecks are all config validations in the order they must run. Borrowed validators are
+// resolved before the committee validations, which account for them
+var validationChecks = []validationCheck{
+	{"nodeCount", "Validating configuration...", "Configuration error", validateConfig},
+	{"borrowedValidators", "Validating borrowed validators...", "Configuration error", resolveBorrowedValidators},
+	{"passwordStrategy", "Validating password strategy...", "Configuration error", validatePasswordStrategy},
+	{"keystoreInclude", "Validating keystore node types...", "Configuration error", validateKeystoreInclude},
+	{"protocolVersion", "Validating protocol version...", "Configuration error", validateProtocolVersion},
+	{"concurrency", "Validating concurrency...", "Configuration error", validateConcurrency},
+	{"keyTypes", "Validating key types...", "Configuration error", validateKeyTypes},
+	{"idRanges", "Validating node id ranges...", "Node id range error", validateIDRanges},
+	{"rootChains", "Validating root chains...", "Root chain error", validateRootChains},
+	{"committeeAssignments", "Validating committee assignments...", "Committee assignment error", validateCommitteeAssignments},
+	{"fullNodePeers", "Validating full node peers...", "Full node peer error", validateFullNodePeers},
+	{"fullNodeTracks", "Validating full node tracked chains...", "Full node tracks error", validateFullNodeTracks},
+	{"maxCommittees", "Validating committees per validator...", "Committee assignment error", validateMaxCommittees},
+	{"committeeSize", "Validating committee sizes...", "Committee size error", validateCommitteeSize},
+	{"delegatorCommittees", "Validating delegator committees...", "Delegator committee error", validateDelegatorCommittees},
+	{"amounts", "Validating node amounts...", "Amount error", validateAmounts},
+	{"stakeDistribution", "Validating stake distributions...", "Stake distribution error", validateStakeDistribution},
+	{"stakedSupply", "Validating staked supply...", "Staked supply error", validateStakedSupply},
+	{"slashing", "Validating slashing params...", "Slashing params error", validateSlashing},
+	{"rewards", "Validating reward params...", "Reward params error", validateRewards},
+	{"orders", "Validating order params...", "Order params error", validateOrders},
+	{"unstaking", "Validating unstaking blocks...", "Unstaking params error", validateUnstaking},
+	{"sizes", "Validating block and mempool sizes...", "Size params error", validateSizes},
+	{"retired", "Validating retired chains...", "Retirement error", validateRetired},
+}
+
+// ValidationReport is the machine-readable result of -validateOnly
+type ValidationReport struct {
+	Config string             `json:"config"`
+	Valid  bool               `json:"valid"`
+	Nodes  int                `json:"nodes"`
+	Chains []ChainReport      `json:"chains"`
+	Checks []ValidationResult `json:"checks"`
+	Errors []string           `json:"errors"`
+}
+
+// ChainReport summarizes the node counts and committee assignments of a chain
+type ChainReport struct {
+	Name                       string               `json:"name"`
+	ID                         int                  `json:"id"`
+	RootChain                  int                  `json:"rootChain"`
+	Validators                 int                  `json:"validators"`
+	FullNodes                  int                  `json:"fullNodes"`
+	Delegators                 int                  `json:"delegators"`
+	RepeatedIdentityExpansions int                  `json:"repeatedIdentityExpansions"`
+	CommitteeOnlyValidators    int                  `json:"committeeOnlyValidators"`
+	Entries                    int                  `json:"entries"`
+	Committees                 []CommitteeReport    `json:"committees"`
+	BorrowedValidators         []BorrowedValidators `json:"borrowedValidators,omitempty"`
+}
+
+// CommitteeReport is a committee assignment of a chain
+type CommitteeReport struct {
+	ID                             int `json:"id"`
+	RepeatedIdentityValidatorCount int `json:"repeatedIdentityValidatorCount"`
+	RepeatedIdentityDelegatorCount int `json:"repeatedIdentityDelegatorCount"`
+	ValidatorCount                 int `json:"validatorCount"`
+	DelegatorCount                 int `json:"delegatorCount"`
+}
+
+// ValidationResult is the outcome of a single validation check
+type ValidationResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runValidationReport runs every validation check, without stopping at the first failure, and
+// summarizes the config and the results
+func runValidationReport(cfg *AppConfig, configName string) ValidationReport {
+	report := ValidationReport{
+		Config: configName,
+		Valid:  true,
+		Nodes:  cfg.Nodes.Count,
+		Chains: []ChainReport{},
+		Checks: []ValidationResult{},
+		Errors: []string{},
+	}
+
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	for _, chainName := range chainNames {
+		report.Chains = append(report.Chains, chainReport(chainName, cfg.Chains[chainName]))
+	}
+
+	for _, check := range validationChecks {
+		log.Info(check.Title, "check", check.Name)
+		result := ValidationResult{Name: check.Name, Passed: true}
+		if err := check.Run(cfg); err != nil {
+			log.Error(check.ErrPrefix, "check", check.Name, "error", err)
+			result.Passed = false
+			result.Error = err.Error()
+			report.Valid = false
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", check.Name, err))
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// chainReport summarizes the node counts and committee assignments of a chain
+func chainReport(chainName string, chainCfg *ChainConfig) ChainReport {
+	chain := ChainReport{
+		Name:               chainName,
+		ID:                 chainCfg.ID,
+		RootChain:          chainCfg.RootChain,
+		Validators:         chainCfg.Validators.Count,
+		FullNodes:          chainCfg.FullNodes.Count,
+		Delegators:         chainCfg.Delegators.Count,
+		Committees:         []CommitteeReport{},
+		BorrowedValidators: chainCfg.BorrowedValidators,
+	}
+	for _, ca := range chainCfg.Committees {
+		chain.RepeatedIdentityExpansions += ca.RepeatedIdentityValidatorCount
+		chain.CommitteeOnlyValidators += ca.ValidatorCount
+		chain.Committees = append(chain.Committees, CommitteeReport{
+			ID:                             ca.ID,
+			RepeatedIdentityValidatorCount: ca.RepeatedIdentityValidatorCount,
+			RepeatedIdentityDelegatorCount: ca.RepeatedIdentityDelegatorCount,
+			ValidatorCount:                 ca.ValidatorCount,
+			DelegatorCount:                 ca.DelegatorCount,
+		})
+	}
+	chain.Entries = chain.Validators + chain.FullNodes + chain.RepeatedIdentityExpansions + chain.CommitteeOnlyValidators
+	return chain
+}
+
+// countChecks are the validation checks -countOnly runs, the ones the node count breakdown depends on
+var countChecks = []string{"nodeCount", "borrowedValidators", "rootChains", "committeeAssignments"}
+
+// runCountReport runs the node count checks, without stopping at the first failure, and prints every
+// chain's node counts with the committees it expands into and the validators it borrows. It reports
+// whether the checks passed
+func runCountReport(cfg *AppConfig, configName string) bool {
+	passed := true
+	for _, check := range validationChecks {
+		if !slices.Contains(countChecks, check.Name) {
+			continue
+		}
+		log.Info(check.Title, "check", check.Name)
+		if err := check.Run(cfg); err != nil {
+			log.Error(check.ErrPrefix, "check", check.Name, "error", err)
+			passed = false
+		}
+	}
+
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	fmt.Printf("Node counts of config %s:\n", configName)
+	total := 0
+	for _, chainName := range chainNames {
+		chain := chainReport(chainName, cfg.Chains[chainName])
+		total += chain.Entries
+		fmt.Printf("  %s (id %d, root chain %d): %d validators + %d full nodes + %d repeatedIdentity expansions + %d committee-only validators = %d entries (+ %d delegators)\n",
+			chain.Name, chain.ID, chain.RootChain, chain.Validators, chain.FullNodes, chain.RepeatedIdentityExpansions,
+			chain.CommitteeOnlyValidators, chain.Entries, chain.Delegators)
+		for _, committee := range chain.Committees {
+			fmt.Printf("    -> committee %d: %d repeatedIdentity + %d committee-only validators, %d repeatedIdentity + %d committee-only delegators\n",
+				committee.ID, committee.RepeatedIdentityValidatorCount, committee.ValidatorCount,
+				committee.RepeatedIdentityDelegatorCount, committee.DelegatorCount)
+		}
+		for _, borrowed := range chain.BorrowedValidators {
+			fmt.Printf("    <- borrows %d validators from %s\n", borrowed.Count, borrowed.Chain)
+		}
+	}
+	fmt.Printf("Total entries: %d (nodes.count: %d)\n", total, cfg.Nodes.Count)
+	return passed
+}
+
+// EffectiveConfig is the fully resolved config printed by -printConfig, with every default applied
+type EffectiveConfig struct {
+	Config           string           `json:"config"`
+	Nodes            int              `json:"nodes"`
+	PasswordStrategy string           `json:"passwordStrategy"`
+	ProtocolVersion  string           `json:"protocolVersion"`
+	Chains           []EffectiveChain `json:"chains"`
+}
+
+// EffectiveChain is a chain's summary with the genesis params and node config it produces
+type EffectiveChain struct {
+	ChainReport
+	Params *fsm.Params `json:"params"`
+	// NodeConfig is the chain's config.json, its dialPeers depend on the generated identities and are left empty
+	NodeConfig *lib.Config `json:"nodeConfig"`
+}
+
+// effectiveConfig resolves the config the way the generator does, without generating any identity
+func effectiveConfig(cfg *AppConfig, configName string) EffectiveConfig {
+	effective := EffectiveConfig{
+		Config:           configName,
+		Nodes:            cfg.Nodes.Count,
+		PasswordStrategy: passwordStrategy(cfg.General),
+		ProtocolVersion:  protocolVersion(cfg.General),
+		Chains:           []EffectiveChain{},
+	}
+
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		effective.Chains = append(effective.Chains, EffectiveChain{
+			ChainReport: chainReport(chainName, chainCfg),
+			Params:      genesisParams(chainCfg, effective.ProtocolVersion),
+			NodeConfig:  chainTemplateConfig(chainCfg, nil),
+		})
+	}
+	return effective
+}
+
+// getChainIDs returns a slice of all chain IDs in the config
+func getChainIDs(cfg *AppConfig) []int {
+	ids := make([]int, 0, len(cfg.Chains))
+	for _, chainCfg := range cfg.Chains {
+		ids = append(ids, chainCfg.ID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func logData() {
+	var accounts, validators, delegators, fullNodes int32
+
+	go func() {
+		for nickname := range nickNames {
+			switch nickname {
+			case accountNick:
+				atomic.AddInt32(&accounts, 1)
+			case validatorNick:
+				atomic.AddInt32(&validators, 1)
+			case delegatorNick:
+				atomic.AddInt32(&delegators, 1)
+			case fullNodeNick:
+				atomic.AddInt32(&fullNodes, 1)
+			default:
+				log.Warn("unknown data type received", "nickname", nickname)
+			}
+		}
+	}()
 
 	go func() {
 		ticker := time.NewTicker(2 * time.Second)
 
 		for range ticker.C {
-			fmt.Printf("Accounts: %d, Validators: %d, Delegators: %d, FullNodes: %d\n",
-				atomic.LoadInt32(&accounts),
-				atomic.LoadInt32(&validators),
-				atomic.LoadInt32(&delegators),
-				atomic.LoadInt32(&fullNodes),
+			log.Info("generated keys",
+				"accounts", atomic.LoadInt32(&accounts),
+				"validators", atomic.LoadInt32(&validators),
+				"delegators", atomic.LoadInt32(&delegators),
+				"full_nodes", atomic.LoadInt32(&fullNodes),
 			)
 		}
 	}()
 }
 
-func mustCreateKey() crypto.PrivateKeyI {
-	pk, err := crypto.NewBLS12381PrivateKey()
-	if err != nil {
-		panic(err)
+// mustCreateKey creates a random key of keyType, or with a seed derives it from the seed and the node's global id.
+// Keys are derived from the id and never from the generation order, so the concurrent generation stays reproducible
+func mustCreateKey(seed string, nodeID int, keyType string) crypto.PrivateKeyI {
+	if seed == "" {
+		newKey := crypto.NewBLS12381PrivateKey
+		if keyType == keyTypeEd25519 {
+			newKey = crypto.NewEd25519PrivateKey
+		}
+		pk, err := newKey()
+		if err != nil {
+			panic(err)
+		}
+		return pk
+	}
+	if keyType == keyTypeEd25519 {
+		digest := sha256.Sum256(fmt.Appendf(nil, "%s/%d", seed, nodeID))
+		return crypto.BytesToED25519Private(ed25519.NewKeyFromSeed(digest[:]))
+	}
+	// hash with an increasing counter until the digest is a valid scalar of the curve
+	for counter := 0; ; counter++ {
+		digest := sha256.Sum256(fmt.Appendf(nil, "%s/%d/%d", seed, nodeID, counter))
+		if pk, err := crypto.BytesToBLS12381PrivateKey(digest[:]); err == nil {
+			return pk
+		}
 	}
-
-	return pk
 }
 
 // addAccounts concurrently creates keys and accounts
-func addAccounts(count int, amount uint64, wg *sync.WaitGroup, semaphoreChan chan struct{}, accountChan chan *fsm.Account) {
+func addAccounts(count int, amount NodeAmounts, wg *sync.WaitGroup, semaphoreChan chan struct{}, accountChan chan *fsm.Account) {
 	for i := range count {
 		wg.Add(1)
 		go func(i int) {
@@ -435,7 +2220,7 @@ func addAccounts(count int, amount uint64, wg *sync.WaitGroup, semaphoreChan cha
 
 			accountChan <- &fsm.Account{
 				Address: []byte(addrStr),
-				Amount:  amount,
+				Amount:  amount.At(i),
 			}
 			nickNames <- accountNick
 		}(i)
@@ -443,8 +2228,8 @@ func addAccounts(count int, amount uint64, wg *sync.WaitGroup, semaphoreChan cha
 }
 
 // addFullNodes concurrently creates full nodes (not staked, but with identities)
-func addFullNodes(count int, amount uint64, startIdx int, chainID int, rootChainID int,
-	netAddressSuffix string, identities *[]NodeIdentity, gsync *sync.Mutex, wg *sync.WaitGroup, semaphoreChan chan struct{},
+func addFullNodes(count int, amount NodeAmounts, startIdx int, chainID int, rootChainID int,
+	netAddressSuffix string, seed string, keyType string, identities *[]NodeIdentity, gsync *sync.Mutex, wg *sync.WaitGroup, semaphoreChan chan struct{},
 	accountChan chan *fsm.Account) {
 
 	for i := range count {
@@ -454,11 +2239,11 @@ func addFullNodes(count int, amount uint64, startIdx int, chainID int, rootChain
 			semaphoreChan <- struct{}{}
 			defer func() { <-semaphoreChan }()
 
-			pk := mustCreateKey()
+			pk := mustCreateKey(seed, startIdx+i, keyType)
 
 			accountChan <- &fsm.Account{
 				Address: pk.PublicKey().Address().Bytes(),
-				Amount:  amount,
+				Amount:  amount.At(i),
 			}
 
 			netAddress := fmt.Sprintf("tcp://node-%d%s", startIdx+i, netAddressSuffix)
@@ -488,9 +2273,9 @@ func addFullNodes(count int, amount uint64, startIdx int, chainID int, rootChain
 // addValidators concurrently creates validators and delegators
 // committeeAssignments maps validator index to additional committees they participate in
 // expandingCommittees maps validator index to committees that should create expanded entries (repeated identity)
-func addValidators(count int, isDelegate bool, startIdx int, stakedAmount uint64, amount uint64,
+func addValidators(count int, isDelegate bool, startIdx int, stakes NodeAmounts, amounts NodeAmounts,
 	chainID int, rootChainID int, committeeAssignments map[int][]uint64, expandingCommittees map[int]map[uint64]bool,
-	netAddressSuffix string, identities *[]NodeIdentity, gsync *sync.Mutex, wg *sync.WaitGroup,
+	netAddressSuffix string, seed string, keyType string, identities *[]NodeIdentity, gsync *sync.Mutex, wg *sync.WaitGroup,
 	semaphoreChan chan struct{}, accountChan chan *fsm.Account) {
 
 	nodeType := "validator"
@@ -505,8 +2290,6 @@ func addValidators(count int, isDelegate bool, startIdx int, stakedAmount uint64
 			semaphoreChan <- struct{}{}
 			defer func() { <-semaphoreChan }()
 
-			pk := mustCreateKey()
-
 			// Base committee is the chain's own ID
 			committees := []uint64{uint64(chainID)}
 
@@ -523,6 +2306,9 @@ func addValidators(count int, isDelegate bool, startIdx int, stakedAmount uint64
 				nodeID = startIdx + i // Validators count up: 1, 2, 3, ...
 			}
 
+			pk := mustCreateKey(seed, nodeID, keyType)
+			amount := amounts.At(i)
+
 			netAddress := fmt.Sprintf("tcp://node-%d%s", nodeID, netAddressSuffix)
 
 			accountChan <- &fsm.Account{
@@ -550,7 +2336,7 @@ func addValidators(count int, isDelegate bool, startIdx int, stakedAmount uint64
 				Committees:          committees,
 				ExpandingCommittees: identityExpandingCommittees,
 				PrivateKeyBytes:     pk.Bytes(),
-				StakedAmount:        stakedAmount,
+				StakedAmount:        stakes.At(i),
 				Amount:              amount,
 				IsDelegate:          isDelegate,
 				NetAddress:          netAddress,
@@ -574,8 +2360,8 @@ func addValidators(count int, isDelegate bool, startIdx int, stakedAmount uint64
 // Genesis validators: appear in ROOT chain's genesis with committees: [target_committee]
 // Accounts/Keystore: appear in TARGET chain (not root chain)
 // In ids.json, they have chainId = target committee (the committee they're staked for)
-func addCommitteeOnlyValidator(nodeID int, stakedAmount uint64, amount uint64,
-	chainID int, rootChainID int, targetCommittee uint64, netAddressSuffix string,
+func addCommitteeOnlyValidator(nodeID int, stakedAmount uint64, amount uint64, crossChainAmount uint64,
+	chainID int, rootChainID int, targetCommittee uint64, netAddressSuffix string, seed string, keyType string,
 	identities *[]NodeIdentity, gsync *sync.Mutex, wg *sync.WaitGroup,
 	semaphoreChan chan struct{}, accountChan chan *fsm.Account) {
 
@@ -583,7 +2369,7 @@ func addCommitteeOnlyValidator(nodeID int, stakedAmount uint64, amount uint64,
 		semaphoreChan <- struct{}{}
 		defer func() { <-semaphoreChan }()
 
-		pk := mustCreateKey()
+		pk := mustCreateKey(seed, nodeID, keyType)
 
 		// Committee is ONLY the target committee (not the chain's own committee)
 		committees := []uint64{targetCommittee}
@@ -607,7 +2393,7 @@ func addCommitteeOnlyValidator(nodeID int, stakedAmount uint64, amount uint64,
 			ExpandingCommittees: nil, // No expanding
 			PrivateKeyBytes:     pk.Bytes(),
 			StakedAmount:        stakedAmount,
-			Amount:              amount,
+			Amount:              crossChainAmount, // Account balance in the target chain
 			IsDelegate:          false,
 			NetAddress:          netAddress,
 			GenesisChainID:      chainID, // Genesis validators in ROOT chain
@@ -625,8 +2411,8 @@ func addCommitteeOnlyValidator(nodeID int, stakedAmount uint64, amount uint64,
 // Genesis validators: appear in ROOT chain's genesis with committees: [target_committee]
 // Accounts/Keystore: appear in TARGET chain (not root chain)
 // In ids.json (if included), they would have chainId = target committee
-func addCommitteeOnlyDelegator(nodeID int, stakedAmount uint64, amount uint64,
-	chainID int, rootChainID int, targetCommittee uint64, netAddressSuffix string,
+func addCommitteeOnlyDelegator(nodeID int, stakedAmount uint64, amount uint64, crossChainAmount uint64,
+	chainID int, rootChainID int, targetCommittee uint64, netAddressSuffix string, seed string, keyType string,
 	identities *[]NodeIdentity, gsync *sync.Mutex, wg *sync.WaitGroup,
 	semaphoreChan chan struct{}, accountChan chan *fsm.Account) {
 
@@ -634,7 +2420,7 @@ func addCommitteeOnlyDelegator(nodeID int, stakedAmount uint64, amount uint64,
 		semaphoreChan <- struct{}{}
 		defer func() { <-semaphoreChan }()
 
-		pk := mustCreateKey()
+		pk := mustCreateKey(seed, nodeID, keyType)
 
 		// Committee is ONLY the target committee (not the chain's own committee)
 		committees := []uint64{targetCommittee}
@@ -658,7 +2444,7 @@ func addCommitteeOnlyDelegator(nodeID int, stakedAmount uint64, amount uint64,
 			ExpandingCommittees: nil, // No expanding
 			PrivateKeyBytes:     pk.Bytes(),
 			StakedAmount:        stakedAmount,
-			Amount:              amount,
+			Amount:              crossChainAmount, // Account balance in the target chain
 			IsDelegate:          true,
 			NetAddress:          netAddress,
 			GenesisChainID:      chainID, // Genesis validators in ROOT chain
@@ -693,32 +2479,212 @@ func mustDeleteInDirectory(dir string) {
 	}
 }
 
-func mustSaveAsJSON(filename string, data any) {
-	file, err := os.Create(filename)
-	if err != nil {
+func mustSaveAsJSON(sink outputSink, name string, data any) {
+	mustWriteFile(sink, name, mustEncodeJSON(data))
+}
+
+// Summary is summary.json, the node counts of a generation for automation to assert on without parsing the
+// other artifacts. Fields are only ever added
+type Summary struct {
+	Config       string         `json:"config"`
+	GeneratedAt  time.Time      `json:"generatedAt"`
+	Nodes        int            `json:"nodes"`     // nodes.count
+	BaseNodes    int            `json:"baseNodes"` // generated identities, before the repeatedIdentity expansions
+	IdsEntries   int            `json:"idsEntries"`
+	MainAccounts int            `json:"mainAccounts"`
+	Chains       []SummaryChain `json:"chains"`
+}
+
+// SummaryChain is the node counts of a chain in summary.json, the configured ones of -validateOnly's report
+// and the ones generated
+type SummaryChain struct {
+	ChainReport
+	Accounts   int `json:"accounts"`
+	IdsEntries int `json:"idsEntries"` // ids.json entries with the chain's id
+	// RootChainNodeAssignments is the number of ids.json entries using each of the chain's validators as
+	// rootChainNode, by node id. Only root chains have entries
+	RootChainNodeAssignments map[int]int `json:"rootChainNodeAssignments"`
+	// StakeMin, StakeMax and StakeTotal are the genesis stakes of the chain's validators, committee-only
+	// validators left out
+	StakeMin   uint64 `json:"stakeMin"`
+	StakeMax   uint64 `json:"stakeMax"`
+	StakeTotal uint64 `json:"stakeTotal"`
+}
+
+// newSummary builds summary.json from the config and the generated ids.json, chains sorted by name
+func newSummary(cfg *AppConfig, configName string, generatedAt time.Time, idsFile *IdsFile, baseNodes int,
+	rootChainNodeAssignments map[int]int) Summary {
+	summary := Summary{
+		Config:       configName,
+		GeneratedAt:  generatedAt.UTC(),
+		Nodes:        cfg.Nodes.Count,
+		BaseNodes:    baseNodes,
+		IdsEntries:   len(idsFile.Keys),
+		MainAccounts: len(idsFile.MainAccounts),
+		Chains:       []SummaryChain{},
+	}
+	idsEntries := make(map[int]int)
+	for _, identity := range idsFile.Keys {
+		idsEntries[identity.ChainID]++
+	}
+	assignments := make(map[int]map[int]int)
+	for id, count := range rootChainNodeAssignments {
+		chainID := idsFile.Keys[fmt.Sprintf("node-%d", id)].ChainID
+		if assignments[chainID] == nil {
+			assignments[chainID] = make(map[int]int)
+		}
+		assignments[chainID][id] = count
+	}
+	chainNames := make([]string, 0, len(cfg.Chains))
+	for chainName := range cfg.Chains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		chainAssignments := assignments[chainCfg.ID]
+		if chainAssignments == nil {
+			chainAssignments = map[int]int{}
+		}
+		stakeMin, stakeMax, stakeTotal := stakeRange(chainCfg.Validators.stakeAmounts(), chainCfg.Validators.Count)
+		summary.Chains = append(summary.Chains, SummaryChain{
+			ChainReport:              chainReport(chainName, chainCfg),
+			Accounts:                 chainCfg.Accounts.Count,
+			IdsEntries:               idsEntries[chainCfg.ID],
+			RootChainNodeAssignments: chainAssignments,
+			StakeMin:                 stakeMin,
+			StakeMax:                 stakeMax,
+			StakeTotal:               stakeTotal,
+		})
+	}
+	return summary
+}
+
+// metricsLabelEscaper escapes a Prometheus label value
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsText encodes the counts of a summary in the Prometheus text format, every count a gauge labelled
+// with the config, and the chain counts also with the chain's name, id and root chain
+func metricsText(summary Summary) []byte {
+	var buf bytes.Buffer
+	config := fmt.Sprintf(`config="%s"`, metricsLabelEscaper.Replace(summary.Config))
+	gauge := func(name, help string, samples func(sample func(labels string, value int64))) {
+		fmt.Fprintf(&buf, "# HELP canopy_genesis_%s %s\n# TYPE canopy_genesis_%s gauge\n", name, help, name)
+		samples(func(labels string, value int64) {
+			fmt.Fprintf(&buf, "canopy_genesis_%s{%s} %d\n", name, labels, value)
+		})
+	}
+	total := func(name, help string, value int64) {
+		gauge(name, help, func(sample func(string, int64)) { sample(config, value) })
+	}
+	perChain := func(name, help string, value func(chain SummaryChain) int) {
+		gauge(name, help, func(sample func(string, int64)) {
+			for _, chain := range summary.Chains {
+				sample(fmt.Sprintf(`%s,chain="%s",chain_id="%d",root_chain="%d"`, config,
+					metricsLabelEscaper.Replace(chain.Name), chain.ID, chain.RootChain), int64(value(chain)))
+			}
+		})
+	}
+	total("generated_timestamp_seconds", "Unix time the generation started.", summary.GeneratedAt.Unix())
+	total("nodes", "Configured nodes.count.", int64(summary.Nodes))
+	total("base_nodes", "Generated node identities, before the repeatedIdentity expansions.", int64(summary.BaseNodes))
+	total("ids_entries", "Entries of ids.json.", int64(summary.IdsEntries))
+	total("main_accounts", "Main accounts of accounts.yml.", int64(summary.MainAccounts))
+	perChain("validators", "Validators of the chain.", func(c SummaryChain) int { return c.Validators })
+	perChain("full_nodes", "Full nodes of the chain.", func(c SummaryChain) int { return c.FullNodes })
+	perChain("delegators", "Delegators of the chain.", func(c SummaryChain) int { return c.Delegators })
+	perChain("accounts", "Genesis accounts of the chain.", func(c SummaryChain) int { return c.Accounts })
+	perChain("repeated_identity_expansions", "ids.json entries the chain's repeatedIdentity validators add to other chains.",
+		func(c SummaryChain) int { return c.RepeatedIdentityExpansions })
+	perChain("committee_only_validators", "Validators the chain adds staked only for other committees.",
+		func(c SummaryChain) int { return c.CommitteeOnlyValidators })
+	perChain("entries", "ids.json entries the chain's config creates on any chain.", func(c SummaryChain) int { return c.Entries })
+	perChain("chain_ids_entries", "ids.json entries with the chain's id.", func(c SummaryChain) int { return c.IdsEntries })
+	perChain("root_chain_node_assignments", "ids.json entries using one of the chain's validators as rootChainNode.",
+		func(c SummaryChain) int {
+			assigned := 0
+			for _, count := range c.RootChainNodeAssignments {
+				assigned += count
+			}
+			return assigned
+		})
+	return buf.Bytes()
+}
+
+// writeMetricsFile writes the metrics of a summary to path through a temporary file renamed into place, so a
+// textfile collector never reads a partial file
+func writeMetricsFile(path string, summary Summary) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, metricsText(summary), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// idsCSVHeader are the columns of ids.csv
+var idsCSVHeader = []string{"id", "chainId", "rootChainId", "rootChainNode", "peerNode", "nodeType", "address", "committees"}
+
+// idsCSV encodes the ids.json entries as CSV rows sorted by node id, unset node references are left
+// empty and committees are separated by semicolons
+func idsCSV(idsFile *IdsFile) []byte {
+	identities := make([]NodeIdentity, 0, len(idsFile.Keys))
+	for _, identity := range idsFile.Keys {
+		identities = append(identities, identity)
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+
+	nodeRef := func(id *int) string {
+		if id == nil {
+			return ""
+		}
+		return fmt.Sprint(*id)
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	rows := [][]string{idsCSVHeader}
+	for _, identity := range identities {
+		committees := make([]string, len(identity.Committees))
+		for i, committee := range identity.Committees {
+			committees[i] = fmt.Sprint(committee)
+		}
+		rows = append(rows, []string{
+			fmt.Sprint(identity.ID),
+			fmt.Sprint(identity.ChainID),
+			fmt.Sprint(identity.RootChainID),
+			nodeRef(identity.RootChainNode),
+			nodeRef(identity.PeerNode),
+			identity.NodeType,
+			identity.Address,
+			strings.Join(committees, ";"),
+		})
+	}
+	if err := writer.WriteAll(rows); err != nil {
 		panic(err)
 	}
-	defer file.Close()
+	return buf.Bytes()
+}
 
-	encoder := json.NewEncoder(file)
+// mustEncodeJSON encodes data as indented JSON
+func mustEncodeJSON(data any) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ")
 
-	err = encoder.Encode(data)
+	err := encoder.Encode(data)
 	if err != nil {
 		panic(err)
 	}
+	return buf.Bytes()
 }
 
 // writeGenesisFromIdentities writes genesis.json for a specific chain using identities
 // For validators from other chains (cross-chain), only include this chain's committee
-func writeGenesisFromIdentities(chainDir string, chainID int, rootChainID int, validators []NodeIdentity, accountsPath string, maxCommitteeSize int, blockSize uint64, poolAmount uint64) {
-	genesisFile, err := os.Create(filepath.Join(chainDir, "genesis.json"))
-	if err != nil {
-		panic(err)
-	}
-	defer genesisFile.Close()
-
-	writer := jwriter.NewStreamingWriter(genesisFile, 1024)
+// The supply is the one canopy computes on import from the accounts (summing to accountsTotal), pools and
+// validators, written so supply invariants can be asserted without summing the genesis by hand
+func writeGenesisFromIdentities(genesisFile io.Writer, chainID int, rootChainID int, validators []NodeIdentity, rawAccounts []byte,
+	accountsTotal uint64, params *fsm.Params, poolAmount uint64, writerBuffer int) *fsm.Supply {
+	writer := jwriter.NewStreamingWriter(genesisFile, writerBuffer)
+	supply := &fsm.Supply{Total: accountsTotal}
 
 	obj := writer.Object()
 	obj.Name("time").String("2024-12-14 20:10:52")
@@ -770,93 +2736,55 @@ func writeGenesisFromIdentities(chainDir string, chainID int, rootChainID int, v
 		validatorObj.Name("output").String(hex.EncodeToString(addressBytes))
 		validatorObj.Name("delegate").Bool(v.IsDelegate)
 		validatorObj.End()
+		addSupplyStake(supply, v.StakedAmount, committeesForGenesis, v.IsDelegate)
 	}
 	arr.End()
 
-	rawAccounts, err := os.ReadFile(accountsPath)
-	if err != nil {
-		panic(err)
-	}
 	obj.Name("accounts").Raw(rawAccounts)
 
-	remainingFields := map[string]interface{}{
-		"params": &fsm.Params{
-			Consensus: &fsm.ConsensusParams{
-				BlockSize:       blockSize,
-				ProtocolVersion: "1/0",
-				RootChainId:     uint64(rootChainID),
-				Retired:         0,
-			},
-			Validator: &fsm.ValidatorParams{
-				UnstakingBlocks:                    2,
-				MaxPauseBlocks:                     4380,
-				DoubleSignSlashPercentage:          10,
-				NonSignSlashPercentage:             1,
-				MaxNonSign:                         4,
-				NonSignWindow:                      10,
-				MaxCommittees:                      15,
-				MaxCommitteeSize:                   uint64(maxCommitteeSize),
-				EarlyWithdrawalPenalty:             20,
-				DelegateUnstakingBlocks:            2,
-				MinimumOrderSize:                   1000,
-				StakePercentForSubsidizedCommittee: 33,
-				MaxSlashPerCommittee:               15,
-				DelegateRewardPercentage:           10,
-				BuyDeadlineBlocks:                  15,
-				LockOrderFeeMultiplier:             2,
-			},
-			Fee: &fsm.FeeParams{
-				SendFee:            10000,
-				StakeFee:           10000,
-				EditStakeFee:       10000,
-				UnstakeFee:         10000,
-				PauseFee:           10000,
-				UnpauseFee:         10000,
-				ChangeParameterFee: 10000,
-				DaoTransferFee:     10000,
-				SubsidyFee:         10000,
-				CreateOrderFee:     10000,
-				EditOrderFee:       10000,
-				DeleteOrderFee:     10000,
-			},
-			Governance: &fsm.GovernanceParams{
-				DaoRewardPercentage: 10,
-			},
-		},
-		"pools": func() []*fsm.Pool {
-			// collect distinct committee IDs from all validators
-			seen := make(map[uint64]bool)
-			var committeeIDs []uint64
-			for _, v := range validators {
-				for _, c := range v.Committees {
-					if !seen[c] {
-						seen[c] = true
-						committeeIDs = append(committeeIDs, c)
-					}
+	pools := func() []*fsm.Pool {
+		// collect distinct committee IDs from all validators
+		seen := make(map[uint64]bool)
+		var committeeIDs []uint64
+		for _, v := range validators {
+			for _, c := range v.Committees {
+				if !seen[c] {
+					seen[c] = true
+					committeeIDs = append(committeeIDs, c)
 				}
 			}
-			// add root chain if it exists and not already seen
-			if chainID != rootChainID && !seen[uint64(rootChainID)] {
-				seen[uint64(rootChainID)] = true
-				committeeIDs = append(committeeIDs, uint64(rootChainID))
-			}
-			// create a pool for each distinct committee
-			pools := make([]*fsm.Pool, 0, len(committeeIDs))
-			for _, c := range committeeIDs {
-				pools = append(pools, &fsm.Pool{
-					Id:              c + fsm.LiquidityPoolAddend,
-					Amount:          poolAmount,
-					Points:          []*lib.PoolPoints{},
-					TotalPoolPoints: 0,
-				})
-			}
-			return pools
-		}(),
+		}
+		// add root chain if it exists and not already seen
+		if chainID != rootChainID && !seen[uint64(rootChainID)] {
+			seen[uint64(rootChainID)] = true
+			committeeIDs = append(committeeIDs, uint64(rootChainID))
+		}
+		// create a pool for each distinct committee
+		pools := make([]*fsm.Pool, 0, len(committeeIDs))
+		for _, c := range committeeIDs {
+			pools = append(pools, &fsm.Pool{
+				Id:              c + fsm.LiquidityPoolAddend,
+				Amount:          poolAmount,
+				Points:          []*lib.PoolPoints{},
+				TotalPoolPoints: 0,
+			})
+		}
+		return pools
+	}()
+	for _, pool := range pools {
+		supply.Total += pool.Amount
+	}
+
+	remainingFields := map[string]interface{}{
+		"params": params,
+		"pools":  pools,
+		"supply": supply,
 	}
 
-	for key, value := range remainingFields {
+	// Write the fields in key order, map iteration order would change genesis.json (and its hash) between runs
+	for _, key := range slices.Sorted(maps.Keys(remainingFields)) {
 		obj.Name(key)
-		data, err := json.Marshal(value)
+		data, err := json.Marshal(remainingFields[key])
 		if err != nil {
 			panic(err)
 		}
@@ -868,6 +2796,210 @@ func writeGenesisFromIdentities(chainDir string, chainID int, rootChainID int, v
 	if err := writer.Flush(); err != nil {
 		panic(err)
 	}
+	return supply
+}
+
+// addSupplyStake adds a validator's stake to a genesis supply like canopy's SetValidators: to the total and
+// staked supply, and to the committee pools of every committee it is staked for, delegators also to the
+// delegated only supply and pools. Committee pools are kept sorted by id
+func addSupplyStake(supply *fsm.Supply, amount uint64, committees []uint64, delegate bool) {
+	supply.Total += amount
+	supply.Staked += amount
+	if delegate {
+		supply.DelegatedOnly += amount
+	}
+	for _, committee := range committees {
+		supply.CommitteeStaked = addToSupplyPool(supply.CommitteeStaked, committee, amount)
+		if delegate {
+			supply.CommitteeDelegatedOnly = addToSupplyPool(supply.CommitteeDelegatedOnly, committee, amount)
+		}
+	}
+}
+
+// addToSupplyPool adds an amount to the pool of a committee, inserting the pool in id order when missing
+func addToSupplyPool(pools []*fsm.Pool, committee uint64, amount uint64) []*fsm.Pool {
+	i, found := slices.BinarySearchFunc(pools, committee, func(pool *fsm.Pool, id uint64) int {
+		return cmp.Compare(pool.Id, id)
+	})
+	if !found {
+		pools = slices.Insert(pools, i, &fsm.Pool{Id: committee})
+	}
+	pools[i].Amount += amount
+	return pools
+}
+
+// genesisParams builds the genesis params for a chain, applying defaults for unset optional fields
+func genesisParams(chainCfg *ChainConfig, protocolVersion string) *fsm.Params {
+	maxCommitteeSize := effectiveMaxCommitteeSize(chainCfg)
+	blockSize := effectiveBlockSize(chainCfg)
+	maxCommittees := effectiveMaxCommittees(chainCfg)
+	params := &fsm.Params{
+		Consensus: &fsm.ConsensusParams{
+			BlockSize:       blockSize,
+			ProtocolVersion: protocolVersion,
+			RootChainId:     uint64(chainCfg.RootChain),
+			Retired:         chainCfg.Retired,
+		},
+		Validator: &fsm.ValidatorParams{
+			UnstakingBlocks:                    effectiveUnstakingBlocks(chainCfg),
+			MaxPauseBlocks:                     4380,
+			DoubleSignSlashPercentage:          10,
+			NonSignSlashPercentage:             1,
+			MaxNonSign:                         4,
+			NonSignWindow:                      10,
+			MaxCommittees:                      uint64(maxCommittees),
+			MaxCommitteeSize:                   uint64(maxCommitteeSize),
+			EarlyWithdrawalPenalty:             20,
+			DelegateUnstakingBlocks:            effectiveDelegateUnstakingBlocks(chainCfg),
+			MinimumOrderSize:                   1000,
+			StakePercentForSubsidizedCommittee: 33,
+			MaxSlashPerCommittee:               15,
+			DelegateRewardPercentage:           10,
+			BuyDeadlineBlocks:                  15,
+			LockOrderFeeMultiplier:             2,
+		},
+		Fee: &fsm.FeeParams{
+			SendFee:            10000,
+			StakeFee:           10000,
+			EditStakeFee:       10000,
+			UnstakeFee:         10000,
+			PauseFee:           10000,
+			UnpauseFee:         10000,
+			ChangeParameterFee: 10000,
+			DaoTransferFee:     10000,
+			SubsidyFee:         10000,
+			CreateOrderFee:     10000,
+			EditOrderFee:       10000,
+			DeleteOrderFee:     10000,
+		},
+		Governance: &fsm.GovernanceParams{
+			DaoRewardPercentage: 10,
+		},
+	}
+	applySlashing(params.Validator, chainCfg.Slashing)
+	applyRewards(params, chainCfg.Rewards)
+	applyOrders(params.Validator, chainCfg.Orders)
+	return params
+}
+
+// applySlashing overrides the validator slashing params with the ones set in the chain config
+func applySlashing(params *fsm.ValidatorParams, slashing *SlashingConfig) {
+	if slashing == nil {
+		return
+	}
+	override := func(dst *uint64, src *uint64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	override(&params.DoubleSignSlashPercentage, slashing.DoubleSignSlashPercentage)
+	override(&params.NonSignSlashPercentage, slashing.NonSignSlashPercentage)
+	override(&params.MaxNonSign, slashing.MaxNonSign)
+	override(&params.NonSignWindow, slashing.NonSignWindow)
+	override(&params.MaxSlashPerCommittee, slashing.MaxSlashPerCommittee)
+}
+
+// applyRewards overrides the validator and governance reward params with the ones set in the chain config
+func applyRewards(params *fsm.Params, rewards *RewardsConfig) {
+	if rewards == nil {
+		return
+	}
+	override := func(dst *uint64, src *uint64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	override(&params.Validator.DelegateRewardPercentage, rewards.DelegateRewardPercentage)
+	override(&params.Governance.DaoRewardPercentage, rewards.DaoRewardPercentage)
+	override(&params.Validator.StakePercentForSubsidizedCommittee, rewards.StakePercentForSubsidizedCommittee)
+	override(&params.Validator.EarlyWithdrawalPenalty, rewards.EarlyWithdrawalPenalty)
+}
+
+// applyOrders overrides the validator order params with the ones set in the chain config
+func applyOrders(params *fsm.ValidatorParams, orders *OrdersConfig) {
+	if orders == nil {
+		return
+	}
+	override := func(dst *uint64, src *uint64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	override(&params.BuyDeadlineBlocks, orders.BuyDeadlineBlocks)
+	override(&params.LockOrderFeeMultiplier, orders.LockOrderFeeMultiplier)
+}
+
+// effectiveMaxCommittees returns the chain's MaxCommittees param, applying the default when unset
+func effectiveMaxCommittees(chainCfg *ChainConfig) int {
+	if chainCfg.MaxCommittees == 0 {
+		return defaultMaxCommittees
+	}
+	return chainCfg.MaxCommittees
+}
+
+// effectiveMaxCommitteeSize returns the chain's MaxCommitteeSize param, applying the default when unset
+func effectiveMaxCommitteeSize(chainCfg *ChainConfig) int {
+	if chainCfg.MaxCommitteeSize == 0 {
+		return defaultMaxCommitteeSize
+	}
+	return chainCfg.MaxCommitteeSize
+}
+
+// effectiveUnstakingBlocks returns the chain's UnstakingBlocks param, applying the default when unset
+func effectiveUnstakingBlocks(chainCfg *ChainConfig) uint64 {
+	if chainCfg.UnstakingBlocks == 0 {
+		return defaultUnstakingBlocks
+	}
+	return chainCfg.UnstakingBlocks
+}
+
+// effectiveDelegateUnstakingBlocks returns the chain's DelegateUnstakingBlocks param, applying the default when unset
+func effectiveDelegateUnstakingBlocks(chainCfg *ChainConfig) uint64 {
+	if chainCfg.DelegateUnstakingBlocks == 0 {
+		return defaultDelegateUnstakingBlocks
+	}
+	return chainCfg.DelegateUnstakingBlocks
+}
+
+func effectiveBlockSize(chainCfg *ChainConfig) uint64 {
+	if chainCfg.BlockSize == 0 {
+		return defaultBlockSize
+	}
+	return chainCfg.BlockSize
+}
+
+func effectiveMaxTotalBytes(chainCfg *ChainConfig) uint64 {
+	if chainCfg.MaxTotalBytes == 0 {
+		return defaultMaxTotalBytes
+	}
+	return chainCfg.MaxTotalBytes
+}
+
+func effectiveIndividualMaxTxSize(chainCfg *ChainConfig) uint32 {
+	if chainCfg.IndividualMaxTxSize == 0 {
+		return defaultIndividualMaxTxSize
+	}
+	return chainCfg.IndividualMaxTxSize
+}
+
+// chainTemplateConfig returns the node config of a chain, applying the defaults of the unset fields
+func chainTemplateConfig(chainCfg *ChainConfig, dialPeers []string) *lib.Config {
+	return createTemplateConfig(
+		chainCfg.ID,
+		chainCfg.RootChain,
+		chainCfg.SleepUntil,
+		chainCfg.MinimumPeersToStart,
+		chainCfg.MaxInbound,
+		chainCfg.MaxOutbound,
+		chainCfg.InMemory,
+		chainCfg.GossipThreshold,
+		dialPeers,
+		chainCfg.MaxTransactionCount,
+		chainCfg.DropPercentage,
+		chainCfg.LazyMempoolCheckFrequencyS,
+		effectiveMaxTotalBytes(chainCfg),
+		effectiveIndividualMaxTxSize(chainCfg),
+	)
 }
 
 func createTemplateConfig(
@@ -883,7 +3015,8 @@ func createTemplateConfig(
 	maxTransactionCount uint32,
 	dropPercentage int,
 	lazyMempoolCheckFrequencyS int,
-	maxTotalBytes uint64) *lib.Config {
+	maxTotalBytes uint64,
+	individualMaxTxSize uint32) *lib.Config {
 	var rootChain []lib.RootChain
 
 	if chainID == rootChainID {
@@ -981,7 +3114,7 @@ func createTemplateConfig(
 		MempoolConfig: lib.MempoolConfig{
 			MaxTotalBytes:              maxTotalBytes,
 			MaxTransactionCount:        maxTransactionCount,
-			IndividualMaxTxSize:        4000,
+			IndividualMaxTxSize:        individualMaxTxSize,
 			DropPercentage:             dropPercentage,
 			LazyMempoolCheckFrequencyS: lazyMempoolCheckFrequencyS,
 		},
@@ -996,9 +3129,9 @@ func createTemplateConfig(
 // Returns the identities and accounts for this chain
 // startIdx is for validators/fullnodes (positive IDs), delegatorStartIdx is for delegators (negative IDs)
 func generateChainIdentities(chainName string, chainCfg *ChainConfig, startIdx int, delegatorStartIdx int, buffer int, netAddressSuffix string,
-	semaphoreChan chan struct{}) ([]NodeIdentity, []*fsm.Account) {
+	seed string, semaphoreChan chan struct{}) ([]NodeIdentity, []*fsm.Account) {
 
-	fmt.Printf("Generating identities for chain: %s (ID: %d, RootChain: %d)\n", chainName, chainCfg.ID, chainCfg.RootChain)
+	log.Info("generating identities", "chain", chainName, "chain_id", chainCfg.ID, "root_chain", chainCfg.RootChain)
 
 	chainIdentities := make([]NodeIdentity, 0, chainCfg.Validators.Count+chainCfg.Delegators.Count+chainCfg.FullNodes.Count)
 	var chainSync sync.Mutex
@@ -1007,9 +3140,11 @@ func generateChainIdentities(chainName string, chainCfg *ChainConfig, startIdx i
 	accountChan := make(chan *fsm.Account, buffer)
 	accounts := make([]*fsm.Account, 0, chainCfg.Delegators.Count+chainCfg.Validators.Count+chainCfg.FullNodes.Count+chainCfg.Accounts.Count)
 	var accountSync sync.Mutex
+	collected := make(chan struct{})
 
 	// Collect accounts from channel
 	go func() {
+		defer close(collected)
 		for acc := range accountChan {
 			accountSync.Lock()
 			accounts = append(accounts, acc)
@@ -1032,6 +3167,13 @@ func generateChainIdentities(chainName string, chainCfg *ChainConfig, startIdx i
 		}
 	}
 
+	// Lent validators are staked for the borrowing chain's committee without expanding into it
+	for _, lent := range chainCfg.lent {
+		for i := 0; i < lent.Count && i < chainCfg.Validators.Count; i++ {
+			validatorCommitteeAssignments[i] = append(validatorCommitteeAssignments[i], uint64(lent.ID))
+		}
+	}
+
 	// Build committee assignments for regular delegators (RepeatedIdentity)
 	delegatorCommitteeAssignments := make(map[int][]uint64)
 	delegatorExpandingCommittees := make(map[int]map[uint64]bool)
@@ -1042,86 +3184,501 @@ func generateChainIdentities(chainName string, chainCfg *ChainConfig, startIdx i
 			if delegatorExpandingCommittees[i] == nil {
 				delegatorExpandingCommittees[i] = make(map[uint64]bool)
 			}
-			delegatorExpandingCommittees[i][uint64(ca.ID)] = true
+			delegatorExpandingCommittees[i][uint64(ca.ID)] = true
+		}
+	}
+
+	// Calculate how many committee-only validators/delegators to create
+	totalCommitteeOnlyValidators := 0
+	totalCommitteeOnlyDelegators := 0
+	for _, ca := range chainCfg.Committees {
+		totalCommitteeOnlyValidators += ca.ValidatorCount
+		totalCommitteeOnlyDelegators += ca.DelegatorCount
+	}
+
+	// Assign unique idx within this chain
+	// Validators get positive IDs starting from startIdx
+	validatorStartIdx := startIdx
+	// Committee-only validators get positive IDs right after regular validators
+	committeeOnlyValidatorStartIdx := validatorStartIdx + chainCfg.Validators.Count
+	// Full nodes get positive IDs right after committee-only validators
+	fullNodeStartIdx := committeeOnlyValidatorStartIdx + totalCommitteeOnlyValidators
+	// Delegators get negative IDs (passed in from caller)
+
+	validatorKeyType, delegatorKeyType, fullNodeKeyType := chainCfg.keyTypes()
+
+	// Create regular validators (staked for their own chain's committee + any repeatedIdentity assignments)
+	addValidators(chainCfg.Validators.Count, false, validatorStartIdx, chainCfg.Validators.stakeAmounts(), chainCfg.Validators.Amount,
+		chainCfg.ID, chainCfg.RootChain, validatorCommitteeAssignments, validatorExpandingCommittees,
+		netAddressSuffix, seed, validatorKeyType, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
+
+	// Create committee-only validators (staked ONLY for target committee in the root chain)
+	// These validators appear in the ROOT chain's genesis with committees: [target_committee]
+	committeeOnlyValidatorIdx := committeeOnlyValidatorStartIdx
+	for _, ca := range chainCfg.Committees {
+		for i := 0; i < ca.ValidatorCount; i++ {
+			addCommitteeOnlyValidator(committeeOnlyValidatorIdx+i, chainCfg.Validators.stakeAmounts().Default, chainCfg.Validators.Amount.Default,
+				ca.crossChainAmount(chainCfg.Validators.Amount.Default), chainCfg.ID, chainCfg.RootChain, uint64(ca.ID), netAddressSuffix,
+				seed, validatorKeyType, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
+		}
+		committeeOnlyValidatorIdx += ca.ValidatorCount
+	}
+
+	// Create regular delegators
+	addValidators(chainCfg.Delegators.Count, true, delegatorStartIdx, NewNodeAmounts(chainCfg.Delegators.StakedAmount), chainCfg.Delegators.Amount,
+		chainCfg.ID, chainCfg.RootChain, delegatorCommitteeAssignments, delegatorExpandingCommittees,
+		netAddressSuffix, seed, delegatorKeyType, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
+
+	// Create committee-only delegators (staked ONLY for target committee in the root chain)
+	committeeOnlyDelegatorIdx := delegatorStartIdx - chainCfg.Delegators.Count // Continue negative IDs after regular delegators
+	for _, ca := range chainCfg.Committees {
+		for i := 0; i < ca.DelegatorCount; i++ {
+			addCommitteeOnlyDelegator(committeeOnlyDelegatorIdx-i, chainCfg.Delegators.StakedAmount, chainCfg.Delegators.Amount.Default,
+				ca.crossChainAmount(chainCfg.Delegators.Amount.Default), chainCfg.ID, chainCfg.RootChain, uint64(ca.ID), netAddressSuffix,
+				seed, delegatorKeyType, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
+		}
+		committeeOnlyDelegatorIdx -= ca.DelegatorCount
+	}
+
+	addFullNodes(chainCfg.FullNodes.Count, chainCfg.FullNodes.Amount, fullNodeStartIdx, chainCfg.ID, chainCfg.RootChain,
+		netAddressSuffix, seed, fullNodeKeyType, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
+	addAccounts(chainCfg.Accounts.Count, chainCfg.Accounts.Amount, &wg, semaphoreChan, accountChan)
+
+	wg.Wait()
+	close(accountChan)
+	// Wait for the collector to drain the channel before returning the accounts
+	<-collected
+
+	// Sort chain identities by ID
+	sort.Slice(chainIdentities, func(i, j int) bool {
+		return chainIdentities[i].ID < chainIdentities[j].ID
+	})
+
+	log.Info("generated identities", "chain", chainName, "validators", chainCfg.Validators.Count,
+		"delegators", chainCfg.Delegators.Count, "full_nodes", chainCfg.FullNodes.Count, "accounts", chainCfg.Accounts.Count)
+
+	return chainIdentities, accounts
+}
+
+// rootChainGroups maps every chain ID to the ID of the root chain its group is named after, following
+// rootChain up from nested chains (of nested chains) to the chain that is its own root
+func rootChainGroups(cfg *AppConfig) map[int]int {
+	rootChains := make(map[int]int, len(cfg.Chains))
+	for _, chainCfg := range cfg.Chains {
+		rootChains[chainCfg.ID] = chainCfg.RootChain
+	}
+	return groupRootChains(rootChains)
+}
+
+// groupRootChains maps every chain ID of rootChains, a chain ID -> rootChain map, to the root chain of its group
+func groupRootChains(rootChains map[int]int) map[int]int {
+	groups := make(map[int]int, len(rootChains))
+	for chainID := range rootChains {
+		root := chainID
+		for hops := 0; rootChains[root] != root && hops < len(rootChains); hops++ {
+			root = rootChains[root]
+		}
+		groups[chainID] = root
+	}
+	return groups
+}
+
+// splitIdsByRootChain splits ids.json into one file per root chain group with the group's nodes, chains
+// and genesis hashes, and every main account. groupOf maps the chain IDs to their root chain group, see
+// rootChainGroups. It returns an error if a node's rootChainNode or peerNode is a node of another group, as the
+// group's file couldn't resolve it
+func splitIdsByRootChain(groupOf map[int]int, ids IdsFile) (map[int]*IdsFile, error) {
+	files := make(map[int]*IdsFile)
+	for _, rootChainID := range groupOf {
+		if _, ok := files[rootChainID]; !ok {
+			files[rootChainID] = &IdsFile{
+				MainAccounts:     ids.MainAccounts,
+				KeystoreExcluded: ids.KeystoreExcluded,
+				Keys:             make(map[string]NodeIdentity),
+			}
+		}
+	}
+	for chainID, rootChainID := range groupOf {
+		file := files[rootChainID]
+		if name, ok := ids.Chains[chainID]; ok {
+			if file.Chains == nil {
+				file.Chains = make(map[int]string)
+			}
+			file.Chains[chainID] = name
+		}
+		if hash, ok := ids.GenesisHashes[chainID]; ok {
+			if file.GenesisHashes == nil {
+				file.GenesisHashes = make(map[int]string)
+			}
+			file.GenesisHashes[chainID] = hash
+		}
+	}
+	keys := make([]string, 0, len(ids.Keys))
+	for key := range ids.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs []string
+	for _, key := range keys {
+		identity := ids.Keys[key]
+		rootChainID := groupOf[identity.ChainID]
+		refs := map[string]*int{"rootChainNode": identity.RootChainNode, "peerNode": identity.PeerNode}
+		for _, tracked := range identity.Tracks {
+			refs[fmt.Sprintf("tracks chain %d node", tracked.ChainID)] = &tracked.Node
+		}
+		for field, ref := range refs {
+			if ref == nil {
+				continue
+			}
+			if other := groupOf[ids.Keys[fmt.Sprintf("node-%d", *ref)].ChainID]; other != rootChainID {
+				errs = append(errs, fmt.Sprintf("%s (root chain %d): %s %d belongs to root chain %d",
+					key, rootChainID, field, *ref, other))
+			}
+		}
+		files[rootChainID].Keys[key] = identity
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("ids.json can't be split per root chain:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return files, nil
+}
+
+// writerBufferSize returns the configured jwriter buffer size, or picks one based on the number of entries
+func writerBufferSize(configured int, entries int) int {
+	if configured > 0 {
+		return configured
+	}
+	if entries >= largeChainEntries {
+		return largeWriterBuffer
+	}
+	return defaultWriterBuffer
+}
+
+// keystoreEntry is a key imported into a chain's keystore, used to verify the saved keystore
+type keystoreEntry struct {
+	nickname   string
+	address    string
+	password   string
+	privateKey []byte
+}
+
+// mustVerifyKeystore reloads a saved keystore and checks that entries decrypt with their password to their
+// source key bytes, and that their nickname resolves to their address. sample limits the check to that many
+// evenly spaced entries, 0 checks all of them
+func mustVerifyKeystore(path string, data []byte, entries []keystoreEntry, sample int) {
+	keystore := new(crypto.Keystore)
+	if err := json.Unmarshal(data, keystore); err != nil {
+		panic(fmt.Errorf("failed to parse keystore '%s': %w", path, err))
+	}
+	if sample <= 0 || sample > len(entries) {
+		sample = len(entries)
+	}
+	for i := 0; i < sample; i++ {
+		entry := entries[i*len(entries)/sample]
+		if keystore.NicknameMap[entry.nickname] != entry.address {
+			panic(fmt.Errorf("keystore '%s': nickname %s does not resolve to %s", path, entry.nickname, entry.address))
+		}
+		address, err := hex.DecodeString(entry.address)
+		if err != nil {
+			panic(err)
+		}
+		key, err := keystore.GetKey(address, entry.password)
+		if err != nil {
+			panic(fmt.Errorf("keystore '%s': failed to decrypt %s: %w", path, entry.nickname, err))
+		}
+		if !bytes.Equal(key.Bytes(), entry.privateKey) {
+			panic(fmt.Errorf("keystore '%s': %s decrypts to a different private key", path, entry.nickname))
+		}
+	}
+}
+
+// chainFiles are the files every chain folder in the artifacts must contain
+var chainFiles = []string{"genesis.json", "config.json", "keystore.json"}
+
+// checkArtifacts walks a generated output tree and returns its structural inconsistencies: chain folders
+// missing files, ids.json entries referencing unknown nodes or chains, keystore nicknames that don't
+// align with ids.json, and ids-root-*.json files that don't split ids.json into self-contained groups
+func checkArtifacts(outputBaseDir string) ([]string, error) {
+	var issues []string
+	entries, err := os.ReadDir(outputBaseDir)
+	if err != nil {
+		return nil, err
+	}
+	// Chain folders by the chain id of their config.json
+	chainDirs := make(map[int]string)
+	keystores := make(map[int]*crypto.Keystore)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		chainDir := filepath.Join(outputBaseDir, entry.Name())
+		missing := false
+		for _, file := range chainFiles {
+			if _, err := os.Stat(filepath.Join(chainDir, file)); err != nil {
+				issues = append(issues, fmt.Sprintf("chain folder %s: missing %s", entry.Name(), file))
+				missing = true
+			}
+		}
+		if missing {
+			continue
+		}
+		config := new(lib.Config)
+		if err := readJSON(filepath.Join(chainDir, "config.json"), config); err != nil {
+			issues = append(issues, fmt.Sprintf("chain folder %s: %v", entry.Name(), err))
+			continue
+		}
+		chainID := int(config.ChainId)
+		if other, ok := chainDirs[chainID]; ok {
+			issues = append(issues, fmt.Sprintf("chain folders %s and %s: both have chain id %d", other, entry.Name(), chainID))
+			continue
+		}
+		if want := fmt.Sprintf("chain_%d", chainID); entry.Name() != want {
+			issues = append(issues, fmt.Sprintf("chain folder %s: has chain id %d, expected folder %s", entry.Name(), chainID, want))
+		}
+		genesis := new(genesisSupplyFile)
+		if err := readJSON(filepath.Join(chainDir, "genesis.json"), genesis); err != nil {
+			issues = append(issues, fmt.Sprintf("chain folder %s: %v", entry.Name(), err))
+		} else if issue := genesis.checkSupply(); issue != "" {
+			issues = append(issues, fmt.Sprintf("chain folder %s: genesis.json %s", entry.Name(), issue))
+		}
+		keystore := new(crypto.Keystore)
+		if err := readJSON(filepath.Join(chainDir, "keystore.json"), keystore); err != nil {
+			issues = append(issues, fmt.Sprintf("chain folder %s: %v", entry.Name(), err))
+			continue
+		}
+		chainDirs[chainID] = entry.Name()
+		keystores[chainID] = keystore
+	}
+	if len(chainDirs) == 0 {
+		issues = append(issues, "no chain folders found")
+	}
+
+	ids := new(IdsFile)
+	if err := readJSON(filepath.Join(outputBaseDir, "ids.json"), ids); err != nil {
+		return append(issues, err.Error()), nil
+	}
+	for chainID, chainName := range ids.Chains {
+		if _, ok := chainDirs[chainID]; !ok {
+			issues = append(issues, fmt.Sprintf("ids.json chains: no chain folder for chain id %d (%s)", chainID, chainName))
+		}
+	}
+	for chainID, want := range ids.GenesisHashes {
+		chainDir, ok := chainDirs[chainID]
+		if !ok {
+			issues = append(issues, fmt.Sprintf("ids.json genesis-hashes: no chain folder for chain id %d", chainID))
+			continue
+		}
+		genesis, err := os.ReadFile(filepath.Join(outputBaseDir, chainDir, "genesis.json"))
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("chain folder %s: %v", chainDir, err))
+			continue
+		}
+		if sum := sha256.Sum256(genesis); hex.EncodeToString(sum[:]) != want {
+			issues = append(issues, fmt.Sprintf("chain folder %s: genesis.json sha256 %x does not match ids.json %s", chainDir, sum, want))
+		}
+	}
+	// Nicknames of each chain's keystore that ids.json accounts for
+	known := make(map[int]map[string]bool)
+	for key, identity := range ids.Keys {
+		if want := fmt.Sprintf("node-%d", identity.ID); key != want {
+			issues = append(issues, fmt.Sprintf("ids.json %s: has id %d, expected key %s", key, identity.ID, want))
+		}
+		if _, ok := chainDirs[identity.ChainID]; !ok {
+			issues = append(issues, fmt.Sprintf("ids.json %s: no chain folder for chain id %d", key, identity.ChainID))
+		}
+		if _, ok := chainDirs[identity.RootChainID]; !ok {
+			issues = append(issues, fmt.Sprintf("ids.json %s: no chain folder for root chain id %d", key, identity.RootChainID))
+		}
+		if identity.RootChainNode == nil {
+			issues = append(issues, fmt.Sprintf("ids.json %s: missing rootChainNode", key))
+		} else if root, ok := ids.Keys[fmt.Sprintf("node-%d", *identity.RootChainNode)]; !ok {
+			issues = append(issues, fmt.Sprintf("ids.json %s: rootChainNode %d does not exist", key, *identity.RootChainNode))
+		} else if root.ChainID != identity.RootChainID {
+			issues = append(issues, fmt.Sprintf("ids.json %s: rootChainNode %d is on chain %d, not root chain %d",
+				key, *identity.RootChainNode, root.ChainID, identity.RootChainID))
+		}
+		if identity.PeerNode == nil {
+			issues = append(issues, fmt.Sprintf("ids.json %s: missing peerNode", key))
+		} else if _, ok := ids.Keys[fmt.Sprintf("node-%d", *identity.PeerNode)]; !ok {
+			issues = append(issues, fmt.Sprintf("ids.json %s: peerNode %d does not exist", key, *identity.PeerNode))
+		}
+		for _, tracked := range identity.Tracks {
+			if node, ok := ids.Keys[fmt.Sprintf("node-%d", tracked.Node)]; !ok {
+				issues = append(issues, fmt.Sprintf("ids.json %s: node %d of tracked chain %d does not exist", key, tracked.Node, tracked.ChainID))
+			} else if node.ChainID != tracked.ChainID {
+				issues = append(issues, fmt.Sprintf("ids.json %s: node %d of tracked chain %d is on chain %d",
+					key, tracked.Node, tracked.ChainID, node.ChainID))
+			}
+		}
+		keystore, ok := keystores[identity.ChainID]
+		if !ok || slices.Contains(ids.KeystoreExcluded, identity.NodeType) {
+			continue
+		}
+		if address := keystore.NicknameMap[key]; address != identity.Address {
+			issues = append(issues, fmt.Sprintf("ids.json %s: keystore of %s has address %q, expected %s",
+				key, chainDirs[identity.ChainID], address, identity.Address))
+		}
+		if known[identity.ChainID] == nil {
+			known[identity.ChainID] = make(map[string]bool)
+		}
+		known[identity.ChainID][key] = true
+	}
+	for chainID, keystore := range keystores {
+		for nickname, address := range keystore.NicknameMap {
+			switch {
+			case strings.HasPrefix(nickname, "delegator-"):
+				// Delegators are not physical nodes and don't appear in ids.json
+			case strings.HasPrefix(nickname, "node-"):
+				if !known[chainID][nickname] {
+					issues = append(issues, fmt.Sprintf("keystore of %s: %s has no ids.json entry on chain %d",
+						chainDirs[chainID], nickname, chainID))
+				}
+			default:
+				mainAccount, ok := ids.MainAccounts[nickname]
+				if !ok {
+					issues = append(issues, fmt.Sprintf("keystore of %s: %s is not a main account in ids.json",
+						chainDirs[chainID], nickname))
+				} else if mainAccount.Address != address {
+					issues = append(issues, fmt.Sprintf("keystore of %s: %s has address %s, ids.json main account has %s",
+						chainDirs[chainID], nickname, address, mainAccount.Address))
+				}
+			}
+		}
+	}
+	// Root chain group files: every ids.json node in exactly one, with references resolving in its file
+	groupFiles, err := filepath.Glob(filepath.Join(outputBaseDir, "ids-root-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	grouped := make(map[string]string) // ids.json key -> group file
+	for _, path := range groupFiles {
+		name := filepath.Base(path)
+		group := new(IdsFile)
+		if err := readJSON(path, group); err != nil {
+			issues = append(issues, err.Error())
+			continue
+		}
+		for key, identity := range group.Keys {
+			if want, ok := ids.Keys[key]; !ok || want.Address != identity.Address || want.ChainID != identity.ChainID {
+				issues = append(issues, fmt.Sprintf("%s %s: does not match ids.json", name, key))
+			}
+			if other, ok := grouped[key]; ok {
+				issues = append(issues, fmt.Sprintf("%s %s: also in %s", name, key, other))
+			}
+			grouped[key] = name
+			if identity.RootChainNode != nil {
+				if _, ok := group.Keys[fmt.Sprintf("node-%d", *identity.RootChainNode)]; !ok {
+					issues = append(issues, fmt.Sprintf("%s %s: rootChainNode %d is not in the file", name, key, *identity.RootChainNode))
+				}
+			}
+			if identity.PeerNode != nil {
+				if _, ok := group.Keys[fmt.Sprintf("node-%d", *identity.PeerNode)]; !ok {
+					issues = append(issues, fmt.Sprintf("%s %s: peerNode %d is not in the file", name, key, *identity.PeerNode))
+				}
+			}
+			for _, tracked := range identity.Tracks {
+				if _, ok := group.Keys[fmt.Sprintf("node-%d", tracked.Node)]; !ok {
+					issues = append(issues, fmt.Sprintf("%s %s: node %d of tracked chain %d is not in the file", name, key, tracked.Node, tracked.ChainID))
+				}
+			}
 		}
 	}
-
-	// Calculate how many committee-only validators/delegators to create
-	totalCommitteeOnlyValidators := 0
-	totalCommitteeOnlyDelegators := 0
-	for _, ca := range chainCfg.Committees {
-		totalCommitteeOnlyValidators += ca.ValidatorCount
-		totalCommitteeOnlyDelegators += ca.DelegatorCount
-	}
-
-	// Assign unique idx within this chain
-	// Validators get positive IDs starting from startIdx
-	validatorStartIdx := startIdx
-	// Committee-only validators get positive IDs right after regular validators
-	committeeOnlyValidatorStartIdx := validatorStartIdx + chainCfg.Validators.Count
-	// Full nodes get positive IDs right after committee-only validators
-	fullNodeStartIdx := committeeOnlyValidatorStartIdx + totalCommitteeOnlyValidators
-	// Delegators get negative IDs (passed in from caller)
-
-	// Create regular validators (staked for their own chain's committee + any repeatedIdentity assignments)
-	addValidators(chainCfg.Validators.Count, false, validatorStartIdx, chainCfg.Validators.StakedAmount, chainCfg.Validators.Amount,
-		chainCfg.ID, chainCfg.RootChain, validatorCommitteeAssignments, validatorExpandingCommittees,
-		netAddressSuffix, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
-
-	// Create committee-only validators (staked ONLY for target committee in the root chain)
-	// These validators appear in the ROOT chain's genesis with committees: [target_committee]
-	committeeOnlyValidatorIdx := committeeOnlyValidatorStartIdx
-	for _, ca := range chainCfg.Committees {
-		for i := 0; i < ca.ValidatorCount; i++ {
-			addCommitteeOnlyValidator(committeeOnlyValidatorIdx+i, chainCfg.Validators.StakedAmount, chainCfg.Validators.Amount,
-				chainCfg.ID, chainCfg.RootChain, uint64(ca.ID), netAddressSuffix,
-				&chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
+	if len(groupFiles) > 0 {
+		for key := range ids.Keys {
+			if _, ok := grouped[key]; !ok {
+				issues = append(issues, fmt.Sprintf("ids.json %s: in no ids-root-*.json file", key))
+			}
 		}
-		committeeOnlyValidatorIdx += ca.ValidatorCount
 	}
+	sort.Strings(issues)
+	return issues, nil
+}
 
-	// Create regular delegators
-	addValidators(chainCfg.Delegators.Count, true, delegatorStartIdx, chainCfg.Delegators.StakedAmount, chainCfg.Delegators.Amount,
-		chainCfg.ID, chainCfg.RootChain, delegatorCommitteeAssignments, delegatorExpandingCommittees,
-		netAddressSuffix, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
+// genesisSupplyFile is the part of a genesis.json its supply is computed from
+type genesisSupplyFile struct {
+	Accounts []struct {
+		Amount uint64 `json:"amount"`
+	} `json:"accounts"`
+	Pools []struct {
+		Amount uint64 `json:"amount"`
+	} `json:"pools"`
+	Validators []struct {
+		StakedAmount uint64   `json:"stakedAmount"`
+		Committees   []uint64 `json:"committees"`
+		Delegate     bool     `json:"delegate"`
+	} `json:"validators"`
+	Supply *fsm.Supply `json:"supply"`
+}
 
-	// Create committee-only delegators (staked ONLY for target committee in the root chain)
-	committeeOnlyDelegatorIdx := delegatorStartIdx - chainCfg.Delegators.Count // Continue negative IDs after regular delegators
-	for _, ca := range chainCfg.Committees {
-		for i := 0; i < ca.DelegatorCount; i++ {
-			addCommitteeOnlyDelegator(committeeOnlyDelegatorIdx-i, chainCfg.Delegators.StakedAmount, chainCfg.Delegators.Amount,
-				chainCfg.ID, chainCfg.RootChain, uint64(ca.ID), netAddressSuffix,
-				&chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
-		}
-		committeeOnlyDelegatorIdx -= ca.DelegatorCount
+// checkSupply recomputes the supply from the accounts, pools and validators, returning an issue when the
+// written supply is missing or differs
+func (g *genesisSupplyFile) checkSupply() string {
+	if g.Supply == nil {
+		return "has no supply"
 	}
+	want := new(fsm.Supply)
+	for _, account := range g.Accounts {
+		want.Total += account.Amount
+	}
+	for _, pool := range g.Pools {
+		want.Total += pool.Amount
+	}
+	for _, v := range g.Validators {
+		addSupplyStake(want, v.StakedAmount, v.Committees, v.Delegate)
+	}
+	got, err := json.Marshal(g.Supply)
+	if err != nil {
+		return err.Error()
+	}
+	expected, err := json.Marshal(want)
+	if err != nil {
+		return err.Error()
+	}
+	if !bytes.Equal(got, expected) {
+		return fmt.Sprintf("supply %s does not match its accounts, pools and validators %s", got, expected)
+	}
+	return ""
+}
 
-	addFullNodes(chainCfg.FullNodes.Count, chainCfg.FullNodes.Amount, fullNodeStartIdx, chainCfg.ID, chainCfg.RootChain,
-		netAddressSuffix, &chainIdentities, &chainSync, &wg, semaphoreChan, accountChan)
-	addAccounts(chainCfg.Accounts.Count, chainCfg.Accounts.Amount, &wg, semaphoreChan, accountChan)
-
-	wg.Wait()
-	close(accountChan)
-
-	// Sort chain identities by ID
-	sort.Slice(chainIdentities, func(i, j int) bool {
-		return chainIdentities[i].ID < chainIdentities[j].ID
-	})
-
-	fmt.Printf("Chain %s: %d validators, %d delegators, %d full nodes, %d accounts\n",
-		chainName, chainCfg.Validators.Count, chainCfg.Delegators.Count, chainCfg.FullNodes.Count, chainCfg.Accounts.Count)
+// readJSON reads and parses a JSON file into v
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse '%s': %w", filepath.Base(path), err)
+	}
+	return nil
+}
 
-	return chainIdentities, accounts
+// runArtifactsCheck checks the generated output tree, printing every inconsistency found, and reports
+// whether the tree is consistent
+func runArtifactsCheck(outputBaseDir string) bool {
+	log.Info("checking artifacts", "path", outputBaseDir)
+	issues, err := checkArtifacts(outputBaseDir)
+	if err != nil {
+		log.Error("artifacts check failed", "path", outputBaseDir, "error", err)
+		return false
+	}
+	for _, issue := range issues {
+		log.Error("artifacts inconsistency", "issue", issue)
+	}
+	if len(issues) > 0 {
+		log.Error("artifacts are inconsistent", "path", outputBaseDir, "inconsistencies", len(issues))
+		return false
+	}
+	log.Info("artifacts are consistent", "path", outputBaseDir)
+	return true
 }
 
 // writeChainFiles writes genesis.json, config.json, and keystore.json for a chain
 // expandedValidators contains validators/delegators with correct IDs for this chain (including cross-chain)
 func writeChainFiles(chainName string, chainCfg *ChainConfig, chainIdentities []NodeIdentity,
-	genesisValidators []NodeIdentity, keystoreValidators []NodeIdentity, dialPeers []string,
-	accounts []*fsm.Account, mainAccounts map[string]*MainAccount, password string, jsonBeautify bool, outputBaseDir string) {
-
-	chainDir := filepath.Join(outputBaseDir, chainName)
-	mustSetDirectory(chainDir)
+	genesisValidators []NodeIdentity, keystoreValidators []NodeIdentity, borrowedValidators []NodeIdentity, dialPeers []string,
+	accounts []*fsm.Account, mainAccounts map[string]*MainAccount, password string, passwords map[string]string,
+	keystoreInclude map[string]bool, protocolVersion string, jsonBeautify bool, writerBuffer int, sink outputSink) (genesisHash string) {
 
 	// Build a set of native account addresses for deduplication
 	nativeAddresses := make(map[string]bool)
@@ -1138,111 +3695,110 @@ func writeChainFiles(chainName string, chainCfg *ChainConfig, chainIdentities []
 			nativeAddresses[v.Address] = true // Prevent duplicates
 		}
 	}
-
-	// Write accounts.json first (needed for genesis)
-	accountsPath := filepath.Join(chainDir, "accounts.json")
-	accountsFile, err := os.Create(accountsPath)
-	if err != nil {
-		panic(err)
+	// Borrowed validators also need accounts in this chain (but are not added to its keystore)
+	for _, v := range borrowedValidators {
+		if !nativeAddresses[v.Address] {
+			crossChainAccounts = append(crossChainAccounts, v)
+			nativeAddresses[v.Address] = true // Prevent duplicates
+		}
 	}
 
-	writer := jwriter.NewStreamingWriter(accountsFile, 1024)
-	arr := writer.Array()
-	// Write native accounts
+	// Size the streaming buffer by the number of entries written to genesis
+	writerBuffer = writerBufferSize(writerBuffer,
+		len(accounts)+len(crossChainAccounts)+len(mainAccounts)+len(genesisValidators))
+
+	// Write the accounts first (needed for genesis)
+	var accountsFile bytes.Buffer
+
+	// Native accounts arrive in goroutine scheduling order, sort all accounts by address so the
+	// genesis accounts section is byte-stable across regenerations
+	type accountEntry struct {
+		address string
+		amount  uint64
+	}
+	entries := make([]accountEntry, 0, len(accounts)+len(crossChainAccounts)+len(mainAccounts))
+	// Native accounts
 	for _, account := range accounts {
-		accountObj := writer.Object()
-		accountObj.Name("address").String(hex.EncodeToString(account.Address))
-		accountObj.Name("amount").Int(int(account.Amount))
-		accountObj.End()
+		entries = append(entries, accountEntry{hex.EncodeToString(account.Address), account.Amount})
 	}
-	// Write accounts for cross-chain validators/delegators
+	// Accounts for cross-chain validators/delegators
 	for _, v := range crossChainAccounts {
-		accountObj := writer.Object()
-		accountObj.Name("address").String(v.Address)
-		accountObj.Name("amount").Int(int(v.Amount))
-		accountObj.End()
+		entries = append(entries, accountEntry{v.Address, v.Amount})
 	}
-	// Write main accounts (same identities across all chains, uses chain's account amount)
+	// Main accounts (same identities across all chains, uses chain's account amount)
 	for _, mainAccount := range mainAccounts {
-		mainAccountObj := writer.Object()
-		mainAccountObj.Name("address").String(mainAccount.Address)
-		mainAccountObj.Name("amount").Int(int(chainCfg.Accounts.Amount))
-		mainAccountObj.End()
+		entries = append(entries, accountEntry{mainAccount.Address, chainCfg.Accounts.Amount.Default})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].address < entries[j].address })
+
+	writer := jwriter.NewStreamingWriter(&accountsFile, writerBuffer)
+	arr := writer.Array()
+	var accountsTotal uint64
+	for _, entry := range entries {
+		accountsTotal += entry.amount
+		accountObj := writer.Object()
+		accountObj.Name("address").String(entry.address)
+		accountObj.Name("amount").Int(int(entry.amount))
+		accountObj.End()
 	}
 	arr.End()
 	if err := writer.Flush(); err != nil {
 		panic(err)
 	}
-	accountsFile.Close()
 
 	// Write genesis.json (uses genesisValidators for validators section)
-	maxCommitteeSize := chainCfg.MaxCommitteeSize
-	if maxCommitteeSize == 0 {
-		maxCommitteeSize = 100 // Default value
-	}
-	blockSize := chainCfg.BlockSize
-	if blockSize == 0 {
-		blockSize = 1000000 // Default value
-	}
-	writeGenesisFromIdentities(chainDir, chainCfg.ID, chainCfg.RootChain, genesisValidators, accountsPath, maxCommitteeSize, blockSize, chainCfg.PoolAmount)
-
-	// Beautify genesis.json if configured
-	if jsonBeautify {
-		genesisPath := filepath.Join(chainDir, "genesis.json")
-		rawData, err := os.ReadFile(genesisPath)
+	genesisName := path.Join(chainName, "genesis.json")
+	var supply *fsm.Supply
+	if !jsonBeautify {
+		genesisFile, err := sink.Create(genesisName)
 		if err != nil {
 			panic(err)
 		}
+		hash := sha256.New()
+		supply = writeGenesisFromIdentities(io.MultiWriter(genesisFile, hash), chainCfg.ID, chainCfg.RootChain, genesisValidators, accountsFile.Bytes(),
+			accountsTotal, genesisParams(chainCfg, protocolVersion), chainCfg.PoolAmount, writerBuffer)
+		if err := genesisFile.Close(); err != nil {
+			panic(err)
+		}
+		genesisHash = hex.EncodeToString(hash.Sum(nil))
+	} else {
+		// Beautify genesis.json before writing it
+		var rawData bytes.Buffer
+		supply = writeGenesisFromIdentities(&rawData, chainCfg.ID, chainCfg.RootChain, genesisValidators, accountsFile.Bytes(),
+			accountsTotal, genesisParams(chainCfg, protocolVersion), chainCfg.PoolAmount, writerBuffer)
 		var parsed interface{}
-		if err := json.Unmarshal(rawData, &parsed); err != nil {
+		if err := json.Unmarshal(rawData.Bytes(), &parsed); err != nil {
 			panic(err)
 		}
 		beautified, err := json.MarshalIndent(parsed, "", "  ")
 		if err != nil {
 			panic(err)
 		}
-		if err := os.WriteFile(genesisPath, beautified, 0644); err != nil {
-			panic(err)
-		}
+		mustWriteFile(sink, genesisName, beautified)
+		sum := sha256.Sum256(beautified)
+		genesisHash = hex.EncodeToString(sum[:])
 	}
+	log.Info("genesis supply", "chain", chainName, "total", supply.Total, "staked", supply.Staked,
+		"delegated_only", supply.DelegatedOnly)
 
-	// Delete accounts.json as it was only needed for genesis.json
-	if err := os.Remove(accountsPath); err != nil {
-		panic(err)
-	}
-	maxTotalBytes := chainCfg.MaxTotalBytes
-	if maxTotalBytes == 0 {
-		maxTotalBytes = 1000000 // Default value
-	}
 	// Write config.json for this chain
-	templateConfig := createTemplateConfig(
-		chainCfg.ID,
-		chainCfg.RootChain,
-		chainCfg.SleepUntil,
-		chainCfg.MinimumPeersToStart,
-		chainCfg.MaxInbound,
-		chainCfg.MaxOutbound,
-		chainCfg.InMemory,
-		chainCfg.GossipThreshold,
-		dialPeers,
-		chainCfg.MaxTransactionCount,
-		chainCfg.DropPercentage,
-		chainCfg.LazyMempoolCheckFrequencyS,
-		maxTotalBytes,
-	)
-	mustSaveAsJSON(filepath.Join(chainDir, "config.json"), templateConfig)
+	mustSaveAsJSON(sink, path.Join(chainName, "config.json"), chainTemplateConfig(chainCfg, dialPeers))
 
 	// Create keystore.json for this chain
 	// Include all validators/delegators whose accounts are in this chain (keystoreValidators)
-	// Plus all native full nodes
+	// Plus all native full nodes, leaving out the node types not in keystoreInclude
 	keystoreIdentities := make([]NodeIdentity, 0)
 
 	// Add all validators/delegators for this chain's keystore
-	keystoreIdentities = append(keystoreIdentities, keystoreValidators...)
+	for _, identity := range keystoreValidators {
+		if keystoreInclude[identity.NodeType] {
+			keystoreIdentities = append(keystoreIdentities, identity)
+		}
+	}
 
 	// Add native full nodes
 	for _, identity := range chainIdentities {
-		if identity.NodeType == "fullnode" {
+		if identity.NodeType == "fullnode" && keystoreInclude[fullNodeNick] {
 			keystoreIdentities = append(keystoreIdentities, identity)
 		}
 	}
@@ -1251,6 +3807,8 @@ func writeChainFiles(chainName string, chainCfg *ChainConfig, chainIdentities []
 		AddressMap:  make(map[string]*crypto.EncryptedPrivateKey, len(keystoreIdentities)+len(mainAccounts)),
 		NicknameMap: make(map[string]string, len(keystoreIdentities)+len(mainAccounts)),
 	}
+	// Imported entries are kept to verify the saved keystore decrypts back to the source keys
+	var imported []keystoreEntry
 	for _, identity := range keystoreIdentities {
 		var nickname string
 		if identity.IsDelegate {
@@ -1259,33 +3817,127 @@ func writeChainFiles(chainName string, chainCfg *ChainConfig, chainIdentities []
 		} else {
 			nickname = fmt.Sprintf("node-%d", identity.ID)
 		}
-		_, err := keystore.ImportRaw(identity.PrivateKeyBytes, password, crypto.ImportRawOpts{
+		// With a per-node strategy (passwords != nil) each entry gets its own derived password
+		nodePassword := password
+		if passwords != nil {
+			nodePassword = derivePassword(password, nickname)
+			passwords[nickname] = nodePassword
+		}
+		address, err := keystore.ImportRaw(identity.PrivateKeyBytes, nodePassword, crypto.ImportRawOpts{
 			Nickname: nickname,
 		})
 		if err != nil {
 			panic(err)
 		}
+		imported = append(imported, keystoreEntry{nickname, address, nodePassword, identity.PrivateKeyBytes})
 	}
 	// Add main accounts to keystore
-	for name, mainAccount := range mainAccounts {
-		_, err = keystore.ImportRaw(mainAccount.PrivateKeyBytes, password, crypto.ImportRawOpts{
-			Nickname: name,
-		})
-		if err != nil {
-			panic(err)
+	if keystoreInclude[accountNick] {
+		for name, mainAccount := range mainAccounts {
+			address, err := keystore.ImportRaw(mainAccount.PrivateKeyBytes, password, crypto.ImportRawOpts{
+				Nickname: name,
+			})
+			if err != nil {
+				panic(err)
+			}
+			imported = append(imported, keystoreEntry{name, address, password, mainAccount.PrivateKeyBytes})
 		}
 	}
-	mustSaveAsJSON(filepath.Join(chainDir, "keystore.json"), keystore)
+	keystorePath := path.Join(chainName, "keystore.json")
+	keystoreData := mustEncodeJSON(keystore)
+	mustWriteFile(sink, keystorePath, keystoreData)
+
+	if *verifyKeystore {
+		mustVerifyKeystore(keystorePath, keystoreData, imported, *verifyKeystoreSample)
+		log.Info("keystore entries decrypt to their source keys", "chain", chainName)
+	}
 
-	fmt.Printf("Written files for chain %s\n", chainName)
+	log.Info("written chain files", "chain", chainName, "genesis_sha256", genesisHash)
+	return genesisHash
 }
 
 var (
-	configPath = flag.String("path", "../../", "path to the folder containing the config files")
-	configName = flag.String("config", "default", "name of the config to use")
-	outputDir  = flag.String("output", "../../artifacts", "path to the folder where the output files will be saved")
+	configPath     = flag.String("path", "../../", "path to the folder containing the config files")
+	configFileFlag = flag.String("configFile", "", "path to the config file, defaults to configs.yml under -path, accounts.yml is still read from -path")
+	configName     = flag.String("config", "default", "name of the config to use")
+	outputDir      = flag.String("output", "../../artifacts", "path to the folder where the output files will be saved, - writes a tar archive to stdout")
+	tarOutput      = flag.Bool("tar", false, "write the output files as a tar archive to <output>/<config>.tar instead of a folder")
+	csvOutput      = flag.Bool("csv", false, "also write ids.csv, one row per ids.json entry, for spreadsheet review")
+	metricsFile    = flag.String("metricsFile", "", "also write the summary.json counts to this path in the Prometheus textfile format, for CI dashboards")
+	outputName     = flag.String("outputName", "{config}", "name of the output folder under <output>, with the {config}, {timestamp} and {seed} placeholders, e.g. {config}-{timestamp} keeps every run")
+	scale          = flag.Int("scale", 1, "multiply every node, delegator, account and committee count (and nodes.count) by this factor before validation")
+	noClean        = flag.Bool("noClean", false, "keep the files of a previous run in the output folder instead of deleting them, files of this run overwrite theirs")
+	seed           = flag.String("seed", "", "derive every node key from this seed and the node id for reproducible artifacts, overrides general.seed")
+
+	validateOnly = flag.Bool("validateOnly", false, "run all validations, print a JSON report and exit without generating files")
+	printConfig  = flag.Bool("printConfig", false, "print the effective config with all defaults applied as JSON and exit without generating files")
+	countOnly    = flag.Bool("countOnly", false, "validate the node counts and committee assignments, print the per-chain breakdown and exit without generating keys or files")
+	strict       = flag.Bool("strict", false, "fail validation on warnings, e.g. delegators staked for committees without validators, more staked than funded or oversized committees")
+
+	verifyKeystore       = flag.Bool("verifyKeystore", false, "verify keystore entries decrypt back to their source keys")
+	verifyKeystoreSample = flag.Int("verifyKeystoreSample", 0, "number of keystore entries per chain to verify with -verifyKeystore (0 = all)")
+
+	check     = flag.Bool("check", false, "check the generated artifacts for structural inconsistencies after generation")
+	checkOnly = flag.Bool("checkOnly", false, "check previously generated artifacts for structural inconsistencies and exit")
+	merge     = flag.String("merge", "", "merge the artifact folders of two networks given as arguments into this folder and exit, renumbering the second network's node ids when they collide")
+
+	logFormat = flag.String("logFormat", "text", "log format, text or json")
 )
 
+// stdoutOutput is the -output value that writes the artifacts as a tar archive to stdout
+const stdoutOutput = "-"
+
+// outputTimestampLayout is the layout of the {timestamp} placeholder of -outputName, sortable and path safe
+const outputTimestampLayout = "20060102-150405"
+
+// expandOutputName replaces the placeholders of an -outputName template, failing on unknown ones and on
+// {seed} without a seed
+func expandOutputName(template, configName, seed string, now time.Time) (string, error) {
+	if seed == "" && strings.Contains(template, "{seed}") {
+		return "", fmt.Errorf("template %q uses {seed} but no seed is set", template)
+	}
+	name := strings.NewReplacer(
+		"{config}", configName,
+		"{timestamp}", now.UTC().Format(outputTimestampLayout),
+		"{seed}", seed,
+	).Replace(template)
+	if strings.ContainsAny(name, "{}") {
+		return "", fmt.Errorf("unknown placeholder in %q, expected {config}, {timestamp} or {seed}", template)
+	}
+	if name == "" {
+		return "", fmt.Errorf("template %q expands to an empty name", template)
+	}
+	return name, nil
+}
+
+// checkOutputBaseDir makes sure the output folder is a folder under -output, as the files in it are
+// deleted before every run, so an -outputName like . or ../.. can't wipe -output or its parents
+func checkOutputBaseDir(outputDir, outputBaseDir string) error {
+	rel, err := filepath.Rel(outputDir, outputBaseDir)
+	if err != nil {
+		return err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("output folder %s is not under %s", outputBaseDir, outputDir)
+	}
+	return nil
+}
+
+// log is the generator's logger, replaced in main by one with the -logFormat format
+var log = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// newLogger returns a logger writing to w in the given format, text or json
+func newLogger(format string, w io.Writer) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+}
+
 func init() {
 	// Customize the usage output
 	flag.Usage = func() {
@@ -1299,37 +3951,155 @@ func init() {
 func main() {
 	flag.Parse()
 
-	cfg, err := getConfig(*configName)
+	stdout := os.Stdout
+	if *validateOnly || *printConfig || *outputDir == stdoutOutput {
+		// Keep stdout for the JSON report, effective config or tar archive, the human-readable progress goes to stderr
+		os.Stdout = os.Stderr
+	}
+	logger, err := newLogger(*logFormat, os.Stdout)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		log.Error("invalid -logFormat", "error", err)
+		os.Exit(1)
+	}
+	log = logger
+
+	if (*check || *checkOnly) && (*tarOutput || *outputDir == stdoutOutput) {
+		log.Error("-check and -checkOnly require a folder output")
 		os.Exit(1)
 	}
 
-	fmt.Printf("Using config: %s\n", *configName)
+	if *merge != "" {
+		if err := runMerge(*merge, flag.Args()); err != nil {
+			log.Error("failed to merge networks", "error", err)
+			os.Exit(1)
+		}
+		if *check && !runArtifactsCheck(*merge) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	start := time.Now()
+	// resolveOutputBaseDir sets up the output directory (relative to genesis-generator directory) named by
+	// -outputName, seed being the value of its {seed} placeholder
+	resolveOutputBaseDir := func(seed string) string {
+		name, err := expandOutputName(*outputName, *configName, seed, start)
+		if err != nil {
+			log.Error("invalid -outputName", "error", err)
+			os.Exit(1)
+		}
+		outputBaseDir := filepath.Join(*outputDir, name)
+		if *outputDir != stdoutOutput {
+			if err := checkOutputBaseDir(*outputDir, outputBaseDir); err != nil {
+				log.Error("invalid -outputName", "error", err)
+				os.Exit(1)
+			}
+		}
+		return outputBaseDir
+	}
+
+	if *checkOnly {
+		// the config isn't loaded, so {seed} only expands to -seed
+		if !runArtifactsCheck(resolveOutputBaseDir(*seed)) {
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Validate node count
-	fmt.Println("Validating configuration...")
-	if err := validateConfig(cfg); err != nil {
-		fmt.Printf("Configuration error: %v\n", err)
+	cfg, err := getConfig(*configName)
+	if err != nil {
+		log.Error("failed to load config", "config", *configName, "error", err)
 		os.Exit(1)
 	}
 
-	// Validate committee assignments
-	fmt.Println("Validating committee assignments...")
-	if err := validateCommitteeAssignments(cfg); err != nil {
-		fmt.Printf("Committee assignment error: %v\n", err)
+	log.Info("using config", "config", *configName)
+
+	if *seed != "" {
+		cfg.General.Seed = *seed
+	}
+	if cfg.General.Seed != "" {
+		log.Info("deriving keys from the seed, the generated keys are reproducible")
+	}
+	outputBaseDir := resolveOutputBaseDir(cfg.General.Seed)
+
+	if *scale < 1 {
+		log.Error("invalid -scale, expected a factor of at least 1", "scale", *scale)
 		os.Exit(1)
 	}
+	if *scale > 1 {
+		scaleConfig(cfg, *scale)
+		validators, delegators, fullNodes, accounts := 0, 0, 0, 0
+		for _, chainCfg := range cfg.Chains {
+			validators += chainCfg.Validators.Count
+			delegators += chainCfg.Delegators.Count
+			fullNodes += chainCfg.FullNodes.Count
+			accounts += chainCfg.Accounts.Count
+		}
+		log.Info("scaled config", "scale", *scale, "nodes", cfg.Nodes.Count, "validators", validators,
+			"delegators", delegators, "full_nodes", fullNodes, "accounts", accounts)
+	}
+
+	if *countOnly {
+		if !runCountReport(cfg, *configName) {
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Set up output directory (relative to genesis-generator directory)
-	outputBaseDir := filepath.Join(*outputDir, *configName)
+	if *printConfig {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(effectiveConfig(cfg, *configName)); err != nil {
+			log.Error("failed to encode", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *validateOnly {
+		report := runValidationReport(cfg, *configName)
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Error("failed to encode", "error", err)
+			os.Exit(1)
+		}
+		if !report.Valid {
+			os.Exit(1)
+		}
+		return
+	}
 
-	fmt.Println("Deleting old files!")
+	for _, check := range validationChecks {
+		log.Info(check.Title, "check", check.Name)
+		if err := check.Run(cfg); err != nil {
+			log.Error(check.ErrPrefix, "check", check.Name, "error", err)
+			os.Exit(1)
+		}
+	}
 
-	mustSetDirectory(outputBaseDir)
-	mustDeleteInDirectory(outputBaseDir)
+	// Seeded runs stamp the archived files with the Unix epoch, so the archive headers don't differ between runs
+	archiveModTime := start
+	if cfg.General.Seed != "" {
+		archiveModTime = time.Unix(0, 0)
+	}
+	var sink outputSink
+	switch {
+	case *outputDir == stdoutOutput:
+		log.Info("writing tar archive to stdout")
+		sink = newTarSink(stdout, archiveModTime)
+	case *tarOutput:
+		log.Info("writing tar archive", "path", outputBaseDir+".tar")
+		sink = newTarFileSink(outputBaseDir+".tar", archiveModTime)
+	case *noClean:
+		log.Info("keeping old files", "path", outputBaseDir)
+		sink = newDirSink(outputBaseDir, false)
+	default:
+		log.Info("deleting old files", "path", outputBaseDir)
+		sink = newDirSink(outputBaseDir, true)
+	}
 
-	fmt.Println("Creating new files!")
+	log.Info("creating new files")
 
 	logData()
 
@@ -1343,19 +4113,7 @@ func main() {
 	sort.Strings(chainNames)
 
 	// Pre-calculate starting indices for each chain (only validators and full nodes get positive IDs)
-	chainStartIndices := make(map[string]int)
-	currentIdx := 1
-	for _, chainName := range chainNames {
-		chainCfg := cfg.Chains[chainName]
-		chainStartIndices[chainName] = currentIdx
-		// Calculate total nodes for this chain (delegators use negative IDs, so not counted here)
-		// Include regular validators + committee-only validators + full nodes
-		committeeOnlyValidators := 0
-		for _, ca := range chainCfg.Committees {
-			committeeOnlyValidators += ca.ValidatorCount
-		}
-		currentIdx += chainCfg.Validators.Count + committeeOnlyValidators + chainCfg.FullNodes.Count
-	}
+	chainStartIndices := assignChainStartIndices(cfg)
 
 	// Pre-calculate delegator starting indices (negative IDs)
 	chainDelegatorStartIndices := make(map[string]int)
@@ -1373,14 +4131,21 @@ func main() {
 	}
 
 	// Load main accounts from accounts.yml (same identities across all chains)
-	fmt.Println("Loading main accounts...")
+	log.Info("loading main accounts")
 	mainAccounts, err := loadMainAccounts()
 	if err != nil {
-		fmt.Printf("Error loading main accounts: %v\n", err)
+		log.Error("failed to load main accounts", "error", err)
 		os.Exit(1)
 	}
 	if len(mainAccounts) > 0 {
-		fmt.Printf("Loaded %d main accounts\n", len(mainAccounts))
+		log.Info("loaded main accounts", "accounts", len(mainAccounts))
+		// Main accounts are funded with each chain's default accounts amount
+		for _, chainName := range chainNames {
+			if !cfg.Chains[chainName].Accounts.Amount.hasDefault {
+				log.Error("main accounts need a default accounts amount, use a map with a default key", "chain", chainName)
+				os.Exit(1)
+			}
+		}
 		// Set password from config for each main account
 		for _, account := range mainAccounts {
 			account.Password = cfg.General.Password
@@ -1388,7 +4153,7 @@ func main() {
 	}
 
 	// Phase 1: Generate all identities for all chains
-	fmt.Println("Phase 1: Generating identities...")
+	log.Info("phase 1: generating identities")
 	chainIdentitiesMap := make(map[string][]NodeIdentity)
 	chainAccountsMap := make(map[string][]*fsm.Account)
 	chainDialPeers := make(map[int][]string)
@@ -1402,6 +4167,7 @@ func main() {
 			chainDelegatorStartIndices[chainName],
 			cfg.General.Buffer,
 			cfg.General.NetAddressSuffix,
+			cfg.General.Seed,
 			semaphoreChan,
 		)
 		chainIdentitiesMap[chainName] = identities
@@ -1431,14 +4197,15 @@ func main() {
 
 	var expandedEntries []expandedEntry
 
-	// Calculate nextExpandedID based only on validators and full nodes (not delegators)
-	baseNodeCount := 0
+	// Calculate nextExpandedID based only on validators and full nodes (not delegators), past the
+	// highest id so expanded entries never collide with a pinned idRange
+	maxNodeID := 0
 	for _, identity := range allIdentities {
 		if !identity.IsDelegate {
-			baseNodeCount++
+			maxNodeID = max(maxNodeID, identity.ID)
 		}
 	}
-	nextExpandedID := baseNodeCount + 1
+	nextExpandedID := maxNodeID + 1
 
 	// Calculate nextExpandedDelegatorID - find the lowest (most negative) delegator ID
 	// and continue from there to avoid collisions
@@ -1503,6 +4270,8 @@ func main() {
 						nextExpandedID++
 					}
 
+					// Fund the account in the target chain per the committee assignment
+					expandedIdentity.Amount = crossChainAccountAmount(cfg, identity.ChainID, committee, identity.Amount)
 					// Update chainId to match the committee (for ids.json)
 					expandedIdentity.ChainID = int(committee)
 					// Update GenesisChainID to match the committee (expanded entries go to target chain's genesis)
@@ -1558,33 +4327,73 @@ func main() {
 		}
 	}
 
+	// Borrowed validators appear in the borrowing chain's genesis (committees: [borrowing_chain]) and accounts,
+	// but are not native nodes of the borrowing chain. Regular validators are the lowest IDs of their chain
+	chainBorrowedValidators := make(map[int][]NodeIdentity)
+	for _, chainName := range chainNames {
+		chainCfg := cfg.Chains[chainName]
+		for _, lent := range chainCfg.lent {
+			for _, identity := range chainIdentitiesMap[chainName] {
+				if identity.NodeType != "validator" || identity.ID >= chainStartIndices[chainName]+lent.Count ||
+					identity.ChainID != chainCfg.ID {
+					continue
+				}
+				borrowed := identity
+				borrowed.ChainID = lent.ID
+				borrowed.GenesisChainID = lent.ID
+				borrowed.Committees = []uint64{uint64(lent.ID)}
+				borrowed.ExpandingCommittees = nil
+				chainGenesisValidators[lent.ID] = append(chainGenesisValidators[lent.ID], borrowed)
+				chainBorrowedValidators[lent.ID] = append(chainBorrowedValidators[lent.ID], borrowed)
+			}
+		}
+	}
+
 	// Phase 2: Write files for all chains
-	fmt.Println("Phase 2: Writing chain files...")
+	log.Info("phase 2: writing chain files")
+	// Per-node passwords are collected across chains for passwords.json
+	var passwords map[string]string
+	if passwordStrategy(cfg.General) == passwordPerNode {
+		passwords = make(map[string]string)
+	}
+	genesisHashes := make(map[int]string, len(chainNames))
 	for _, chainName := range chainNames {
 		chainID := cfg.Chains[chainName].ID
-		writeChainFiles(
+		genesisHashes[chainID] = writeChainFiles(
 			chainName,
 			cfg.Chains[chainName],
 			chainIdentitiesMap[chainName],
 			chainGenesisValidators[chainID],
 			chainKeystoreValidators[chainID],
+			chainBorrowedValidators[chainID],
 			chainDialPeers[chainID],
 			chainAccountsMap[chainName],
 			mainAccounts,
 			cfg.General.Password,
+			passwords,
+			keystoreIncluded(cfg.General),
+			protocolVersion(cfg.General),
 			cfg.General.JsonBeautify,
-			outputBaseDir,
+			cfg.General.WriterBuffer,
+			sink,
 		)
 	}
 
+	if passwords != nil {
+		mustSaveAsJSON(sink, "passwords.json", passwords)
+	}
+
 	// Phase 3: Generate ids.json
-	fmt.Println("Phase 3: Writing ids.json...")
+	log.Info("phase 3: writing ids.json")
 
-	// Collect root chain node IDs for distribution (only validators, not delegators or fullnodes)
+	// Collect root chain node IDs for distribution (only validators, not delegators or fullnodes), per root
+	// chain so nested chain nodes are assigned to a node of their own root chain
 	var rootChainNodeIDs []int
+	rootChainNodesByChain := make(map[int][]int) // root chain ID -> []nodeID
 	for _, entry := range expandedEntries {
 		if entry.isRootChain && entry.identity.NodeType == "validator" {
 			rootChainNodeIDs = append(rootChainNodeIDs, entry.identity.ID)
+			rootChainNodesByChain[entry.identity.ChainID] = append(rootChainNodesByChain[entry.identity.ChainID], entry.identity.ID)
 		}
 	}
 
@@ -1698,11 +4507,16 @@ func main() {
 		}
 	}
 
-	// Helper function to find the root chain node with fewest assignments
-	findLeastAssignedRootNode := func() int {
+	// Helper function to find the node of the given root chain with fewest assignments, any root chain
+	// node when the root chain has no validators of its own
+	findLeastAssignedRootNode := func(rootChainID int) int {
+		candidates := rootChainNodesByChain[rootChainID]
+		if len(candidates) == 0 {
+			candidates = rootChainNodeIDs
+		}
 		minAssignments := -1
-		selectedNode := rootChainNodeIDs[0]
-		for _, id := range rootChainNodeIDs {
+		selectedNode := candidates[0]
+		for _, id := range candidates {
 			if minAssignments == -1 || rootChainNodeAssignments[id] < minAssignments {
 				minAssignments = rootChainNodeAssignments[id]
 				selectedNode = id
@@ -1711,16 +4525,34 @@ func main() {
 		return selectedNode
 	}
 
-	// Helper function to find the root chain validator with fewest peerNode assignments
-	findLeastAssignedRootChainPeerNode := func() int {
-		minAssignments := -1
-		selectedNode := rootChainNodeIDs[0]
-		for _, id := range rootChainNodeIDs {
-			if minAssignments == -1 || peerNodeAssignments[id] < minAssignments {
-				minAssignments = peerNodeAssignments[id]
-				selectedNode = id
-			}
+	// Full nodes sync from a validator on their own chain, assigned round-robin in ID order
+	// Root chains use their own validators, nested chains use repeatedIdentity validators or, if none,
+	// committee-only validators (same priority as nested chain validators)
+	fullNodePeers := make(map[int][]int) // chainID -> []nodeID
+	for _, entry := range expandedEntries {
+		if entry.isRootChain && entry.identity.NodeType == "validator" && !entry.identity.IsDelegate {
+			fullNodePeers[entry.identity.ChainID] = append(fullNodePeers[entry.identity.ChainID], entry.identity.ID)
+		}
+	}
+	for chainID, peerIDs := range nestedChainPeerNodes {
+		fullNodePeers[chainID] = append([]int(nil), peerIDs...)
+	}
+	for chainID, peerIDs := range committeeOnlyPeerNodes {
+		if len(fullNodePeers[chainID]) == 0 {
+			fullNodePeers[chainID] = append([]int(nil), peerIDs...)
 		}
+	}
+	for _, peerIDs := range fullNodePeers {
+		sort.Ints(peerIDs)
+	}
+	fullNodesAssigned := make(map[int]int) // chainID -> full nodes assigned so far
+
+	// Helper function to pick the next validator of a chain for a full node
+	// Note: Validation ensures every chain with full nodes has at least one candidate
+	nextFullNodePeer := func(chainID int) int {
+		peerIDs := fullNodePeers[chainID]
+		selectedNode := peerIDs[fullNodesAssigned[chainID]%len(peerIDs)]
+		fullNodesAssigned[chainID]++
 		return selectedNode
 	}
 
@@ -1746,10 +4578,19 @@ func main() {
 		return selectedNode
 	}
 
+	// Per node type sleepUntil overrides, by the chain the node runs
+	chainsByID := make(map[int]*ChainConfig, len(cfg.Chains))
+	for _, chainCfg := range cfg.Chains {
+		chainsByID[chainCfg.ID] = chainCfg
+	}
+
 	// Second pass: Assign rootChainNode and peerNode to each entry
 	idsFile := IdsFile{
-		Keys: make(map[string]NodeIdentity),
+		GenesisHashes: genesisHashes,
+		Keys:          make(map[string]NodeIdentity),
 	}
+	// Reverse lookup from address to ids.json entries, built in the same pass
+	addressIndex := make(map[string][]AddressIndexEntry)
 
 	for _, entry := range expandedEntries {
 		identity := entry.identity
@@ -1769,7 +4610,7 @@ func main() {
 		} else {
 			// Nested chain node without same identity: assign to least-used root chain node
 			// Note: rootChainNodeIDs is guaranteed to be non-empty due to config validation
-			leastUsed := findLeastAssignedRootNode()
+			leastUsed := findLeastAssignedRootNode(identity.RootChainID)
 			identity.RootChainNode = &leastUsed
 			rootChainNodeAssignments[leastUsed]++
 		}
@@ -1801,22 +4642,58 @@ func main() {
 				peerNodeAssignments[leastUsed]++
 			}
 		case "fullnode":
-			if entry.isRootChain {
-				// Root chain full node: peerNode is assigned to a root chain validator (distributed evenly)
-				leastUsed := findLeastAssignedRootChainPeerNode()
-				identity.PeerNode = &leastUsed
-				peerNodeAssignments[leastUsed]++
-			} else {
-				// Nested chain full node: assign to least-used peer node
-				// Falls back to root chain validators if no repeatedIdentity validators exist
-				leastUsed := findLeastAssignedPeerNode(identity.ChainID)
-				identity.PeerNode = &leastUsed
-				peerNodeAssignments[leastUsed]++
+			// Full node: peerNode is the next validator of its own chain (round-robin)
+			peer := nextFullNodePeer(identity.ChainID)
+			identity.PeerNode = &peer
+			peerNodeAssignments[peer]++
+			// Tracked chains: the next validator of each chain, like the peerNode of its own full nodes
+			if chainCfg, ok := chainsByID[identity.ChainID]; ok {
+				for _, tracked := range chainCfg.FullNodes.Tracks {
+					identity.Tracks = append(identity.Tracks, TrackedChain{ChainID: tracked, Node: nextFullNodePeer(tracked)})
+				}
+			}
+		}
+
+		// Stagger the startup of the node type if configured, otherwise config.json's sleepUntil applies
+		if chainCfg, ok := chainsByID[identity.ChainID]; ok {
+			switch identity.NodeType {
+			case "validator":
+				identity.SleepUntil = chainCfg.Validators.SleepUntil
+			case "fullnode":
+				identity.SleepUntil = chainCfg.FullNodes.SleepUntil
 			}
 		}
 
 		key := fmt.Sprintf("node-%d", identity.ID)
 		idsFile.Keys[key] = identity
+
+		committees := identity.Committees
+		if committees == nil {
+			committees = []uint64{} // Full nodes have no committees
+		}
+		addressIndex[identity.Address] = append(addressIndex[identity.Address], AddressIndexEntry{
+			ID:         identity.ID,
+			Key:        key,
+			ChainID:    identity.ChainID,
+			NodeType:   identity.NodeType,
+			Committees: committees,
+		})
+	}
+
+	// Add the chain names to ids.json so consumers can label chains without the config
+	if cfg.General.IdsChainNames {
+		idsFile.Chains = make(map[int]string, len(cfg.Chains))
+		for chainName, chainCfg := range cfg.Chains {
+			idsFile.Chains[chainCfg.ID] = chainName
+		}
+	}
+
+	// Record the node types left out of the keystores, so the artifacts check doesn't expect them there
+	included := keystoreIncluded(cfg.General)
+	for _, nodeType := range keystoreTypes {
+		if !included[nodeType] {
+			idsFile.KeystoreExcluded = append(idsFile.KeystoreExcluded, nodeType)
+		}
 	}
 
 	// Add main accounts to ids.json
@@ -1824,9 +4701,49 @@ func main() {
 		idsFile.MainAccounts = mainAccounts
 	}
 
-	mustSaveAsJSON(filepath.Join(outputBaseDir, "ids.json"), idsFile)
+	mustSaveAsJSON(sink, "ids.json", idsFile)
+	mustSaveAsJSON(sink, "address-index.json", addressIndex)
+	summary := newSummary(cfg, *configName, start, &idsFile, len(allIdentities), rootChainNodeAssignments)
+	mustSaveAsJSON(sink, "summary.json", summary)
+	if *csvOutput {
+		mustWriteFile(sink, "ids.csv", idsCSV(&idsFile))
+	}
+
+	// Split ids.json per root chain group, so each group can be deployed on its own
+	if cfg.General.IdsPerRootChain {
+		groups, err := splitIdsByRootChain(rootChainGroups(cfg), idsFile)
+		if err != nil {
+			log.Error("failed to split ids.json per root chain", "error", err)
+			os.Exit(1)
+		}
+		rootChainIDs := make([]int, 0, len(groups))
+		for rootChainID := range groups {
+			rootChainIDs = append(rootChainIDs, rootChainID)
+		}
+		sort.Ints(rootChainIDs)
+		for _, rootChainID := range rootChainIDs {
+			name := fmt.Sprintf("ids-root-%d.json", rootChainID)
+			mustSaveAsJSON(sink, name, groups[rootChainID])
+			log.Info("written root chain ids file", "file", name, "root_chain", rootChainID, "entries", len(groups[rootChainID].Keys))
+		}
+	}
+	if err := sink.Close(); err != nil {
+		panic(err)
+	}
+
+	if *metricsFile != "" {
+		if err := writeMetricsFile(*metricsFile, summary); err != nil {
+			log.Error("failed to write the metrics file", "error", err)
+			os.Exit(1)
+		}
+		log.Info("written metrics file", "path", *metricsFile)
+	}
 
 	fmt.Println("Done!")
 	fmt.Printf("Total base nodes: %d\n", len(allIdentities))
 	fmt.Printf("Total ids.json entries (including multi-committee expansions): %d\n", len(idsFile.Keys))
+
+	if *check && !runArtifactsCheck(outputBaseDir) {
+		os.Exit(1)
+	}
 }
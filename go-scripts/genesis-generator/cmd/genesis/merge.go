@@ -0,0 +1,342 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// mergeRef matches the node-{id} references of the chain files, the config.json dial peers, the genesis.json
+// net addresses and the keystore nicknames, and the delegator-{id} keystore nicknames
+var mergeRef = regexp.MustCompile(`\b(node|delegator)-(\d+)\b`)
+
+// mergeNetwork is a generated artifacts tree read by -merge
+type mergeNetwork struct {
+	dir          string
+	ids          IdsFile
+	addressIndex map[string][]AddressIndexEntry
+	chains       map[int]string // chain id -> chain folder name
+	rootFiles    bool           // ids.json is split into ids-root-{id}.json files
+	delegators   []int          // delegator-{id} ids of the keystores
+	passwords    map[string]string
+	csv          bool
+}
+
+// loadMergeNetwork reads the ids.json, address-index.json, passwords.json and chain folders of a tree
+func loadMergeNetwork(dir string) (*mergeNetwork, error) {
+	network := &mergeNetwork{dir: dir, chains: make(map[int]string)}
+	if err := readJSON(filepath.Join(dir, "ids.json"), &network.ids); err != nil {
+		return nil, err
+	}
+	if err := readJSON(filepath.Join(dir, "address-index.json"), &network.addressIndex); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+			chainID, err := strconv.Atoi(strings.TrimPrefix(name, "chain_"))
+			if err != nil || !strings.HasPrefix(name, "chain_") {
+				return nil, fmt.Errorf("folder %s is not a chain_{id} folder", name)
+			}
+			network.chains[chainID] = name
+			keystore, err := os.ReadFile(filepath.Join(dir, name, "keystore.json"))
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			for _, ref := range mergeRef.FindAllSubmatch(keystore, -1) {
+				if string(ref[1]) == "delegator" {
+					id, _ := strconv.Atoi(string(ref[2]))
+					network.delegators = append(network.delegators, id)
+				}
+			}
+		case name == "passwords.json":
+			if err := readJSON(filepath.Join(dir, name), &network.passwords); err != nil {
+				return nil, err
+			}
+		case name == "ids.csv":
+			network.csv = true
+		case strings.HasPrefix(name, "ids-root-") && strings.HasSuffix(name, ".json"):
+			network.rootFiles = true
+		case name == "ids.json" || name == "address-index.json":
+		default:
+			log.Warn("file not merged, it describes a single network", "path", filepath.Join(dir, name))
+		}
+	}
+	return network, nil
+}
+
+// idRange returns the lowest and highest node id of the network's ids.json, 0 and 0 without entries
+func (n *mergeNetwork) idRange() (lowest, highest int) {
+	for _, identity := range n.ids.Keys {
+		if lowest == 0 || identity.ID < lowest {
+			lowest = identity.ID
+		}
+		highest = max(highest, identity.ID)
+	}
+	return lowest, highest
+}
+
+// delegatorRange returns the lowest and highest delegator-{id} id of the network's keystores, 0 and 0 without
+// delegators
+func (n *mergeNetwork) delegatorRange() (lowest, highest int) {
+	if len(n.delegators) == 0 {
+		return 0, 0
+	}
+	return slices.Min(n.delegators), slices.Max(n.delegators)
+}
+
+// renumberIds returns ids with every node id shifted by offset: the keys, ids and the rootChainNode,
+// peerNode and tracks references
+func renumberIds(ids IdsFile, offset int) IdsFile {
+	shift := func(id *int) *int {
+		if id == nil {
+			return nil
+		}
+		shifted := *id + offset
+		return &shifted
+	}
+	keys := make(map[string]NodeIdentity, len(ids.Keys))
+	for _, identity := range ids.Keys {
+		identity.ID += offset
+		identity.RootChainNode = shift(identity.RootChainNode)
+		identity.PeerNode = shift(identity.PeerNode)
+		if len(identity.Tracks) > 0 {
+			tracks := make([]TrackedChain, len(identity.Tracks))
+			for i, tracked := range identity.Tracks {
+				tracks[i] = TrackedChain{ChainID: tracked.ChainID, Node: tracked.Node + offset}
+			}
+			identity.Tracks = tracks
+		}
+		keys[fmt.Sprintf("node-%d", identity.ID)] = identity
+	}
+	ids.Keys = keys
+	return ids
+}
+
+// renumberRefs shifts the node-{id} references of a chain file by nodeOffset and its delegator-{id} ones by
+// delegatorOffset
+func renumberRefs(data []byte, nodeOffset, delegatorOffset int) []byte {
+	if nodeOffset == 0 && delegatorOffset == 0 {
+		return data
+	}
+	return mergeRef.ReplaceAllFunc(data, func(ref []byte) []byte {
+		match := mergeRef.FindSubmatch(ref)
+		id, _ := strconv.Atoi(string(match[2]))
+		if string(match[1]) == "node" {
+			return fmt.Appendf(nil, "node-%d", id+nodeOffset)
+		}
+		return fmt.Appendf(nil, "delegator-%d", id+delegatorOffset)
+	})
+}
+
+// runMerge merges the artifacts trees of two separately generated networks into dest, so they deploy as one
+// multi root chain network. The chain ids of the networks must not collide. The node ids of the second
+// network are shifted past the first network's when their ranges overlap, its node-{id} references
+// with them. Every rootChainNode and peerNode stays within its network, as the root chains of one network
+// have no nodes in the other
+func runMerge(dest string, inputs []string) error {
+	if len(inputs) != 2 {
+		return fmt.Errorf("expected the paths of two artifact folders, got %d arguments", len(inputs))
+	}
+	for _, input := range inputs {
+		rel, err := filepath.Rel(input, dest)
+		if err != nil {
+			return err
+		}
+		if rel == "." || !strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("output folder %s is inside the input %s, which is deleted before merging", dest, input)
+		}
+		if rel, err = filepath.Rel(dest, input); err == nil && !strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("input %s is inside the output folder %s, which is deleted before merging", input, dest)
+		}
+	}
+	a, err := loadMergeNetwork(inputs[0])
+	if err != nil {
+		return fmt.Errorf("load %s: %w", inputs[0], err)
+	}
+	b, err := loadMergeNetwork(inputs[1])
+	if err != nil {
+		return fmt.Errorf("load %s: %w", inputs[1], err)
+	}
+
+	var collisions []string
+	for _, chainID := range slices.Sorted(maps.Keys(b.chains)) {
+		if _, ok := a.chains[chainID]; ok {
+			collisions = append(collisions, strconv.Itoa(chainID))
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("chain ids %s are in both networks, regenerate one of them with other chain ids",
+			strings.Join(collisions, ", "))
+	}
+
+	// Shift the second network's node ids to start right after the first's, unless they already do
+	_, highestA := a.idRange()
+	lowestB, _ := b.idRange()
+	offset := 0
+	if lowestB != 0 && lowestB <= highestA {
+		offset = highestA - lowestB + 1
+	}
+	// Delegators are only in the keystores and passwords.json, by their delegator-{id} nicknames, which are
+	// shifted the same way so the merged passwords.json keeps one password per nickname
+	_, highestDelegatorA := a.delegatorRange()
+	lowestDelegatorB, _ := b.delegatorRange()
+	delegatorOffset := 0
+	if lowestDelegatorB != 0 && lowestDelegatorB <= highestDelegatorA {
+		delegatorOffset = highestDelegatorA - lowestDelegatorB + 1
+	}
+	log.Info("merging networks", "first", a.dir, "second", b.dir, "second_id_offset", offset,
+		"second_delegator_offset", delegatorOffset)
+
+	renumbered := renumberIds(b.ids, offset)
+	merged := IdsFile{
+		Chains:       maps.Clone(a.ids.Chains),
+		MainAccounts: maps.Clone(a.ids.MainAccounts),
+		Keys:         maps.Clone(a.ids.Keys),
+	}
+	maps.Copy(merged.Keys, renumbered.Keys)
+	if renumbered.Chains != nil {
+		if merged.Chains == nil {
+			merged.Chains = make(map[int]string)
+		}
+		maps.Copy(merged.Chains, renumbered.Chains)
+	}
+	if renumbered.MainAccounts != nil {
+		if merged.MainAccounts == nil {
+			merged.MainAccounts = make(map[string]*MainAccount)
+		}
+		for _, name := range slices.Sorted(maps.Keys(renumbered.MainAccounts)) {
+			account := renumbered.MainAccounts[name]
+			if existing, ok := merged.MainAccounts[name]; ok && existing.Address != account.Address {
+				return fmt.Errorf("main account %s has address %s in one network and %s in the other", name,
+					existing.Address, account.Address)
+			}
+			merged.MainAccounts[name] = account
+		}
+	}
+	// A node type left out of either network's keystores is left out of the merged ones
+	for _, nodeType := range keystoreTypes {
+		if slices.Contains(a.ids.KeystoreExcluded, nodeType) || slices.Contains(b.ids.KeystoreExcluded, nodeType) {
+			merged.KeystoreExcluded = append(merged.KeystoreExcluded, nodeType)
+		}
+	}
+
+	addressIndex := a.addressIndex
+	if addressIndex == nil {
+		addressIndex = make(map[string][]AddressIndexEntry)
+	}
+	for address, entries := range b.addressIndex {
+		for _, entry := range entries {
+			entry.ID += offset
+			entry.Key = fmt.Sprintf("node-%d", entry.ID)
+			addressIndex[address] = append(addressIndex[address], entry)
+		}
+	}
+
+	sink := newDirSink(dest, !*noClean)
+	// Copy the chain folders, renumbering the second network's node references and genesis hashes
+	for _, network := range []*mergeNetwork{a, b} {
+		networkOffset, networkDelegatorOffset := 0, 0
+		if network == b {
+			networkOffset, networkDelegatorOffset = offset, delegatorOffset
+		}
+		for _, chainID := range slices.Sorted(maps.Keys(network.chains)) {
+			folder := network.chains[chainID]
+			files, err := os.ReadDir(filepath.Join(network.dir, folder))
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				data, err := os.ReadFile(filepath.Join(network.dir, folder, file.Name()))
+				if err != nil {
+					return err
+				}
+				data = renumberRefs(data, networkOffset, networkDelegatorOffset)
+				if file.Name() == "genesis.json" {
+					if merged.GenesisHashes == nil {
+						merged.GenesisHashes = make(map[int]string)
+					}
+					sum := sha256.Sum256(data)
+					merged.GenesisHashes[chainID] = hex.EncodeToString(sum[:])
+				}
+				mustWriteFile(sink, path.Join(folder, file.Name()), data)
+			}
+		}
+	}
+
+	if a.passwords != nil || b.passwords != nil {
+		passwords := maps.Clone(a.passwords)
+		if passwords == nil {
+			passwords = make(map[string]string)
+		}
+		for _, nickname := range slices.Sorted(maps.Keys(b.passwords)) {
+			renumbered := string(renumberRefs([]byte(nickname), offset, delegatorOffset))
+			if existing, ok := passwords[renumbered]; ok && existing != b.passwords[nickname] {
+				return fmt.Errorf("password of %s differs between the networks", renumbered)
+			}
+			passwords[renumbered] = b.passwords[nickname]
+		}
+		mustSaveAsJSON(sink, "passwords.json", passwords)
+	}
+
+	// The merged ids.json is split again, so the network that wasn't split gets its ids-root-{id}.json files too
+	if a.rootFiles || b.rootFiles {
+		rootChains := make(map[int]int, len(merged.Chains))
+		for _, identity := range merged.Keys {
+			rootChains[identity.ChainID] = identity.RootChainID
+		}
+		for _, network := range []*mergeNetwork{a, b} {
+			for chainID := range network.chains {
+				if _, ok := rootChains[chainID]; !ok {
+					rootChains[chainID] = chainID
+				}
+			}
+		}
+		groups, err := splitIdsByRootChain(groupRootChains(rootChains), merged)
+		if err != nil {
+			return err
+		}
+		for _, rootChainID := range slices.Sorted(maps.Keys(groups)) {
+			mustSaveAsJSON(sink, fmt.Sprintf("ids-root-%d.json", rootChainID), groups[rootChainID])
+		}
+	}
+
+	mustSaveAsJSON(sink, "ids.json", merged)
+	mustSaveAsJSON(sink, "address-index.json", addressIndex)
+	if a.csv || b.csv {
+		// ids.json leaves out the committees, so they're taken from the address index
+		committees := make(map[string][]uint64)
+		for _, entries := range addressIndex {
+			for _, entry := range entries {
+				committees[entry.Key] = entry.Committees
+			}
+		}
+		withCommittees := merged
+		withCommittees.Keys = make(map[string]NodeIdentity, len(merged.Keys))
+		for key, identity := range merged.Keys {
+			identity.Committees = committees[key]
+			withCommittees.Keys[key] = identity
+		}
+		mustWriteFile(sink, "ids.csv", idsCSV(&withCommittees))
+	}
+	if err := sink.Close(); err != nil {
+		return err
+	}
+
+	log.Info("merged networks", "path", dest, "chains", len(a.chains)+len(b.chains), "ids_entries", len(merged.Keys),
+		"first_entries", len(a.ids.Keys), "second_entries", len(b.ids.Keys))
+	return nil
+}
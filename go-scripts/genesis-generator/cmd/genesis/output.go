@@ -0,0 +1,121 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// outputSink is where the generated artifacts are written, names are slash separated paths relative to
+// the output root (e.g. "chain_1/genesis.json")
+type outputSink interface {
+	// Create returns a writer for the named file, the file is complete once the writer is closed
+	Create(name string) (io.WriteCloser, error)
+	// Close finishes writing the output
+	Close() error
+}
+
+// dirSink writes the artifacts to a directory on the filesystem
+type dirSink struct {
+	root string
+}
+
+// newDirSink creates the output directory, deleting the files of a previous run when clean is set
+func newDirSink(root string, clean bool) *dirSink {
+	mustSetDirectory(root)
+	if clean {
+		mustDeleteInDirectory(root)
+	}
+	return &dirSink{root: root}
+}
+
+func (d *dirSink) Create(name string) (io.WriteCloser, error) {
+	path := filepath.Join(d.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+func (d *dirSink) Close() error { return nil }
+
+// tarSink writes the artifacts as a tar archive, keeping the directory layout. Tar headers need the
+// file size upfront, so each file is buffered in memory until closed
+type tarSink struct {
+	mu      sync.Mutex
+	w       *tar.Writer
+	file    *os.File // archive file closed with the sink, nil when writing to a stream
+	modTime time.Time
+}
+
+// newTarSink writes the archive to w, every file stamped with modTime
+func newTarSink(w io.Writer, modTime time.Time) *tarSink {
+	return &tarSink{w: tar.NewWriter(w), modTime: modTime}
+}
+
+// newTarFileSink creates the archive file, replacing the one of a previous run
+func newTarFileSink(path string, modTime time.Time) *tarSink {
+	mustSetDirectory(filepath.Dir(path))
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	sink := newTarSink(file, modTime)
+	sink.file = file
+	return sink
+}
+
+func (t *tarSink) Create(name string) (io.WriteCloser, error) {
+	return &tarFile{sink: t, name: name}, nil
+}
+
+func (t *tarSink) Close() error {
+	if err := t.w.Close(); err != nil {
+		return err
+	}
+	if t.file != nil {
+		return t.file.Close()
+	}
+	return nil
+}
+
+// tarFile buffers a file until it is closed and appended to the archive
+type tarFile struct {
+	bytes.Buffer
+	sink *tarSink
+	name string
+}
+
+func (f *tarFile) Close() error {
+	f.sink.mu.Lock()
+	defer f.sink.mu.Unlock()
+	header := &tar.Header{
+		Name:    f.name,
+		Mode:    0644,
+		Size:    int64(f.Len()),
+		ModTime: f.sink.modTime,
+	}
+	if err := f.sink.w.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := f.sink.w.Write(f.Bytes())
+	return err
+}
+
+// mustWriteFile writes data to the named file of the sink
+func mustWriteFile(sink outputSink, name string, data []byte) {
+	file, err := sink.Create(name)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := file.Write(data); err != nil {
+		panic(err)
+	}
+	if err := file.Close(); err != nil {
+		panic(err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -121,12 +122,16 @@ func main() {
 	configMaps := buildConfigMapsFromData(*namespace, dataByType)
 	// apply ConfigMaps
 	for _, configmap := range configMaps {
-		err := applyConfigMap(ctx, clientset, *namespace, configmap.Name, configmap)
+		changed, err := applyConfigMap(ctx, clientset, *namespace, configmap.Name, configmap)
 		if err != nil {
 			log.Error("failed to ensure configmap",
 				slog.String("err", err.Error()), slog.String("kubeconfig", *kubeconfig))
 			os.Exit(1)
 		}
+		if !changed {
+			log.Info("unchanged configmap", slog.String("name", configmap.Name), slog.Int("keys", len(configmap.Data)))
+			continue
+		}
 		log.Info("applied configmap", slog.String("name", configmap.Name), slog.Int("keys", len(configmap.Data)))
 	}
 	// parse the ids file
@@ -173,7 +178,12 @@ func buildDataMaps(basePath string, fileTypes []string, ext string, idsFile stri
 			}
 			// retrieve the file
 			path := filepath.Join(basePath, chain, fileType+ext)
-			contents, err := readJSONFile(path)
+			// genesis is stored as generated, init-node checks its bytes against the ids file genesis-hashes
+			read := readJSONFile
+			if fileType == genesisFile {
+				read = readJSONFileVerbatim
+			}
+			contents, err := read(path)
 			if err != nil {
 				return nil, fmt.Errorf("read %s: %w", path, err)
 			}
@@ -282,29 +292,46 @@ func readJSONFile(path string) ([]byte, error) {
 	return pretty, nil
 }
 
-// applyConfigMap creates the configmap or updates it if it already exists.
+// readJSONFileVerbatim reads a JSON file and returns its bytes unchanged, after checking they are valid JSON.
+func readJSONFileVerbatim(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file [path: %s]: %w", path, err)
+	}
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("invalid JSON [path: %s]", path)
+	}
+	return b, nil
+}
+
+// applyConfigMap creates the configmap or updates it if it already exists, skipping the update when its
+// data is unchanged so pods watching it aren't reloaded. It reports whether the configmap was written.
 func applyConfigMap(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string,
-	configMap *corev1.ConfigMap) error {
+	configMap *corev1.ConfigMap) (bool, error) {
 	cmClient := clientset.CoreV1().ConfigMaps(namespace)
 	_, err := cmClient.Create(ctx, configMap, metav1.CreateOptions{})
 	if err == nil {
-		return nil
+		return true, nil
 	}
 	if !apierrors.IsAlreadyExists(err) {
-		return fmt.Errorf("create ConfigMap %s/%s: %w", namespace, name, err)
+		return false, fmt.Errorf("create ConfigMap %s/%s: %w", namespace, name, err)
 	}
 	// the configmap already exists, try to update it
 	existing, err := cmClient.Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("get ConfigMap %s/%s: %w", namespace, name, err)
+		return false, fmt.Errorf("get ConfigMap %s/%s: %w", namespace, name, err)
+	}
+	// skip the update when nothing changed, as it would bump the resource version
+	if maps.Equal(existing.Data, configMap.Data) {
+		return false, nil
 	}
 	// overwrite data (this replaces the Data map entirely).
 	existing.Data = configMap.Data
 	_, err = cmClient.Update(ctx, existing, metav1.UpdateOptions{})
 	if err != nil {
-		return fmt.Errorf("update ConfigMap %s/%s: %w", namespace, name, err)
+		return false, fmt.Errorf("update ConfigMap %s/%s: %w", namespace, name, err)
 	}
-	return nil
+	return true, nil
 }
 
 // getChains iterates over the ids file and returns a map of chainID->nodes
@@ -5,6 +5,7 @@ go 1.25.3
 require (
 	github.com/canopy-network/canopy v0.1.16-0.20260202170619-a05a50dc2fb1
 	github.com/launchdarkly/go-jsonstream/v3 v3.1.0
+	github.com/prometheus/client_golang v1.23.2
 	golang.org/x/sync v0.17.0
 	google.golang.org/protobuf v1.36.10
 	gopkg.in/yaml.v3 v3.0.1
@@ -81,7 +82,6 @@ require (
 	github.com/oasisprotocol/curve25519-voi v0.0.0-20230904125328-1f23a7beb09a // indirect
 	github.com/phuslu/iploc v1.0.20240731 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/prometheus/client_golang v1.23.2 // indirect
 	github.com/prometheus/client_model v0.6.2 // indirect
 	github.com/prometheus/common v0.67.1 // indirect
 	github.com/prometheus/procfs v0.19.1 // indirect
@@ -10,6 +10,8 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -45,7 +47,9 @@ const (
 
 // Keys is the map of node keys
 type Keys struct {
-	Keys map[string]NodeKey `json:"keys"`
+	// GenesisHashes is the SHA-256 (hex) of each chain's generated genesis file by chain id
+	GenesisHashes map[int]string     `json:"genesis-hashes"`
+	Keys          map[string]NodeKey `json:"keys"`
 }
 
 // NodeKey is the structure representing the node key information in order to initialize the node
@@ -59,10 +63,19 @@ type NodeKey struct {
 	PublicKey     string `json:"publicKey"`
 	PrivateKey    string `json:"privateKey"`
 	NodeType      string `json:"nodeType"`
+	SleepUntil    int    `json:"sleepUntil"` // optional: overrides the config's sleepUntil for this node
+	// optional: other chains a full node follows, each through the RPC of one of its nodes
+	Tracks []TrackedChain `json:"tracks"`
 	// optional: domain to use when assigning node's external address
 	Domain string `json:"domain"`
 }
 
+// TrackedChain is a chain a full node follows besides its own, through the RPC of the node with id Node
+type TrackedChain struct {
+	ChainID int `json:"chainId"`
+	Node    int `json:"node"`
+}
+
 func main() {
 	// create a default logger
 	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
@@ -113,6 +126,20 @@ func main() {
 			slog.String("dst", dst))
 		os.Exit(1)
 	}
+	// verify the genesis file matches the generated one, when its hash was recorded
+	if want, ok := nodes.GenesisHashes[node.ChainID]; ok {
+		got, err := fileHash(dst)
+		if err != nil {
+			log.Error("failed to hash genesis file", slog.String("err", err.Error()), slog.String("dst", dst))
+			os.Exit(1)
+		}
+		if got != want {
+			log.Error("genesis file does not match the generated one",
+				slog.String("src", src), slog.String("sha256", got), slog.String("expected", want))
+			os.Exit(1)
+		}
+		log.Info("verified genesis file", slog.String("sha256", got))
+	}
 	// copy the keystore file to the canopy directory
 	src = fullFilePath(configPath, indexedFileName(keystoreFile, node.ChainID), configFileExt)
 	dst = fullFilePath(canopyPath, keystoreFile, configFileExt)
@@ -151,8 +178,20 @@ func main() {
 		log.Error("failed to find peer node", slog.String("peerNodeKey", peerNodeKey))
 		os.Exit(1)
 	}
+	// and for the nodes of the tracked chains
+	trackedNodes := make([]NodeKey, 0, len(node.Tracks))
+	for _, tracked := range node.Tracks {
+		trackedNodeKey := fmt.Sprintf("%s%d", podPrefix, tracked.Node)
+		trackedNode, ok := nodes.Keys[trackedNodeKey]
+		if !ok {
+			log.Error("failed to find tracked chain node", slog.String("trackedNodeKey", trackedNodeKey),
+				slog.Int("chainId", tracked.ChainID))
+			os.Exit(1)
+		}
+		trackedNodes = append(trackedNodes, trackedNode)
+	}
 	// perform the substitutions
-	modifyConfig(&config, podPrefix, &node, &rootNode, &peerNode)
+	modifyConfig(&config, podPrefix, &node, &rootNode, &peerNode, trackedNodes)
 	// encode to save it as a file
 	rawConfig, err = json.MarshalIndent(config, "", "  ")
 	if err != nil {
@@ -236,8 +275,22 @@ func copy(src, dst string) error {
 	return err
 }
 
+// fileHash returns the hex encoded SHA-256 of the file at path
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // modifyConfig applies the config modifications for the specific node
-func modifyConfig(config *Config, nodePrefix string, node, rootNode, peerNode *NodeKey) {
+func modifyConfig(config *Config, nodePrefix string, node, rootNode, peerNode *NodeKey, trackedNodes []NodeKey) {
 	// modify the node id for the root and nested chain
 	for idx := range config.RootChain {
 		chain := &config.RootChain[idx]
@@ -248,6 +301,17 @@ func modifyConfig(config *Config, nodePrefix string, node, rootNode, peerNode *N
 		}
 		chain.URL = buildNodeAddress(true, nodePrefix, chainNode, ":50002")
 	}
+	// follow the tracked chains through the rpc of their nodes
+	for idx := range trackedNodes {
+		config.RootChain = append(config.RootChain, RootChain{
+			ChainID: trackedNodes[idx].ChainID,
+			URL:     buildNodeAddress(true, nodePrefix, &trackedNodes[idx], ":50002"),
+		})
+	}
+	// if set, stagger the node startup with its own sleepUntil
+	if node.SleepUntil != 0 {
+		config.SleepUntil = node.SleepUntil
+	}
 	// if set, apply the TCPDomain as the external address
 	if config.ExternalAddress = node.Domain; config.ExternalAddress == "" {
 		// otherwise, change the external address to itself so it can be discovered by the network
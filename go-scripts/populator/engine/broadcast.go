@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// Broadcaster fans out values of type T from a single source channel to multiple subscribers (no buffers).
+// Values a subscriber isn't ready for are dropped and counted, so the skipped blocks are reported.
+type Broadcaster[T any] struct {
+	log     *slog.Logger
+	names   []string
+	subs    []chan T
+	dropped []atomic.Uint64
+	done    []atomic.Bool
+}
+
+// NewBroadcaster creates a broadcaster that relays values from src to a subscriber per name, the names
+// label the dropped values in the logs. When src closes, all subscriber channels are closed.
+func NewBroadcaster[T any](log *slog.Logger, src <-chan T, names ...string) *Broadcaster[T] {
+	b := &Broadcaster[T]{
+		log:     log,
+		names:   names,
+		subs:    make([]chan T, len(names)),
+		dropped: make([]atomic.Uint64, len(names)),
+		done:    make([]atomic.Bool, len(names)),
+	}
+	for i := range names {
+		b.subs[i] = make(chan T)
+	}
+	go func() {
+		for v := range src {
+			for i, ch := range b.subs {
+				if b.done[i].Load() {
+					continue
+				}
+				select {
+				case ch <- v:
+					// sent successfully
+				default:
+					// channel full or not ready, skip
+					dropped := b.dropped[i].Add(1)
+					b.log.Warn("broadcaster dropped value, subscriber not ready",
+						slog.String("subscriber", b.names[i]), slog.Any("value", v),
+						slog.Uint64("dropped", dropped))
+				}
+			}
+		}
+		for _, ch := range b.subs {
+			close(ch)
+		}
+	}()
+	return b
+}
+
+// Channels returns the subscriber receive-only channels.
+func (b *Broadcaster[T]) Channels() []<-chan T {
+	outs := make([]<-chan T, len(b.subs))
+	for i, ch := range b.subs {
+		outs[i] = ch
+	}
+	return outs
+}
+
+// Done marks subscriber i as no longer listening, the values it's not sent aren't counted as dropped.
+func (b *Broadcaster[T]) Done(i int) {
+	b.done[i].Store(true)
+}
+
+// LogDropped warns about every subscriber that had values dropped, returning whether any had.
+func (b *Broadcaster[T]) LogDropped() bool {
+	found := false
+	for i, name := range b.names {
+		if dropped := b.dropped[i].Load(); dropped > 0 {
+			found = true
+			b.log.Warn("subscriber missed values, its schedule wasn't fully executed",
+				slog.String("subscriber", name), slog.Uint64("dropped", dropped))
+		}
+	}
+	return found
+}
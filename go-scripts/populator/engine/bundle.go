@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/canopy-network/canopy/cmd/rpc"
+	"github.com/canopy-network/canopy/lib"
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+	"google.golang.org/protobuf/proto"
+)
+
+// TxBundle is the scheduled transactions of a profile built and signed offline with -build, to be
+// submitted separately with -submit
+type TxBundle struct {
+	ChainId   uint64        `json:"chainId"`
+	NetworkId uint64        `json:"networkId"`
+	Entries   []BundleEntry `json:"entries"`
+}
+
+// BundleEntry is the signed transactions of a scheduled tx, submitted at the first height at or after
+// Height. Transactions are signed with the chain height they are due at as their created height, which
+// the node accepts for 4320 blocks around it: Height, or for incremental profiles -buildHeight plus Height
+type BundleEntry struct {
+	Key       string   `json:"key"` // scheduled tx id, or its kind and index
+	Kind      TxType   `json:"kind"`
+	Height    uint64   `json:"height"`
+	From      string   `json:"from"`                // sender address
+	BatchSize uint     `json:"batchSize,omitempty"` // transactions per submit request, all at once when 0
+	Txs       []string `json:"txs"`                 // hex encoded signed transactions
+
+	// signed are the decoded Txs, set when loading the bundle
+	signed []lib.TransactionI
+}
+
+// BuildBundle builds and signs every scheduled transaction of the profile whose message is built
+// locally, returning the bundle and the number of transactions that failed to build. The heights of
+// incremental profiles count blocks from baseHeight, the chain height the bundle is submitted from
+func BuildBundle(log *slog.Logger, profile *Profile, accounts []shared.Account,
+	baseHeight uint64) (bundle *TxBundle, failed int) {
+	bundle = &TxBundle{ChainId: profile.General.ChainId, NetworkId: profile.General.NetworkId}
+	for _, s := range scheduledTxs(profile) {
+		attrs := []any{
+			slog.String("tx", s.key),
+			slog.String("kind", string(s.tx.Kind())),
+			slog.Uint64("height", s.tx.Schedule().Height),
+		}
+		entry, err := buildBundleEntry(s, profile.General, accounts, baseHeight)
+		switch {
+		case errors.Is(err, ErrNotBuiltLocally):
+			log.Warn("skipped tx, it can't be signed offline", append(attrs, slog.String("reason", err.Error()))...)
+		case err != nil:
+			failed++
+			log.Error("failed to build tx", append(attrs, slog.String("error", err.Error()))...)
+		default:
+			bundle.Entries = append(bundle.Entries, entry)
+			log.Info("built tx", append(attrs, slog.Int("txs", len(entry.Txs)))...)
+		}
+	}
+	return bundle, failed
+}
+
+// buildBundleEntry signs the transactions of a scheduled tx, its batch count of them for batch txs
+func buildBundleEntry(s *scheduledTx, config General, accounts []shared.Account, baseHeight uint64) (BundleEntry, error) {
+	tx := s.tx
+	if tx.Sender() < 0 || tx.Sender() >= len(accounts) || tx.Receiver() < 0 || tx.Receiver() >= len(accounts) {
+		return BundleEntry{}, fmt.Errorf("account index out of range, accounts: %d", len(accounts))
+	}
+	msgTx, ok := tx.(MsgTx)
+	if !ok {
+		return BundleEntry{}, ErrNotBuiltLocally
+	}
+	height := tx.Schedule().Height
+	createdHeight := height
+	if config.Incremental {
+		createdHeight += baseHeight
+	}
+	req, err := BuildTxRequest(accounts[tx.Sender()], accounts[tx.Receiver()], config, max(createdHeight, verifyHeight), 1)
+	if err != nil {
+		return BundleEntry{}, fmt.Errorf("build tx request: %w", err)
+	}
+	msg, err := msgTx.Msg(req)
+	if err != nil {
+		return BundleEntry{}, fmt.Errorf("build msg: %w", err)
+	}
+	entry := BundleEntry{Key: s.key, Kind: tx.Kind(), Height: height, From: req.FromAddr.String()}
+	msgs := []proto.Message{msg}
+	if bulk, ok := tx.(BulkTx); ok && tx.IsBatch() {
+		if cycling, ok := tx.(CyclingBulkTx); ok {
+			// a single cycle over all the messages, as the live batches continue the cycle of the previous ones
+			if msgs, err = cycling.Msgs(req, bulk.Count()); err != nil {
+				return BundleEntry{}, fmt.Errorf("build msgs: %w", err)
+			}
+		} else {
+			msgs = make([]proto.Message, 0, bulk.Count())
+			for range bulk.Count() {
+				msgs = append(msgs, msg)
+			}
+		}
+		entry.BatchSize = bulk.BatchSize()
+	}
+	txs, err := BuildTransactions(req, msgs)
+	if err != nil {
+		return BundleEntry{}, err
+	}
+	for _, signed := range txs {
+		bz, err := lib.Marshal(signed)
+		if err != nil {
+			return BundleEntry{}, fmt.Errorf("marshal tx: %w", err)
+		}
+		entry.Txs = append(entry.Txs, hex.EncodeToString(bz))
+	}
+	return entry, nil
+}
+
+// WriteBundle writes the bundle as JSON to path
+func WriteBundle(path string, bundle *TxBundle) error {
+	raw, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode bundle: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		return fmt.Errorf("write bundle %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadBundle reads and decodes the signed transactions of a bundle written by WriteBundle
+func LoadBundle(path string) (*TxBundle, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load bundle %s: %w", path, err)
+	}
+	bundle := new(TxBundle)
+	if err := json.Unmarshal(raw, bundle); err != nil {
+		return nil, fmt.Errorf("parse bundle %s: %w", path, err)
+	}
+	var errs error
+	for i := range bundle.Entries {
+		entry := &bundle.Entries[i]
+		for j, encoded := range entry.Txs {
+			bz, err := hex.DecodeString(encoded)
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("bundle %s tx %s #%d: %w", path, entry.Key, j, err))
+				continue
+			}
+			tx := new(lib.Transaction)
+			if err := lib.Unmarshal(bz, tx); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("bundle %s tx %s #%d: %w", path, entry.Key, j, err))
+				continue
+			}
+			entry.signed = append(entry.signed, tx)
+		}
+	}
+	return bundle, errs
+}
+
+// SubmitBundle submits every bundle entry at the first height at or after its own, returning the number
+// of submitted and failed transactions once all entries were submitted or the notifier closed
+func SubmitBundle(log *slog.Logger, client *rpc.Client, notifier <-chan HeightCh, bundle *TxBundle,
+	incremental bool) (submitted, failed int) {
+	pending := bundle.Entries
+	for heightInfo := range notifier {
+		height := heightInfo.Height
+		if incremental {
+			height = heightInfo.Counter
+		}
+		remaining := pending[:0]
+		for _, entry := range pending {
+			if entry.Height > height {
+				remaining = append(remaining, entry)
+				continue
+			}
+			txLog := log.With(slog.String("tx", entry.Key), slog.String("type", string(entry.Kind)),
+				slog.Uint64("height", height), slog.String("address", entry.From))
+			hashes, success, errors, err := submitBundleEntry(client, entry)
+			logTxResult(txLog, hashes, success, errors, err)
+			submitted += success
+			failed += errors
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			break
+		}
+	}
+	return submitted, failed
+}
+
+// submitBundleEntry submits the signed transactions of an entry to client, in parallel requests of BatchSize
+func submitBundleEntry(client *rpc.Client, entry BundleEntry) (hashes []string, success, errs int, err error) {
+	batchSize := len(entry.signed)
+	if entry.BatchSize > 0 {
+		batchSize = int(entry.BatchSize)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for start := 0; start < len(entry.signed); start += batchSize {
+		batch := entry.signed[start:min(start+batchSize, len(entry.signed))]
+		wg.Go(func() {
+			batchHashes, batchErr := SubmitTransactions(client, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			if batchErr != nil {
+				err = batchErr
+				errs += len(batch)
+				return
+			}
+			for _, hash := range batchHashes {
+				hashes = append(hashes, *hash)
+			}
+			success += len(batch)
+		})
+	}
+	wg.Wait()
+	return hashes, success, errs, err
+}
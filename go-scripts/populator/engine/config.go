@@ -0,0 +1,960 @@
+package engine
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canopy-network/canopy/cmd/rpc"
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+)
+
+// defaults for the general config fields left unset
+const (
+	defaultBaseFee              = uint64(10_000)         // base fee for transactions
+	defaultRetries              = 5                      // number of retries for failed requests
+	defaultTimeoutMs            = 5_000                  // milliseconds before each request times out
+	defaultBlockCheckIntervalMs = 500                    // milliseconds between new block checks
+	defaultSubsidyRoute         = "/v1/admin/tx-subsidy" // admin RPC path of the subsidy tx
+	defaultConfirmConcurrency   = 10                     // tx inclusion queries of confirmTxs run at once
+)
+
+var (
+	// default http/canopy client for making requests
+	httpClient = &http.Client{}
+	cnpyClient *rpc.Client
+)
+
+// SetCanopyClient sets the canopy global client for making requests and the raw admin route client, which
+// sends through transport. The canopy client can't be given a transport, see SetDefaultTransport
+func SetCanopyClient(rpcURL, adminRPCURL string, transport *http.Transport) {
+	httpClient.Transport = transport
+	cnpyClient = rpc.NewClient(rpcURL, adminRPCURL)
+}
+
+// SetDefaultTransport replaces the process-wide http.DefaultTransport with transport. The canopy clients'
+// http.Client is unexported and has no transport of its own, so this is the only way to tune theirs; it also
+// applies to every other request of the process that doesn't set a transport
+func SetDefaultTransport(transport *http.Transport) {
+	http.DefaultTransport = transport
+}
+
+// Profile is a configuration for a single profile
+type Profile struct {
+	General      General      `yaml:"general"`
+	Send         SendTx       `yaml:"send"`         // handled separately
+	Heartbeat    HeartbeatTx  `yaml:"heartbeat"`    // handled separately
+	Transactions Transactions `yaml:"transactions"` // height-driven ones
+	Warmup       Warmup       `yaml:"warmup"`       // runs once before the height-driven ones
+	DexSetup     DexSetup     `yaml:"dexSetup"`     // runs once after the warmup, before the height-driven ones
+	// Schedule is an optional CSV file whose txs are added to Transactions, relative to the config file
+	Schedule string `yaml:"schedule"`
+	// Phases are stages run in order, each starting once every tx of the previous one is confirmed
+	Phases []Phase `yaml:"phases"`
+	// Order sets which tx types run first when several are due at the same height, unlisted types
+	// follow in their default order
+	Order []TxType `yaml:"order"`
+}
+
+// Validate validates the profile configuration
+func (p *Profile) Validate() error {
+	p.General.RpcURL = os.Getenv("RPC_URL")
+	p.General.AdminRpcURL = os.Getenv("ADMIN_RPC_URL")
+	var errs error
+	required := func(field string) error { return fmt.Errorf("%s is required", field) }
+	if _, err := url.Parse(p.General.RpcURL); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("rpcURL: %w", err))
+	}
+	if _, err := url.Parse(p.General.AdminRpcURL); err != nil {
+		errs = errors.Join(errs, fmt.Errorf("adminRpcURL: %w", err))
+	}
+	if p.General.ChainId == 0 {
+		errs = errors.Join(errs, required("chain"))
+	}
+	errs = errors.Join(errs, p.General.setDefaults())
+	if p.Send.ConcurrencyRamp.Enabled {
+		if p.Send.IsBatch() {
+			errs = errors.Join(errs, errors.New("concurrencyRamp: not supported for batch sends"))
+		}
+		errs = errors.Join(errs, p.Send.ConcurrencyRamp.setDefaults(p.Send.Concurrency))
+	}
+	if p.Send.Spike.Enabled {
+		if p.Send.ConcurrencyRamp.Enabled {
+			errs = errors.Join(errs, errors.New("spike: not supported with concurrencyRamp"))
+		}
+		errs = errors.Join(errs, p.Send.Spike.setDefaults())
+	}
+	if _, err := NewDependencies(p); err != nil {
+		errs = errors.Join(errs, err)
+	}
+	errs = errors.Join(errs, validateOrderLifecycles("orderLifecycle", p.Transactions.OrderLifecycle))
+	for i, tx := range p.Transactions.EditStake {
+		if err := tx.stakerRole.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("editStake %d: %w", i, err))
+		}
+	}
+	for i, tx := range p.Transactions.Unstake {
+		if err := tx.stakerRole.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("unstake %d: %w", i, err))
+		}
+	}
+	if fund := p.Warmup.Fund; fund != nil {
+		if fund.Source == "" {
+			errs = errors.Join(errs, fmt.Errorf("warmup fund: %w", required("source")))
+		}
+		if fund.Amount == 0 {
+			errs = errors.Join(errs, fmt.Errorf("warmup fund: %w", required("amount")))
+		}
+	}
+	for i, tx := range p.DexSetup.Deposit {
+		if len(tx.Committees) != 1 {
+			errs = errors.Join(errs, fmt.Errorf("dexSetup deposit %d: exactly one committee is required", i))
+		}
+		if tx.Amount == 0 {
+			errs = errors.Join(errs, fmt.Errorf("dexSetup deposit %d: %w", i, required("amount")))
+		}
+	}
+	seen := make(map[TxType]bool, len(p.Order))
+	for _, kind := range p.Order {
+		switch {
+		case !slices.Contains(DefaultTxOrder, kind):
+			errs = errors.Join(errs, fmt.Errorf("order: unknown scheduled tx type %q", kind))
+		case seen[kind]:
+			errs = errors.Join(errs, fmt.Errorf("order: duplicate tx type %q", kind))
+		}
+		seen[kind] = true
+	}
+	for kind := range p.General.TimeoutsMs {
+		if !slices.Contains(TxTypes, kind) {
+			errs = errors.Join(errs, fmt.Errorf("timeouts: unknown tx type %q", kind))
+		}
+	}
+	for _, kind := range p.General.RejectSelfTxs {
+		if !slices.Contains(TxTypes, kind) {
+			errs = errors.Join(errs, fmt.Errorf("rejectSelfTxs: unknown tx type %q", kind))
+		}
+	}
+	return errs
+}
+
+// CheckSelfTxs returns an error for every tx of a type in RejectSelfTxs whose sender and receiver
+// resolve to the same account address. The heartbeat sends to itself by design and isn't checked
+func (p *Profile) CheckSelfTxs(accounts []shared.Account) error {
+	if len(p.General.RejectSelfTxs) == 0 {
+		return nil
+	}
+	var errs error
+	check := func(key string, tx Tx) {
+		if !slices.Contains(p.General.RejectSelfTxs, tx.Kind()) {
+			return
+		}
+		from, to := tx.Sender(), tx.Receiver()
+		if from < 0 || from >= len(accounts) || to < 0 || to >= len(accounts) {
+			return
+		}
+		if accounts[from].Address == accounts[to].Address {
+			errs = errors.Join(errs, fmt.Errorf("%s: sender and receiver are the same address %s (from: %d, to: %d)",
+				key, accounts[from].Address, from, to))
+		}
+	}
+	if p.Send.Count() > 0 || p.Send.Spike.Enabled {
+		check(string(TxSend), p.Send)
+	}
+	for _, s := range scheduledTxs(p) {
+		check(s.key, s.tx)
+	}
+	return errs
+}
+
+// validateOrderLifecycles checks the lock and close offsets of order lifecycles, errors are prefixed with
+// name and the lifecycle index
+func validateOrderLifecycles(name string, lifecycles []OrderLifecycleTx) error {
+	var errs error
+	for i, lifecycle := range lifecycles {
+		if lifecycle.LockAfter == 0 || lifecycle.CloseAfter <= lifecycle.LockAfter {
+			errs = errors.Join(errs, fmt.Errorf("%s %d: requires 0 < lockAfter < closeAfter, got %d and %d",
+				name, i, lifecycle.LockAfter, lifecycle.CloseAfter))
+		}
+	}
+	return errs
+}
+
+// ExpandOrderLifecycles schedules the create, lock and close order txs of every order lifecycle, chained
+// through dependencies so each step waits for the previous one to be included in a block
+func (p *Profile) ExpandOrderLifecycles() {
+	p.Transactions.expandOrderLifecycles("orderLifecycle")
+}
+
+// expandOrderLifecycles schedules the steps of the order lifecycles, their ids are prefixed with prefix
+func (t *Transactions) expandOrderLifecycles(prefix string) {
+	for i, l := range t.OrderLifecycle {
+		o := l.order
+		o.lifecycle = &orderLifecycle{}
+		id := func(step string) string { return fmt.Sprintf("%s-%d-%s", prefix, i, step) }
+		create := CreateOrderTx{account: l.account, order: o, Data: l.Data}
+		create.Height, create.ID = l.Height, id("create")
+		buyer := account{From: l.Buyer, To: l.Buyer}
+		lock := LockOrderTx{account: buyer, order: o}
+		lock.Height, lock.ID, lock.DependsOn = l.Height+l.LockAfter, id("lock"), []string{create.ID}
+		closeTx := CloseOrderTx{account: buyer, order: o}
+		closeTx.Height, closeTx.ID, closeTx.DependsOn = l.Height+l.CloseAfter, id("close"), []string{lock.ID}
+		t.CreateOrder = append(t.CreateOrder, create)
+		t.LockOrder = append(t.LockOrder, lock)
+		t.CloseOrder = append(t.CloseOrder, closeTx)
+	}
+}
+
+// clone returns a copy of the txs that shares no state with t: the tx slices are copied and every order
+// lifecycle is replaced by a new one, so the order created on one chain doesn't drive the lock and close
+// order txs of another
+func (t Transactions) clone() Transactions {
+	lifecycles := make(map[*orderLifecycle]*orderLifecycle)
+	relink := func(o *order) {
+		if o.lifecycle == nil {
+			return
+		}
+		lifecycle, ok := lifecycles[o.lifecycle]
+		if !ok {
+			lifecycle = &orderLifecycle{}
+			lifecycles[o.lifecycle] = lifecycle
+		}
+		o.lifecycle = lifecycle
+	}
+	t.Stake = slices.Clone(t.Stake)
+	t.EditStake = slices.Clone(t.EditStake)
+	t.Pause = slices.Clone(t.Pause)
+	t.Unstake = slices.Clone(t.Unstake)
+	t.ChangeParam = slices.Clone(t.ChangeParam)
+	t.DaoTransfer = slices.Clone(t.DaoTransfer)
+	t.Subsidy = slices.Clone(t.Subsidy)
+	t.CreateOrder = slices.Clone(t.CreateOrder)
+	t.EditOrder = slices.Clone(t.EditOrder)
+	t.DeleteOrder = slices.Clone(t.DeleteOrder)
+	t.LockOrder = slices.Clone(t.LockOrder)
+	t.CloseOrder = slices.Clone(t.CloseOrder)
+	t.StartPoll = slices.Clone(t.StartPoll)
+	t.DexLimitOrder = slices.Clone(t.DexLimitOrder)
+	t.DexWithdraw = slices.Clone(t.DexWithdraw)
+	t.DexDeposit = slices.Clone(t.DexDeposit)
+	t.OrderLifecycle = slices.Clone(t.OrderLifecycle)
+	for i := range t.CreateOrder {
+		relink(&t.CreateOrder[i].order)
+	}
+	for i := range t.EditOrder {
+		relink(&t.EditOrder[i].order)
+	}
+	for i := range t.DeleteOrder {
+		relink(&t.DeleteOrder[i].order)
+	}
+	for i := range t.LockOrder {
+		relink(&t.LockOrder[i].order)
+	}
+	for i := range t.CloseOrder {
+		relink(&t.CloseOrder[i].order)
+	}
+	for i := range t.OrderLifecycle {
+		relink(&t.OrderLifecycle[i].order)
+	}
+	return t
+}
+
+// TxOrder returns the execution order of the scheduled tx types due at the same height: the configured
+// order first, then the remaining types in their default order
+func (p *Profile) TxOrder() []TxType {
+	order := slices.Clone(p.Order)
+	for _, kind := range DefaultTxOrder {
+		if !slices.Contains(order, kind) {
+			order = append(order, kind)
+		}
+	}
+	return order
+}
+
+// setDefaults fills the unset run parameters with their defaults and checks they are in sane ranges
+func (g *General) setDefaults() error {
+	if g.BaseFee == 0 {
+		g.BaseFee = defaultBaseFee
+	}
+	if g.Retries == 0 {
+		g.Retries = defaultRetries
+	}
+	if g.TimeoutMs == 0 {
+		g.TimeoutMs = defaultTimeoutMs
+	}
+	if g.BlockCheckIntervalMs == 0 {
+		g.BlockCheckIntervalMs = defaultBlockCheckIntervalMs
+	}
+	if g.ConfirmConcurrency == 0 {
+		g.ConfirmConcurrency = defaultConfirmConcurrency
+	}
+	var errs error
+	if g.Retries < 1 || g.Retries > 100 {
+		errs = errors.Join(errs, fmt.Errorf("retries: %d out of range [1, 100]", g.Retries))
+	}
+	if g.TimeoutMs < 100 || g.TimeoutMs > 600_000 {
+		errs = errors.Join(errs, fmt.Errorf("timeout: %dms out of range [100, 600000]", g.TimeoutMs))
+	}
+	if g.BlockCheckIntervalMs < 10 || g.BlockCheckIntervalMs > 60_000 {
+		errs = errors.Join(errs, fmt.Errorf("blockCheckInterval: %dms out of range [10, 60000]", g.BlockCheckIntervalMs))
+	}
+	if g.DrainTimeoutMs > 600_000 {
+		errs = errors.Join(errs, fmt.Errorf("drainTimeout: %dms out of range [0, 600000]", g.DrainTimeoutMs))
+	}
+	errs = errors.Join(errs, g.AdminRoutes.setDefaults())
+	errs = errors.Join(errs, g.validateChainRPCs())
+	g.Preflight.setDefaults()
+	return errs
+}
+
+// WarnUnreachableTxs warns about the scheduled txs due after MaxHeight, which never run as the block notifier
+// stops past it, returning their count. Heights are block counters from the start in incremental mode and
+// chain heights otherwise, both compared to MaxHeight
+func (p *Profile) WarnUnreachableTxs(log *slog.Logger) (unreachable int) {
+	for _, s := range scheduledTxs(p) {
+		if s.tx.Schedule().Height <= p.General.MaxHeight {
+			continue
+		}
+		log.Warn("tx scheduled after maxHeight never runs", slog.String("tx", s.key),
+			slog.String("kind", string(s.tx.Kind())), slog.Uint64("height", s.tx.Schedule().Height),
+			slog.Uint64("max_height", p.General.MaxHeight), slog.Bool("incremental", p.General.Incremental))
+		unreachable++
+	}
+	return unreachable
+}
+
+// ResolveCommittees translates the chain names listed as committees into their chain ids, using the chain
+// names of the loaded accounts files
+func (p *Profile) ResolveCommittees(chainNames map[string]uint64) error {
+	t := &p.Transactions
+	return errors.Join(
+		resolveCommittees(TxStake, t.Stake, chainNames),
+		resolveCommittees(TxEditStake, t.EditStake, chainNames),
+		resolveCommittees(TxSubsidy, t.Subsidy, chainNames),
+		resolveCommittees(TxCreateOrder, t.CreateOrder, chainNames),
+		resolveCommittees(TxEditOrder, t.EditOrder, chainNames),
+		resolveCommittees(TxDeleteOrder, t.DeleteOrder, chainNames),
+		resolveCommittees(TxLockOrder, t.LockOrder, chainNames),
+		resolveCommittees(TxCloseOrder, t.CloseOrder, chainNames),
+		resolveCommittees(TxLimitOrder, t.DexLimitOrder, chainNames),
+		resolveCommittees(TxDexWithdraw, t.DexWithdraw, chainNames),
+		resolveCommittees(TxDexDeposit, t.DexDeposit, chainNames),
+		resolveCommittees("warmup "+TxStake, p.Warmup.Stake, chainNames),
+		resolveCommittees("dexSetup "+TxDexDeposit, p.DexSetup.Deposit, chainNames),
+	)
+}
+
+// resolveCommittees is a helper that resolves the committees of every item
+func resolveCommittees[T any, PT interface {
+	*T
+	txCommittees() *committees
+}](kind TxType, items []T, chainNames map[string]uint64) error {
+	var errs error
+	for i := range items {
+		if err := PT(&items[i]).txCommittees().resolve(chainNames); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s %d: %w", kind, i, err))
+		}
+	}
+	return errs
+}
+
+// fileRefPrefix marks a hex field whose value is read from the file at the path that follows it
+const fileRefPrefix = "@file:"
+
+// LoadFileRefs replaces the create order data and subsidy op codes given as @file:path with the hex
+// contents of the file, relative paths are resolved against dir
+func (p *Profile) LoadFileRefs(dir string) error {
+	var errs error
+	for i := range p.Transactions.CreateOrder {
+		tx := &p.Transactions.CreateOrder[i]
+		if err := loadFileRef(dir, &tx.Data); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s %d: data: %w", TxCreateOrder, i, err))
+		}
+	}
+	for i := range p.Transactions.Subsidy {
+		tx := &p.Transactions.Subsidy[i]
+		if err := loadFileRef(dir, &tx.OpCode); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s %d: opCode: %w", TxSubsidy, i, err))
+		}
+	}
+	return errs
+}
+
+// loadFileRef sets value to the trimmed contents of the file it references, which must decode as hex,
+// values without the fileRefPrefix are left as is
+func loadFileRef(dir string, value *string) error {
+	path, ok := strings.CutPrefix(*value, fileRefPrefix)
+	if !ok {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	contents := strings.TrimSpace(string(raw))
+	if _, err := hex.DecodeString(contents); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	*value = contents
+	return nil
+}
+
+// DefaultCommittees sets the committees of stake and edit stake txs that don't list any to the
+// chain the populator is running against
+func (p *Profile) DefaultCommittees(log *slog.Logger) {
+	inferred := func(kind TxType, i int, c *committees) {
+		if len(c.Committees) > 0 {
+			return
+		}
+		c.Committees = []uint64{p.General.ChainId}
+		log.Info("inferred committees", slog.String("kind", string(kind)), slog.Int("index", i),
+			slog.String("committees", c.String()))
+	}
+	for i := range p.Transactions.Stake {
+		inferred(TxStake, i, &p.Transactions.Stake[i].committees)
+	}
+	for i := range p.Transactions.EditStake {
+		inferred(TxEditStake, i, &p.Transactions.EditStake[i].committees)
+	}
+	for i := range p.Warmup.Stake {
+		inferred(TxStake, i, &p.Warmup.Stake[i].committees)
+	}
+}
+
+// Transactions is the config part that defines all the transactions to make
+type Transactions struct {
+	Stake         []StakeTx         `yaml:"stake"`
+	EditStake     []EditStakeTx     `yaml:"editStake"`
+	Pause         []PauseTx         `yaml:"pause"`
+	Unstake       []UnstakeTx       `yaml:"unstake"`
+	ChangeParam   []ChangeParamTx   `yaml:"changeParam"`
+	DaoTransfer   []DaoTransferTx   `yaml:"daoTransfer"`
+	Subsidy       []SubsidyTx       `yaml:"subsidy"`
+	CreateOrder   []CreateOrderTx   `yaml:"createOrder"`
+	EditOrder     []EditOrderTx     `yaml:"editOrder"`
+	DeleteOrder   []DeleteOrderTx   `yaml:"deleteOrder"`
+	LockOrder     []LockOrderTx     `yaml:"lockOrder"`
+	CloseOrder    []CloseOrderTx    `yaml:"closeOrder"`
+	StartPoll     []StartPollTx     `yaml:"startPoll"`
+	DexLimitOrder []DexLimitOrderTx `yaml:"dexLimitOrder"`
+	DexWithdraw   []DexWithdrawTx   `yaml:"dexWithdraw"`
+	DexDeposit    []DexDepositTx    `yaml:"dexDeposit"`
+
+	// OrderLifecycle entries are expanded into createOrder, lockOrder and closeOrder txs on load
+	OrderLifecycle []OrderLifecycleTx `yaml:"orderLifecycle"`
+}
+
+// General populator configuration
+type General struct {
+	RpcURL                string
+	AdminRpcURL           string
+	Incremental           bool   `yaml:"incremental"`
+	BasePort              int    `yaml:"basePort"`
+	Accounts              int    `yaml:"accounts"`
+	Fee                   uint64 `yaml:"fee"`
+	DefaultPassword       string `yaml:"defaultPassword"` // used when an account has no password
+	ChainId               uint64 `yaml:"chainId"`
+	NetworkId             uint64 `yaml:"networkId"`
+	MaxHeight             uint64 `yaml:"maxHeight"`
+	WaitForNewBlock       bool   `yaml:"waitForNewBlock"`
+	NotifyNewBlockDelayMs uint   `yaml:"notifyNewBlockDelay"` // milliseconds
+	MinNotifyIntervalMs   uint   `yaml:"minNotifyInterval"`   // milliseconds, coalesces the blocks produced faster
+	// StartOffsetMs delays the height-driven handler's first height relative to the send handler
+	StartOffsetMs uint `yaml:"startOffset"` // milliseconds
+	// StartJitterMs adds a random delay of up to this value on top of StartOffsetMs
+	StartJitterMs uint `yaml:"startJitter"` // milliseconds
+	// BaseFee is the fee used when neither chainFees nor fee apply (default: 10000)
+	BaseFee uint64 `yaml:"baseFee"`
+	// Retries is the number of consecutive failed block height requests tolerated (default: 5)
+	Retries int `yaml:"retries"`
+	// TimeoutMs is the timeout of each request, unless overridden by TimeoutsMs (default: 5000)
+	TimeoutMs uint `yaml:"timeout"` // milliseconds
+	// BlockCheckIntervalMs is the interval between new block checks (default: 500)
+	BlockCheckIntervalMs uint `yaml:"blockCheckInterval"` // milliseconds
+	// MaxDurationMs stops the run after this wall-clock time regardless of height, disabled when 0
+	MaxDurationMs uint `yaml:"maxDuration"` // milliseconds
+	// FirstBlockTimeoutMs fails the run when the notifier sees no new block within it, disabled when 0
+	FirstBlockTimeoutMs uint `yaml:"firstBlockTimeout"` // milliseconds
+	// DrainTimeoutMs is how long the txs in flight may take to finish once the run is interrupted by SIGINT or
+	// SIGTERM, before they are cancelled. No new txs are scheduled after the signal, 0 cancels them at once
+	DrainTimeoutMs uint `yaml:"drainTimeout"` // milliseconds
+	// Seed makes the memos and jitter reproducible across runs, a random seed is used when unset
+	Seed uint64 `yaml:"seed"`
+	// ChainFees overrides the fee for txs targeting the given chain id, e.g. chains with their own fee schedule
+	ChainFees map[uint64]uint64 `yaml:"chainFees"`
+	// TimeoutsMs overrides the per-request timeout for the given tx kinds, e.g. long bulk sends
+	TimeoutsMs map[TxType]uint `yaml:"timeouts"` // milliseconds
+	// RejectSelfTxs are the tx types whose sender and receiver can't be the same address, checked on load
+	RejectSelfTxs []TxType `yaml:"rejectSelfTxs"`
+	// OrderTxs makes each scheduled tx wait for the confirmation of the sender's previous scheduled tx
+	OrderTxs bool `yaml:"orderTxs"`
+	// ConfirmTxs tracks the inclusion of every sent scheduled tx, recording its confirmation latency
+	ConfirmTxs bool `yaml:"confirmTxs"`
+	// ConfirmConcurrency bounds the tx inclusion queries of confirmTxs run at once (default: 10)
+	ConfirmConcurrency uint `yaml:"confirmConcurrency"`
+	// MetricsAddress serves the confirmation latency histograms at /metrics (e.g. ":9100"), disabled when empty
+	MetricsAddress string `yaml:"metricsAddress"`
+	// ConfirmStakes optionally verifies staked validators appear in the validator set
+	ConfirmStakes StakeConfirmation `yaml:"confirmStakes"`
+	// Preflight optionally checks the network is healthy before the run, aborting it when it isn't
+	Preflight Preflight `yaml:"preflight"`
+	// Transport tunes the HTTP connections shared by the RPC and admin RPC clients
+	Transport HTTPTransport `yaml:"transport"`
+	// AdminRoutes overrides the admin RPC paths of the txs posted without the canopy client
+	AdminRoutes AdminRoutes `yaml:"adminRoutes"`
+	// ChainRPCs are the other chains loaded alongside chainId, each running the send, txs and heartbeat
+	// handlers against its own RPC and height
+	ChainRPCs []ChainRPC `yaml:"chainRpcs"`
+	// client is the canopy client of the chain, the global one when nil
+	client *rpc.Client
+}
+
+// Client returns the canopy client of the chain the config targets
+func (g General) Client() *rpc.Client {
+	if g.client != nil {
+		return g.client
+	}
+	return cnpyClient
+}
+
+// ChainRPC is a chain loaded alongside the profile's chain, with its own RPC endpoints
+type ChainRPC struct {
+	ChainId     uint64 `yaml:"chainId"`
+	RpcURL      string `yaml:"rpcURL"`
+	AdminRpcURL string `yaml:"adminRpcURL"`
+}
+
+// ForChain returns a copy of the profile targeting chain, its txs run against the chain's RPC and height
+func (p *Profile) ForChain(chain ChainRPC) *Profile {
+	forChain := *p
+	forChain.Transactions = p.Transactions.clone()
+	forChain.General.ChainId = chain.ChainId
+	forChain.General.RpcURL = chain.RpcURL
+	forChain.General.AdminRpcURL = chain.AdminRpcURL
+	forChain.General.client = rpc.NewClient(chain.RpcURL, chain.AdminRpcURL)
+	return &forChain
+}
+
+// validateChainRPCs checks every extra chain has a distinct chain id and absolute RPC urls
+func (g *General) validateChainRPCs() error {
+	var errs error
+	seen := map[uint64]bool{g.ChainId: true}
+	for i, chain := range g.ChainRPCs {
+		if chain.ChainId == 0 {
+			errs = errors.Join(errs, fmt.Errorf("chainRpcs %d: chainId is required", i))
+		} else if seen[chain.ChainId] {
+			errs = errors.Join(errs, fmt.Errorf("chainRpcs %d: chain %d is listed more than once", i, chain.ChainId))
+		}
+		seen[chain.ChainId] = true
+		for _, endpoint := range []struct{ name, url string }{{"rpcURL", chain.RpcURL}, {"adminRpcURL", chain.AdminRpcURL}} {
+			if u, err := url.Parse(endpoint.url); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = errors.Join(errs, fmt.Errorf("chainRpcs %d: %s %q must be an absolute url", i, endpoint.name, endpoint.url))
+			}
+		}
+	}
+	return errs
+}
+
+// AdminRoutes are the admin RPC paths of the txs the populator posts itself instead of through the canopy
+// client, relative to the admin RPC url. Unset routes keep their defaults
+type AdminRoutes struct {
+	Subsidy string `yaml:"subsidy"` // default: /v1/admin/tx-subsidy
+}
+
+// setDefaults fills the unset routes with their defaults and checks every route is a plain absolute path
+func (r *AdminRoutes) setDefaults() error {
+	if r.Subsidy == "" {
+		r.Subsidy = defaultSubsidyRoute
+	}
+	var errs error
+	routes := []struct{ name, path string }{
+		{"subsidy", r.Subsidy},
+	}
+	for _, route := range routes {
+		u, err := url.Parse(route.path)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("adminRoutes.%s: %w", route.name, err))
+			continue
+		}
+		if !strings.HasPrefix(route.path, "/") || u.Path != route.path || u.RawQuery != "" || u.Fragment != "" {
+			errs = errors.Join(errs, fmt.Errorf("adminRoutes.%s: %q must be a path starting with /", route.name, route.path))
+		}
+	}
+	return errs
+}
+
+// HTTPTransport tunes the HTTP connections to the node, unset fields keep the net/http defaults
+type HTTPTransport struct {
+	HTTP2               bool `yaml:"http2"`               // use HTTP/2 only, cleartext (h2c) for http:// urls
+	MaxIdleConnsPerHost uint `yaml:"maxIdleConnsPerHost"` // idle connections kept for reuse per host (default: 2)
+	MaxConnsPerHost     uint `yaml:"maxConnsPerHost"`     // open connections per host (default: unlimited)
+	IdleConnTimeoutMs   uint `yaml:"idleConnTimeout"`     // milliseconds an idle connection is kept (default: 90000)
+	KeepAliveMs         uint `yaml:"keepAlive"`           // milliseconds between TCP keep-alive probes (default: 30000)
+	DisableKeepAlives   bool `yaml:"disableKeepAlives"`   // open a new connection per request
+}
+
+// New builds a transport from the net/http default one with the configured tuning applied
+func (t HTTPTransport) New() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if t.HTTP2 {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = protocols
+	}
+	if t.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = int(t.MaxIdleConnsPerHost)
+		transport.MaxIdleConns = max(transport.MaxIdleConns, int(t.MaxIdleConnsPerHost))
+	}
+	if t.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = int(t.MaxConnsPerHost)
+	}
+	if t.IdleConnTimeoutMs > 0 {
+		transport.IdleConnTimeout = time.Duration(t.IdleConnTimeoutMs) * time.Millisecond
+	}
+	if t.KeepAliveMs > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: time.Duration(t.KeepAliveMs) * time.Millisecond,
+		}).DialContext
+	}
+	transport.DisableKeepAlives = t.DisableKeepAlives
+	return transport
+}
+
+// StakeConfirmation configures the post-stake verification against the validator set
+type StakeConfirmation struct {
+	Enabled    bool `yaml:"enabled"`
+	TimeoutMs  uint `yaml:"timeout"`  // milliseconds to wait for all stakes to appear
+	IntervalMs uint `yaml:"interval"` // milliseconds between validator set polls
+}
+
+// Warmup funds and stakes accounts before the main loop and waits for them to take effect, the
+// heights of its stake txs are ignored
+type Warmup struct {
+	// Fund seeds every account from a funding source, before the stakes
+	Fund       *WarmupFund `yaml:"fund"`
+	Stake      []StakeTx   `yaml:"stake"`
+	TimeoutMs  uint        `yaml:"timeout"`  // milliseconds to wait for all funds and stakes to appear
+	IntervalMs uint        `yaml:"interval"` // milliseconds between polls
+}
+
+// WarmupFund sends Amount to every account from a funding source with balance, e.g. a genesis validator.
+// The source's key is its nickname in the accounts files (a main account or a node key like node-1) or,
+// with Keystore, in that keystore.json
+type WarmupFund struct {
+	Source   string `yaml:"source"`
+	Amount   uint64 `yaml:"amount"`
+	Keystore string `yaml:"keystore"` // optional keystore.json holding the source, relative to the config file
+	Password string `yaml:"password"` // keystore password, defaults to general.defaultPassword
+
+	// account is the resolved funding source, set on load
+	account shared.Account
+}
+
+// DexSetup seeds the liquidity of DEX committees before the main loop and waits for the deposits to be
+// included in a block, so limit orders run against existing pools. The heights of its deposit txs are ignored
+type DexSetup struct {
+	Deposit    []DexDepositTx `yaml:"deposit"`
+	TimeoutMs  uint           `yaml:"timeout"`  // milliseconds to wait for all deposits to be included
+	IntervalMs uint           `yaml:"interval"` // milliseconds between inclusion polls
+}
+
+// Common fields
+
+type heightBatch struct {
+	Height    uint64   `yaml:"height"`
+	Batch     bool     `yaml:"batch"`
+	ID        string   `yaml:"id"`        // optional, referenced by other txs' dependsOn
+	DependsOn []string `yaml:"dependsOn"` // ids of the txs that must be confirmed before this one runs
+	// SkipValidate sends the tx without its pre-send checks, e.g. to test the node rejects it
+	SkipValidate bool `yaml:"skipValidate"`
+}
+
+type account struct {
+	From int `yaml:"from"`
+	To   int `yaml:"to"`
+}
+
+// StakerRole is the kind of stake a tx's sender must have
+type StakerRole string
+
+const (
+	RoleValidator StakerRole = "validator"
+	RoleDelegator StakerRole = "delegator"
+)
+
+// stakerRole asserts the sender is a validator or a delegator, any staked sender is accepted when empty
+type stakerRole struct {
+	Role StakerRole `yaml:"role"`
+}
+
+// validate ensures the role is a known one
+func (r stakerRole) validate() error {
+	switch r.Role {
+	case "", RoleValidator, RoleDelegator:
+		return nil
+	}
+	return fmt.Errorf("unknown role %q, must be %s or %s", r.Role, RoleValidator, RoleDelegator)
+}
+
+// check ensures a staked sender has the role
+func (r stakerRole) check(delegator bool) error {
+	switch {
+	case r.Role == RoleValidator && delegator:
+		return ErrNotValidator
+	case r.Role == RoleDelegator && !delegator:
+		return ErrNotDelegator
+	}
+	return nil
+}
+
+type amount struct {
+	Amount uint64 `yaml:"amount"`
+}
+
+// committees are the chains a tx is for, listed in the config as chain ids or as the chain names of the
+// genesis config, which are resolved into Committees when loading the profile
+type committees struct {
+	Refs       []string `yaml:"committees"`
+	Committees []uint64 `yaml:"-"`
+}
+
+// resolve sets Committees from the listed chain ids and names
+func (c *committees) resolve(chainNames map[string]uint64) error {
+	c.Committees = make([]uint64, 0, len(c.Refs))
+	for _, ref := range c.Refs {
+		if id, err := strconv.ParseUint(ref, 10, 64); err == nil {
+			c.Committees = append(c.Committees, id)
+			continue
+		}
+		id, ok := chainNames[ref]
+		if !ok {
+			if len(chainNames) == 0 {
+				return fmt.Errorf("committee %q: no chain names loaded, generate the accounts file with general.idsChainNames", ref)
+			}
+			return fmt.Errorf("committee %q: unknown chain name", ref)
+		}
+		c.Committees = append(c.Committees, id)
+	}
+	return nil
+}
+
+// txCommittees returns the committees of a tx, to resolve them
+func (c *committees) txCommittees() *committees { return c }
+
+// rotated returns a copy of the committees starting at committee offset % len(committees)
+func (c committees) rotated(offset uint) committees {
+	if len(c.Committees) == 0 {
+		return c
+	}
+	start := int(offset % uint(len(c.Committees)))
+	c.Committees = append(slices.Clone(c.Committees[start:]), c.Committees[:start]...)
+	return c
+}
+
+func (c committees) String() string {
+	strSlice := make([]string, len(c.Committees))
+	for i, committee := range c.Committees {
+		strSlice[i] = fmt.Sprintf("%d", committee)
+	}
+	return strings.Join(strSlice, ",")
+}
+
+type delimitedBlock struct {
+	StartBlock uint64 `yaml:"startBlock"`
+	EndBlock   uint64 `yaml:"endBlock"`
+}
+
+type batchOptions struct {
+	Count         uint `yaml:"batchCount"`
+	Concurrency   uint `yaml:"concurrency"`
+	UsePrivateKey bool `yaml:"usePrivateKey"`
+	BatchSize     uint `yaml:"batchSize"`
+}
+
+// Transaction types
+
+// SendTx Tx is handled separately
+type SendTx struct {
+	account      `yaml:",inline"`
+	amount       `yaml:",inline"`
+	heightBatch  `yaml:",inline"`
+	batchOptions `yaml:",inline"`
+	// ConcurrencyRamp raises the concurrency every block to find the saturation point, not for batch sends
+	ConcurrencyRamp ConcurrencyRamp `yaml:"concurrencyRamp"`
+	// Spike replaces the per block sends with a single burst, then measures the blocks to recover from it
+	Spike Spike `yaml:"spike"`
+}
+
+// HeartbeatTx is a minimal send from an account to itself on every block, keeping a steady
+// baseline of activity between scheduled bursts. Handled separately
+type HeartbeatTx struct {
+	Enabled       bool   `yaml:"enabled"`
+	From          int    `yaml:"from"`
+	Amount        uint64 `yaml:"amount"` // defaults to the minimum sendable amount
+	UsePrivateKey bool   `yaml:"usePrivateKey"`
+}
+
+// Transaction types
+
+// StakeTx represents a transaction to stake a validator/delegator, committees default to the
+// general chainId when empty
+type StakeTx struct {
+	heightBatch     `yaml:",inline"`
+	account         `yaml:",inline"`
+	amount          `yaml:",inline"`
+	committees      `yaml:",inline"`
+	Delegate        bool   `yaml:"delegate"`
+	EarlyWithdrawal bool   `yaml:"earlyWithdrawal"`
+	NetAddr         string `yaml:"netAddress"`
+}
+
+// EditStakeTx represents a transaction to edit a validator/delegator's stake
+type EditStakeTx struct {
+	StakeTx    `yaml:",inline"`
+	stakerRole `yaml:",inline"`
+}
+
+// PauseTx represents a transaction to pause a validator
+type PauseTx struct {
+	heightBatch `yaml:",inline"`
+	account     `yaml:",inline"`
+}
+
+// UnstakeTx represents a transaction to unstake a validator/delegator
+type UnstakeTx struct {
+	heightBatch `yaml:",inline"`
+	account     `yaml:",inline"`
+	stakerRole  `yaml:",inline"`
+}
+
+// ChangeParam represents a transaction to change a parameter
+type ChangeParamTx struct {
+	account        `yaml:",inline"`
+	heightBatch    `yaml:",inline"`
+	ParamSpace     string `yaml:"paramSpace"`
+	ParamKey       string `yaml:"paramKey"`
+	ParamValue     string `yaml:"paramValue"`
+	delimitedBlock `yaml:",inline"`
+}
+
+// DaoTransferTx represents a transaction to transfer DAO tokens
+type DaoTransferTx struct {
+	account        `yaml:",inline"`
+	amount         `yaml:",inline"`
+	heightBatch    `yaml:",inline"`
+	delimitedBlock `yaml:",inline"`
+}
+
+// SubsidyTx represents a transaction to subsidy a nested chain
+type SubsidyTx struct {
+	account     `yaml:",inline"`
+	amount      `yaml:",inline"`
+	heightBatch `yaml:",inline"`
+	committees  `yaml:",inline"`
+	OpCode      string `yaml:"opCode"`
+}
+
+// order is the set of fields that are used to work with order-related transactions
+type order struct {
+	OrderId       string `yaml:"orderId"`
+	SellAmount    uint64 `yaml:"sellAmount"`
+	ReceiveAmount uint64 `yaml:"receiveAmount"`
+	ChainId       uint64 `yaml:"chainID"`
+	committees    `yaml:",inline"`
+	// lifecycle is shared by the txs of an order lifecycle, to use the id of the order it created
+	lifecycle *orderLifecycle
+}
+
+// CreateOrderTx represents a transaction to create an order
+type CreateOrderTx struct {
+	account     `yaml:",inline"`
+	order       `yaml:",inline"`
+	heightBatch `yaml:",inline"`
+	Data        string `yaml:"data"`
+}
+
+// EditOrderTx represents a transaction to edit an order
+type EditOrderTx struct {
+	order       `yaml:",inline"`
+	account     `yaml:",inline"`
+	heightBatch `yaml:",inline"`
+}
+
+// DeleteOrderTx represents a transaction to delete an order
+type DeleteOrderTx struct {
+	order       `yaml:",inline"`
+	account     `yaml:",inline"`
+	heightBatch `yaml:",inline"`
+}
+
+// LockOrderTx represents a transaction to lock an order
+type LockOrderTx struct {
+	order       `yaml:",inline"`
+	account     `yaml:",inline"`
+	heightBatch `yaml:",inline"`
+}
+
+// CloseOrderTx represents a transaction to close an order
+type CloseOrderTx struct {
+	order       `yaml:",inline"`
+	account     `yaml:",inline"`
+	heightBatch `yaml:",inline"`
+}
+
+// OrderLifecycleTx drives a full order lifecycle from a single entry: From creates the order at Height,
+// then Buyer locks it LockAfter blocks and closes it CloseAfter blocks after its creation, using the id
+// of the created order. Each step waits for the previous one to be included in a block
+type OrderLifecycleTx struct {
+	account    `yaml:",inline"`
+	order      `yaml:",inline"`
+	Height     uint64 `yaml:"height"`
+	Data       string `yaml:"data"`
+	Buyer      int    `yaml:"buyer"`
+	LockAfter  uint64 `yaml:"lockAfter"`  // blocks after creation
+	CloseAfter uint64 `yaml:"closeAfter"` // blocks after creation
+}
+
+// StartPollTx represents a transaction to start a poll
+type StartPollTx struct {
+	heightBatch `yaml:",inline"`
+	account     `yaml:",inline"`
+	PollJSON    string `yaml:"pollJSON"`
+}
+
+// DexLimitOrderTx represents a transaction to limit an order
+type DexLimitOrderTx struct {
+	heightBatch  `yaml:",inline"`
+	order        `yaml:",inline"`
+	account      `yaml:",inline"`
+	batchOptions `yaml:",inline"`
+}
+
+type DexWithdrawTx struct {
+	heightBatch  `yaml:",inline"`
+	account      `yaml:",inline"`
+	batchOptions `yaml:",inline"`
+	Percent      int `yaml:"percent"`
+	committees   `yaml:",inline"`
+}
+
+type DexDepositTx struct {
+	heightBatch  `yaml:",inline"`
+	account      `yaml:",inline"`
+	committees   `yaml:",inline"`
+	amount       `yaml:",inline"`
+	batchOptions `yaml:",inline"`
+}
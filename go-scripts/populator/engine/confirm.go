@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/canopy-network/canopy/cmd/rpc"
+	"github.com/canopy-network/canopy/fsm"
+	"github.com/canopy-network/canopy/lib/crypto"
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+)
+
+const (
+	defaultConfirmTimeout  = 30 * time.Second // default time to wait for stakes to appear
+	defaultConfirmInterval = time.Second      // default interval between validator set polls
+)
+
+// stakedAddresses returns the addresses of the validators a sent stake tx staked, read from the public key
+// of the stake message built for its requests, which every batch of the tx shares
+func stakedAddresses(log *slog.Logger, tx Tx, accounts []shared.Account, config General) []string {
+	sender := accounts[tx.Sender()].Address
+	msgTx, ok := tx.(MsgTx)
+	if !ok {
+		return []string{sender}
+	}
+	req, err := BuildTxRequest(accounts[tx.Sender()], accounts[tx.Receiver()], config, 0, 1)
+	if err != nil {
+		log.Warn("confirm stake: build tx request failed", slog.String("address", sender), slog.String("error", err.Error()))
+		return []string{sender}
+	}
+	msg, err := msgTx.Msg(req)
+	if err != nil {
+		log.Warn("confirm stake: build stake message failed", slog.String("address", sender), slog.String("error", err.Error()))
+		return []string{sender}
+	}
+	stake, ok := msg.(*fsm.MessageStake)
+	if !ok {
+		return []string{sender}
+	}
+	pub, err := crypto.NewPublicKeyFromBytes(stake.PublicKey)
+	if err != nil {
+		log.Warn("confirm stake: invalid stake public key", slog.String("address", sender), slog.String("error", err.Error()))
+		return []string{sender}
+	}
+	return []string{pub.Address().String()}
+}
+
+// ConfirmStakes polls the validator set of client's chain until every address is staked or the timeout
+// elapses, returning the addresses whose stake never took effect
+func ConfirmStakes(ctx context.Context, log *slog.Logger, client *rpc.Client, config StakeConfirmation,
+	addresses []string) []string {
+	timeout := time.Duration(config.TimeoutMs) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultConfirmTimeout
+	}
+	interval := time.Duration(config.IntervalMs) * time.Millisecond
+	if interval == 0 {
+		interval = defaultConfirmInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// track the addresses that haven't been confirmed yet
+	pending := make(map[string]struct{}, len(addresses))
+	for _, address := range addresses {
+		pending[address] = struct{}{}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for address := range pending {
+			staked, _, err := isStaked(client, address)
+			if err != nil {
+				log.Debug("confirm stake: query validator failed",
+					slog.String("address", address), slog.String("error", err.Error()))
+				continue
+			}
+			if staked {
+				delete(pending, address)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			missing := make([]string, 0, len(pending))
+			for address := range pending {
+				missing = append(missing, address)
+			}
+			return missing
+		case <-ticker.C:
+		}
+	}
+}
+
+// ConfirmTxs polls the txs by hash on client's chain until every one is included in a block or the timeout
+// elapses, returning the hashes that were never included
+func ConfirmTxs(ctx context.Context, log *slog.Logger, client *rpc.Client, timeoutMs, intervalMs uint,
+	hashes []string) []string {
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultConfirmTimeout
+	}
+	interval := time.Duration(intervalMs) * time.Millisecond
+	if interval == 0 {
+		interval = defaultConfirmInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// track the hashes that haven't been included yet
+	pending := make(map[string]struct{}, len(hashes))
+	for _, hash := range hashes {
+		pending[hash] = struct{}{}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for hash := range pending {
+			result, err := client.TransactionByHash(hash)
+			if err != nil {
+				log.Debug("confirm tx: query tx failed", slog.String("hash", hash), slog.String("error", err.Error()))
+				continue
+			}
+			if result.Height > 0 {
+				delete(pending, hash)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			missing := make([]string, 0, len(pending))
+			for hash := range pending {
+				missing = append(missing, hash)
+			}
+			return missing
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/canopy-network/canopy/cmd/rpc"
+)
+
+// txState is the execution state of a scheduled transaction
+type txState int
+
+const (
+	txPending   txState = iota // not executed yet
+	txSent                     // executed, waiting to be included in a block
+	txConfirmed                // included in a block
+	txFailed                   // failed to execute, or one of its dependencies did
+)
+
+// scheduledTx is a scheduled transaction tracked for dependencies
+type scheduledTx struct {
+	key       string
+	tx        DueAt
+	dependsOn []*scheduledTx
+	state     txState
+	hashes    []string
+}
+
+// ScheduledTx is a transaction due for execution, Key identifies it to the dependency tracker
+type ScheduledTx struct {
+	Key string
+	Tx  Tx
+}
+
+// Dependencies enforces the execution order of dependent scheduled transactions: a tx only runs once
+// every tx it depends on has been included in a block. Dependencies are explicit (dependsOn) or, with
+// General.OrderTxs, implicit on the sender's previous scheduled tx. As a missed height would otherwise
+// drop a tx and block its dependents forever, tracked txs run at the first height at or after their own
+type Dependencies struct {
+	txs    []*scheduledTx
+	byKey  map[string]*scheduledTx
+	client *rpc.Client // client of the chain the txs are confirmed on
+}
+
+// NewDependencies builds the dependency tracker for the profile's scheduled transactions, it returns nil
+// when no transaction has dependencies
+func NewDependencies(p *Profile) (*Dependencies, error) {
+	d := &Dependencies{txs: scheduledTxs(p), byKey: make(map[string]*scheduledTx), client: p.General.Client()}
+	for _, s := range d.txs {
+		if _, ok := d.byKey[s.key]; ok {
+			return nil, fmt.Errorf("duplicate tx id %q", s.key)
+		}
+		d.byKey[s.key] = s
+	}
+	// explicit dependencies
+	tracked := false
+	for _, s := range d.txs {
+		for _, key := range s.tx.Schedule().DependsOn {
+			dep, ok := d.byKey[key]
+			if !ok {
+				return nil, fmt.Errorf("tx %s: dependsOn unknown tx id %q", s.key, key)
+			}
+			if dep == s {
+				return nil, fmt.Errorf("tx %s: depends on itself", s.key)
+			}
+			s.dependsOn = append(s.dependsOn, dep)
+			tracked = true
+		}
+	}
+	// implicit dependencies: each tx depends on the sender's previous tx at a lower height
+	if p.General.OrderTxs {
+		bySender := make(map[int][]*scheduledTx)
+		for _, s := range d.txs {
+			bySender[s.tx.Sender()] = append(bySender[s.tx.Sender()], s)
+		}
+		for _, txs := range bySender {
+			sort.SliceStable(txs, func(i, j int) bool {
+				return txs[i].tx.Schedule().Height < txs[j].tx.Schedule().Height
+			})
+			for i := 1; i < len(txs); i++ {
+				if txs[i-1].tx.Schedule().Height < txs[i].tx.Schedule().Height {
+					txs[i].dependsOn = append(txs[i].dependsOn, txs[i-1])
+					tracked = true
+				}
+			}
+		}
+	}
+	if !tracked {
+		return nil, nil
+	}
+	if err := d.checkCycles(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// scheduledTxs returns the profile's scheduled transactions in the profile's tx order, so txs due
+// together run in that order
+func scheduledTxs(p *Profile) []*scheduledTx {
+	byKind := map[TxType][]*scheduledTx{
+		TxStake:       schedule(TxStake, p.Transactions.Stake),
+		TxEditStake:   schedule(TxEditStake, p.Transactions.EditStake),
+		TxPause:       schedule(TxPause, p.Transactions.Pause),
+		TxUnstake:     schedule(TxUnstake, p.Transactions.Unstake),
+		TxChangeParam: schedule(TxChangeParam, p.Transactions.ChangeParam),
+		TxDaoTransfer: schedule(TxDaoTransfer, p.Transactions.DaoTransfer),
+		TxSubsidy:     schedule(TxSubsidy, p.Transactions.Subsidy),
+		TxCreateOrder: schedule(TxCreateOrder, p.Transactions.CreateOrder),
+		TxEditOrder:   schedule(TxEditOrder, p.Transactions.EditOrder),
+		TxDeleteOrder: schedule(TxDeleteOrder, p.Transactions.DeleteOrder),
+		TxLockOrder:   schedule(TxLockOrder, p.Transactions.LockOrder),
+		TxCloseOrder:  schedule(TxCloseOrder, p.Transactions.CloseOrder),
+		TxStartPoll:   schedule(TxStartPoll, p.Transactions.StartPoll),
+		TxLimitOrder:  schedule(TxLimitOrder, p.Transactions.DexLimitOrder),
+		TxDexDeposit:  schedule(TxDexDeposit, p.Transactions.DexDeposit),
+		TxDexWithdraw: schedule(TxDexWithdraw, p.Transactions.DexWithdraw),
+	}
+	var txs []*scheduledTx
+	for _, kind := range p.TxOrder() {
+		txs = append(txs, byKind[kind]...)
+	}
+	return txs
+}
+
+// schedule wraps the scheduled transactions of a kind, keyed by their id or their kind and index
+func schedule[T DueAt](kind TxType, items []T) []*scheduledTx {
+	out := make([]*scheduledTx, 0, len(items))
+	for i, tx := range items {
+		key := tx.Schedule().ID
+		if key == "" {
+			key = fmt.Sprintf("%s-%d", kind, i)
+		}
+		out = append(out, &scheduledTx{key: key, tx: tx})
+	}
+	return out
+}
+
+// checkCycles returns an error if the dependencies form a cycle
+func (d *Dependencies) checkCycles() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[*scheduledTx]int, len(d.txs))
+	var path []string
+	var visit func(s *scheduledTx) error
+	visit = func(s *scheduledTx) error {
+		path = append(path, s.key)
+		defer func() { path = path[:len(path)-1] }()
+		switch marks[s] {
+		case visiting:
+			return fmt.Errorf("circular tx dependency: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		marks[s] = visiting
+		for _, dep := range s.dependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		marks[s] = visited
+		return nil
+	}
+	for _, s := range d.txs {
+		if err := visit(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Due returns the pending transactions scheduled at or before height whose dependencies are all
+// confirmed. Transactions with a failed dependency are marked as failed and never run
+func (d *Dependencies) Due(log *slog.Logger, height uint64) []ScheduledTx {
+	var due []ScheduledTx
+	for _, s := range d.txs {
+		if s.state != txPending || s.tx.Schedule().Height > height {
+			continue
+		}
+		ready := true
+		for _, dep := range s.dependsOn {
+			d.refresh(dep)
+			switch dep.state {
+			case txFailed:
+				s.state = txFailed
+				log.Warn("skipping tx, dependency failed", slog.String("tx", s.key),
+					slog.String("dependency", dep.key))
+			case txConfirmed:
+				continue
+			default:
+				log.Debug("tx waiting for dependency", slog.String("tx", s.key),
+					slog.String("dependency", dep.key), slog.Uint64("height", height))
+			}
+			ready = false
+			break
+		}
+		if ready {
+			due = append(due, ScheduledTx{Key: s.key, Tx: s.tx})
+		}
+	}
+	return due
+}
+
+// Done records the execution result of a transaction returned by Due
+func (d *Dependencies) Done(key string, hashes []string, err error) {
+	s, ok := d.byKey[key]
+	if !ok {
+		return
+	}
+	if err != nil {
+		s.state = txFailed
+		return
+	}
+	s.state = txSent
+	s.hashes = hashes
+}
+
+// refresh marks a sent transaction as confirmed once all its hashes are included in a block
+func (d *Dependencies) refresh(s *scheduledTx) {
+	if s.state != txSent {
+		return
+	}
+	for _, hash := range s.hashes {
+		result, err := d.client.TransactionByHash(hash)
+		if err != nil || result.Height == 0 {
+			return
+		}
+	}
+	s.state = txConfirmed
+}
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+)
+
+// RunDexSetup deposits the profile's dex setup liquidity, one deposit per configured committee pool, and
+// waits until every deposit is included in a block, so the height-driven dex txs start against seeded
+// pools. Waiting for the inclusion stops once ctx is done
+func RunDexSetup(ctx context.Context, log *slog.Logger, profile *Profile, accounts []shared.Account) error {
+	var errs error
+	hashes := make([]string, 0, len(profile.DexSetup.Deposit))
+	for i, tx := range profile.DexSetup.Deposit {
+		sent, err := sendTx(tx, accounts[tx.Sender()], accounts[tx.Receiver()], profile.General, 0, false, 0)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("dex setup deposit %d: %w", i, err))
+			continue
+		}
+		log.Info("dex setup: deposited", slog.String("address", accounts[tx.Sender()].Address),
+			slog.Uint64("committee", tx.Committees[0]), slog.Uint64("amount", tx.Amount), slog.String("hash", sent[0]))
+		hashes = append(hashes, sent[0])
+	}
+	if errs != nil {
+		return errs
+	}
+	if missing := ConfirmTxs(ctx, log, profile.General.Client(), profile.DexSetup.TimeoutMs, profile.DexSetup.IntervalMs, hashes); len(missing) > 0 {
+		return fmt.Errorf("dex setup: %d of %d deposits not included in a block: %v",
+			len(missing), len(hashes), missing)
+	}
+	log.Info("dex setup: deposits confirmed", slog.Int("deposited", len(hashes)))
+	return nil
+}
@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"log/slog"
+
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+)
+
+// Drain unstakes every staked validator/delegator among the loaded accounts, returning how many were
+// unstaked and how many failed
+func Drain(log *slog.Logger, profile *Profile, accounts []shared.Account) (unstaked, failed int) {
+	for i, acc := range accounts {
+		staked, _, err := isStaked(profile.General.Client(), acc.Address)
+		if err != nil {
+			failed++
+			log.Error("failed to query validator", slog.String("address", acc.Address),
+				slog.String("error", err.Error()))
+			continue
+		}
+		if !staked {
+			continue
+		}
+		tx := UnstakeTx{account: account{From: i, To: i}}
+		hashes, err := sendTx(tx, accounts[i], accounts[i], profile.General, 0, false, 0)
+		if err != nil {
+			failed++
+			log.Error("failed to unstake", slog.String("address", acc.Address),
+				slog.String("error", err.Error()))
+			continue
+		}
+		unstaked++
+		log.Info("unstaked", slog.String("address", acc.Address), slog.String("hash", hashes[0]))
+	}
+	return unstaked, failed
+}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/canopy-network/canopy/cmd/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// sentTx is a sent transaction waiting to be observed in a block
+type sentTx struct {
+	client *rpc.Client // client of the chain the transaction was sent to
+	kind   TxType
+	hash   string
+	height uint64    // chain height the transaction was sent at
+	sentAt time.Time // wall-clock time the transaction was sent
+}
+
+// Confirmations tracks the inclusion of the sent scheduled transactions, recording per tx type the
+// height delta and wall-clock time between the send and the height the inclusion was observed at.
+// Inclusion is checked once per block, so the wall-clock time is bounded by the block time
+type Confirmations struct {
+	log         *slog.Logger
+	mu          sync.Mutex
+	pending     []sentTx
+	samples     map[TxType]*latencySamples
+	concurrency uint // inclusion queries run at once
+
+	registry      *prometheus.Registry
+	heightLatency *prometheus.HistogramVec
+	timeLatency   *prometheus.HistogramVec
+}
+
+// latencySamples are the recorded confirmation latencies of a tx type
+type latencySamples struct {
+	heights []float64
+	seconds []float64
+}
+
+// NewConfirmations creates the confirmation tracker, querying at most concurrency txs at once, and
+// registers its histograms
+func NewConfirmations(log *slog.Logger, concurrency uint) *Confirmations {
+	c := &Confirmations{
+		log:         log,
+		samples:     make(map[TxType]*latencySamples),
+		concurrency: concurrency,
+		registry:    prometheus.NewRegistry(),
+		heightLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "populator_tx_confirmation_blocks",
+			Help:    "Blocks between sending a transaction and its inclusion, by tx type",
+			Buckets: []float64{0, 1, 2, 3, 5, 8, 13, 21},
+		}, []string{"type"}),
+		timeLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "populator_tx_confirmation_seconds",
+			Help:    "Seconds between sending a transaction and observing its inclusion, by tx type",
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+		}, []string{"type"}),
+	}
+	c.registry.MustRegister(c.heightLatency, c.timeLatency)
+	return c
+}
+
+// Serve exposes the confirmation histograms on addr at /metrics, in the background
+func (c *Confirmations) Serve(log *slog.Logger, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Error("metrics server stopped", slog.String("address", addr), slog.String("error", err.Error()))
+		}
+	}()
+	log.Info("serving metrics", slog.String("address", addr))
+}
+
+// Track starts tracking the hashes of a transaction sent at height, on the chain of client
+func (c *Confirmations) Track(client *rpc.Client, kind TxType, height uint64, hashes []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, hash := range hashes {
+		c.pending = append(c.pending, sentTx{client: client, kind: kind, hash: hash, height: height, sentAt: now})
+	}
+}
+
+// Check records the latency of the pending transactions included in a block, the ones that can't be
+// queried yet stay pending. The inclusions are queried concurrently, the txs tracked meanwhile are
+// checked on the next call
+func (c *Confirmations) Check() {
+	c.mu.Lock()
+	txs := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	// query the inclusion heights, 0 while the tx isn't included or can't be queried
+	heights := make([]uint64, len(txs))
+	var next atomic.Int64
+	query := func() (string, error) {
+		i := next.Add(1) - 1
+		result, err := txs[i].client.TransactionByHash(txs[i].hash)
+		if err != nil {
+			return "", err
+		}
+		if result.Height == 0 {
+			return "", errors.New("not included")
+		}
+		heights[i] = result.Height
+		return txs[i].hash, nil
+	}
+	RunConcurrentTxs(context.Background(), uint(len(txs)), c.concurrency, query, c.log)
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := txs[:0]
+	for i, tx := range txs {
+		if heights[i] == 0 {
+			pending = append(pending, tx)
+			continue
+		}
+		blocks := float64(heights[i]) - float64(tx.height)
+		seconds := now.Sub(tx.sentAt).Seconds()
+		c.heightLatency.WithLabelValues(string(tx.kind)).Observe(blocks)
+		c.timeLatency.WithLabelValues(string(tx.kind)).Observe(seconds)
+		samples, ok := c.samples[tx.kind]
+		if !ok {
+			samples = &latencySamples{}
+			c.samples[tx.kind] = samples
+		}
+		samples.heights = append(samples.heights, blocks)
+		samples.seconds = append(samples.seconds, seconds)
+	}
+	c.pending = append(pending, c.pending...)
+}
+
+// LatencyReport is the JSON summary of the confirmation latencies per tx type
+type LatencyReport struct {
+	Types map[TxType]TypeLatency `json:"types"`
+}
+
+// TypeLatency summarizes the confirmation latencies of a tx type
+type TypeLatency struct {
+	Confirmed   int            `json:"confirmed"`   // transactions observed in a block
+	Unconfirmed int            `json:"unconfirmed"` // transactions still pending when the report was made
+	Blocks      LatencySummary `json:"blocks"`      // blocks between send and inclusion
+	Seconds     LatencySummary `json:"seconds"`     // seconds between send and observed inclusion
+}
+
+// LatencySummary are the statistics of a set of latency samples
+type LatencySummary struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	Max  float64 `json:"max"`
+}
+
+// Report summarizes the recorded latencies and the transactions still pending per tx type
+func (c *Confirmations) Report() LatencyReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := LatencyReport{Types: make(map[TxType]TypeLatency)}
+	for kind, samples := range c.samples {
+		report.Types[kind] = TypeLatency{
+			Confirmed: len(samples.heights),
+			Blocks:    summarize(samples.heights),
+			Seconds:   summarize(samples.seconds),
+		}
+	}
+	for _, tx := range c.pending {
+		latency := report.Types[tx.kind]
+		latency.Unconfirmed++
+		report.Types[tx.kind] = latency
+	}
+	return report
+}
+
+// summarize computes the statistics of the samples, a percentile p is the sample at rank p*(n-1) rounded down
+func summarize(samples []float64) LatencySummary {
+	if len(samples) == 0 {
+		return LatencySummary{}
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	percentile := func(p float64) float64 {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	return LatencySummary{
+		Min:  sorted[0],
+		Mean: sum / float64(len(sorted)),
+		P50:  percentile(0.5),
+		P95:  percentile(0.95),
+		Max:  sorted[len(sorted)-1],
+	}
+}
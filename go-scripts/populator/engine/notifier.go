@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// HeightCh represents a channel for height updates
+type HeightCh struct {
+	Height  uint64 `json:"height"`  // actual height of the block
+	Counter uint64 `json:"counter"` // height counter of the block for incremental mode
+}
+
+// ErrChainStalled is the error of a notifier that saw no new block within general.firstBlockTimeoutMs
+var ErrChainStalled = errors.New("no new block within firstBlockTimeout, the chain looks stalled")
+
+type HeightResp struct {
+	Height int `json:"height"`
+}
+
+type newBlockNotifier struct {
+	log           *slog.Logger
+	config        General
+	checkInterval time.Duration
+	maxRetries    int
+	notifyDelay   time.Duration // wait before emitting each height
+	minInterval   time.Duration // minimum time between emitted heights
+
+	heightCh    chan HeightCh
+	lastHeight  uint64
+	retries     int
+	initialized bool
+	counter     uint64
+	lastEmit    time.Time
+	err         error         // why the notifier stopped early, set before stopped is closed
+	stopped     chan struct{} // closed once the notifier stopped
+}
+
+// newNotifier creates a new block notifier
+func newNotifier(log *slog.Logger, config General, checkInterval time.Duration, maxRetries int) *newBlockNotifier {
+	return &newBlockNotifier{
+		log:           log,
+		config:        config,
+		checkInterval: checkInterval,
+		maxRetries:    maxRetries,
+		notifyDelay:   time.Duration(config.NotifyNewBlockDelayMs) * time.Millisecond,
+		minInterval:   time.Duration(config.MinNotifyIntervalMs) * time.Millisecond,
+		heightCh:      make(chan HeightCh),
+		stopped:       make(chan struct{}),
+		lastHeight:    uint64(0),
+		retries:       0,
+		initialized:   !config.WaitForNewBlock,
+		counter:       0,
+	}
+}
+
+// handleHeight handles the height of a new block depending on the profile settings
+func (n *newBlockNotifier) handleHeight(height uint64) (stop bool, h uint64, counter uint64) {
+	// emit actual chain height until it exceeds MaxHeight
+	max := n.config.MaxHeight
+	if !n.config.Incremental {
+		if height <= max {
+			return false, height, 0
+		}
+		return true, height, 0
+	}
+	// incremental mode: height becomes a 0 based counter, incremented by 1 per emitted block
+	n.counter++
+	if n.counter <= max {
+		return false, height, n.counter
+	}
+	return true, height, n.counter
+}
+
+// run starts the block notifier, until MaxHeight, too many failed height requests or ctx is done. With
+// FirstBlockTimeoutMs, the notifier stops with ErrChainStalled when no block is emitted within it
+func (n *newBlockNotifier) run(ctx context.Context) {
+	defer close(n.stopped)
+	defer close(n.heightCh)
+	ticker := time.NewTicker(n.checkInterval)
+	defer ticker.Stop()
+	var firstBlockDeadline time.Time
+	if n.config.FirstBlockTimeoutMs > 0 {
+		firstBlockDeadline = time.Now().Add(time.Duration(n.config.FirstBlockTimeoutMs) * time.Millisecond)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			n.log.Warn("block notifier stopped", slog.String("reason", ctx.Err().Error()))
+			return
+		case <-ticker.C:
+		}
+		if !firstBlockDeadline.IsZero() && time.Now().After(firstBlockDeadline) {
+			n.err = ErrChainStalled
+			n.log.Error(n.err.Error(),
+				slog.Uint64("first_block_timeout_ms", uint64(n.config.FirstBlockTimeoutMs)),
+				slog.Uint64("last_height", n.lastHeight))
+			return
+		}
+		resp, err := n.config.Client().Height()
+		if err != nil {
+			n.log.Error("get block height failed",
+				slog.String("err", err.Error()),
+				slog.Int("retry", n.retries),
+				slog.Int("maxRetries", n.maxRetries),
+			)
+			n.retries++
+			if n.retries > n.maxRetries {
+				return
+			}
+			continue
+		}
+		// reset retries on success
+		n.retries = 0
+		// ignore genesis or non-increasing heights
+		if resp.Height == 0 || resp.Height <= n.lastHeight {
+			continue
+		}
+		// coalesce the blocks produced within minInterval of the last emitted height, the latest height is
+		// emitted once it elapses
+		if n.minInterval > 0 && !n.lastEmit.IsZero() && time.Since(n.lastEmit) < n.minInterval {
+			continue
+		}
+		// sleep for notifyDelay before emitting the height
+		if n.notifyDelay > 0 {
+			time.Sleep(n.notifyDelay)
+		}
+		n.lastHeight = resp.Height
+		// wait for the next block on the very first iteration so is always notified on a "new block"
+		if !n.initialized {
+			n.initialized = true
+			continue
+		}
+		// handle the new height
+		stop, height, counter := n.handleHeight(resp.Height)
+		if stop {
+			return
+		}
+		firstBlockDeadline = time.Time{}
+		n.lastEmit = time.Now()
+		select {
+		case n.heightCh <- HeightCh{Height: height, Counter: counter}:
+		case <-ctx.Done():
+			n.log.Warn("block notifier stopped", slog.String("reason", ctx.Err().Error()))
+			return
+		}
+	}
+}
+
+// Err returns why the notifier stopped early, e.g. ErrChainStalled, or nil while it's still running
+func (n *newBlockNotifier) Err() error {
+	select {
+	case <-n.stopped:
+		return n.err
+	default:
+		return nil
+	}
+}
+
+// BlockNotifier creates a new block notifier that emits the height of every new block until ctx is done.
+// Once the returned channel is closed, errFn reports why the notifier stopped early, e.g. ErrChainStalled
+func BlockNotifier(ctx context.Context, log *slog.Logger, config General, timeout time.Duration,
+	checkInterval time.Duration, maxRetries int) (heightCh <-chan HeightCh, errFn func() error) {
+	n := newNotifier(log, config, checkInterval, maxRetries)
+	go n.run(ctx)
+	return n.heightCh, n.Err
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Phase is a stage of a multi-stage scenario. Its txs only run once every tx of the previous phase is
+// confirmed, their heights still being the earliest height they run at
+type Phase struct {
+	Name         string       `yaml:"name"` // prefixes the ids of the phase's txs, defaults to phase-<index>
+	Transactions Transactions `yaml:"transactions"`
+}
+
+// ExpandPhases adds the txs of every phase to the profile's txs. Each tx of a phase depends on all the
+// txs of the previous phase with txs, so a failed tx stops every later phase
+func (p *Profile) ExpandPhases() error {
+	var errs error
+	names := make(map[string]bool, len(p.Phases))
+	var previous []string
+	for i := range p.Phases {
+		phase := &p.Phases[i]
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase-%d", i)
+		}
+		if names[phase.Name] {
+			errs = errors.Join(errs, fmt.Errorf("phase %d: duplicate name %q", i, phase.Name))
+			continue
+		}
+		names[phase.Name] = true
+		txs := &phase.Transactions
+		if err := validateOrderLifecycles(phase.Name+" orderLifecycle", txs.OrderLifecycle); err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		txs.expandOrderLifecycles(phase.Name + "-orderLifecycle")
+		txs.OrderLifecycle = nil
+		// key the phase's txs so the next phase can depend on them
+		var keys []string
+		txs.eachScheduled(func(kind TxType, i int, s *heightBatch) {
+			if s.ID == "" {
+				s.ID = fmt.Sprintf("%s-%s-%d", phase.Name, kind, i)
+			}
+			s.DependsOn = append(s.DependsOn, previous...)
+			keys = append(keys, s.ID)
+		})
+		if len(keys) > 0 {
+			previous = keys
+		}
+		p.Transactions.append(txs)
+	}
+	return errs
+}
+
+// eachScheduled calls fn with the scheduling options of every tx, by kind and index within the kind
+func (t *Transactions) eachScheduled(fn func(kind TxType, i int, s *heightBatch)) {
+	eachScheduled(TxStake, t.Stake, fn)
+	eachScheduled(TxEditStake, t.EditStake, fn)
+	eachScheduled(TxPause, t.Pause, fn)
+	eachScheduled(TxUnstake, t.Unstake, fn)
+	eachScheduled(TxChangeParam, t.ChangeParam, fn)
+	eachScheduled(TxDaoTransfer, t.DaoTransfer, fn)
+	eachScheduled(TxSubsidy, t.Subsidy, fn)
+	eachScheduled(TxCreateOrder, t.CreateOrder, fn)
+	eachScheduled(TxEditOrder, t.EditOrder, fn)
+	eachScheduled(TxDeleteOrder, t.DeleteOrder, fn)
+	eachScheduled(TxLockOrder, t.LockOrder, fn)
+	eachScheduled(TxCloseOrder, t.CloseOrder, fn)
+	eachScheduled(TxStartPoll, t.StartPoll, fn)
+	eachScheduled(TxLimitOrder, t.DexLimitOrder, fn)
+	eachScheduled(TxDexWithdraw, t.DexWithdraw, fn)
+	eachScheduled(TxDexDeposit, t.DexDeposit, fn)
+}
+
+// eachScheduled is a helper that calls fn with the scheduling options of every item
+func eachScheduled[T any, PT interface {
+	*T
+	scheduling() *heightBatch
+}](kind TxType, items []T, fn func(kind TxType, i int, s *heightBatch)) {
+	for i := range items {
+		fn(kind, i, PT(&items[i]).scheduling())
+	}
+}
+
+// append adds the txs of other to t
+func (t *Transactions) append(other *Transactions) {
+	t.Stake = append(t.Stake, other.Stake...)
+	t.EditStake = append(t.EditStake, other.EditStake...)
+	t.Pause = append(t.Pause, other.Pause...)
+	t.Unstake = append(t.Unstake, other.Unstake...)
+	t.ChangeParam = append(t.ChangeParam, other.ChangeParam...)
+	t.DaoTransfer = append(t.DaoTransfer, other.DaoTransfer...)
+	t.Subsidy = append(t.Subsidy, other.Subsidy...)
+	t.CreateOrder = append(t.CreateOrder, other.CreateOrder...)
+	t.EditOrder = append(t.EditOrder, other.EditOrder...)
+	t.DeleteOrder = append(t.DeleteOrder, other.DeleteOrder...)
+	t.LockOrder = append(t.LockOrder, other.LockOrder...)
+	t.CloseOrder = append(t.CloseOrder, other.CloseOrder...)
+	t.StartPoll = append(t.StartPoll, other.StartPoll...)
+	t.DexLimitOrder = append(t.DexLimitOrder, other.DexLimitOrder...)
+	t.DexWithdraw = append(t.DexWithdraw, other.DexWithdraw...)
+	t.DexDeposit = append(t.DexDeposit, other.DexDeposit...)
+	t.OrderLifecycle = append(t.OrderLifecycle, other.OrderLifecycle...)
+}
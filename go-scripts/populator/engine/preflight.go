@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/canopy-network/canopy/cmd/rpc"
+)
+
+const (
+	defaultPreflightMinValidators = 1      // default validators the chain's validator set must have
+	defaultPreflightTimeoutMs     = 30_000 // default milliseconds to wait for the height to advance
+	defaultPreflightIntervalMs    = 1_000  // default milliseconds between height polls
+)
+
+// Preflight checks the target network is ready before any tx is sent: the rpc is reachable, the height
+// advances, the validator set of the chain has at least MinValidators and the blocks are of the configured
+// chain and network ids
+type Preflight struct {
+	Enabled       bool   `yaml:"enabled"`
+	MinValidators uint64 `yaml:"minValidators"` // validators the chain's validator set must have (default: 1)
+	TimeoutMs     uint   `yaml:"timeout"`       // milliseconds to wait for the height to advance (default: 30000)
+	IntervalMs    uint   `yaml:"interval"`      // milliseconds between height polls (default: 1000)
+}
+
+// setDefaults fills the unset preflight parameters with their defaults
+func (p *Preflight) setDefaults() {
+	if p.MinValidators == 0 {
+		p.MinValidators = defaultPreflightMinValidators
+	}
+	if p.TimeoutMs == 0 {
+		p.TimeoutMs = defaultPreflightTimeoutMs
+	}
+	if p.IntervalMs == 0 {
+		p.IntervalMs = defaultPreflightIntervalMs
+	}
+}
+
+// RunPreflight checks the network is healthy, returning why it isn't ready to be loaded
+func RunPreflight(ctx context.Context, log *slog.Logger, general General) error {
+	preflight := general.Preflight
+	client := general.Client()
+	start, rpcErr := client.Height()
+	if rpcErr != nil {
+		return fmt.Errorf("rpc unreachable: %w", rpcErr)
+	}
+	log.Info("preflight: rpc reachable", slog.Uint64("height", start.Height))
+	// the height must advance within the timeout, a stalled chain can't include the txs
+	height, err := waitHeightAdvance(ctx, client, start.Height,
+		time.Duration(preflight.TimeoutMs)*time.Millisecond, time.Duration(preflight.IntervalMs)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	log.Info("preflight: height advancing", slog.Uint64("from", start.Height), slog.Uint64("to", height))
+	validators, err := client.ValidatorSet(0, general.ChainId)
+	if err != nil {
+		return fmt.Errorf("validator set of chain %d: %w", general.ChainId, err)
+	}
+	if validators.NumValidators < preflight.MinValidators {
+		return fmt.Errorf("validator set of chain %d has %d validators, expected at least %d",
+			general.ChainId, validators.NumValidators, preflight.MinValidators)
+	}
+	log.Info("preflight: validator set", slog.Uint64("chain_id", general.ChainId),
+		slog.Uint64("validators", validators.NumValidators))
+	// the chain id is only in the certificate of the previous block, which the block at the new height has
+	block, err := client.BlockByHeight(height)
+	if err != nil {
+		return fmt.Errorf("block at height %d: %w", height, err)
+	}
+	if block.BlockHeader == nil || block.BlockHeader.LastQuorumCertificate == nil ||
+		block.BlockHeader.LastQuorumCertificate.Header == nil {
+		return fmt.Errorf("block at height %d has no certificate to read the chain id from", height)
+	}
+	view := block.BlockHeader.LastQuorumCertificate.Header
+	if view.ChainId != general.ChainId || view.NetworkId != general.NetworkId {
+		return fmt.Errorf("rpc serves chain %d of network %d, expected chain %d of network %d",
+			view.ChainId, view.NetworkId, general.ChainId, general.NetworkId)
+	}
+	log.Info("preflight: chain id", slog.Uint64("chain_id", view.ChainId),
+		slog.Uint64("network_id", view.NetworkId))
+	return nil
+}
+
+// waitHeightAdvance polls the height until it's past from, returning the new height
+func waitHeightAdvance(ctx context.Context, client *rpc.Client, from uint64, timeout, interval time.Duration) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("height stuck at %d for %s", from, timeout)
+		case <-ticker.C:
+		}
+		resp, err := client.Height()
+		if err != nil {
+			return 0, fmt.Errorf("rpc unreachable: %w", err)
+		}
+		if resp.Height > from {
+			return resp.Height, nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+const (
+	defaultRampStep        = 1  // default concurrency increment per block
+	defaultRampFailureRate = 10 // default failure rate (%) that marks saturation
+)
+
+// ConcurrencyRamp increases the concurrency of the send txs every block, from Start up to Max, to
+// discover the concurrency at which the node saturates. Once a level's failure rate exceeds FailureRate
+// the ramp stops and holds the last level under it
+type ConcurrencyRamp struct {
+	Enabled     bool    `yaml:"enabled"`
+	Start       uint    `yaml:"start"`       // first level, defaults to the send concurrency
+	Step        uint    `yaml:"step"`        // increment per block (default: 1)
+	Max         uint    `yaml:"max"`         // last level, required
+	FailureRate float64 `yaml:"failureRate"` // % of failed txs in a block that marks saturation (default: 10)
+}
+
+// setDefaults fills the unset ramp parameters with their defaults and checks they are in sane ranges
+func (r *ConcurrencyRamp) setDefaults(concurrency uint) error {
+	if r.Start == 0 {
+		r.Start = max(concurrency, 1)
+	}
+	if r.Step == 0 {
+		r.Step = defaultRampStep
+	}
+	if r.FailureRate == 0 {
+		r.FailureRate = defaultRampFailureRate
+	}
+	if r.Max < r.Start {
+		return fmt.Errorf("concurrencyRamp: max %d must be at least start %d", r.Max, r.Start)
+	}
+	if r.FailureRate < 0 || r.FailureRate > 100 {
+		return fmt.Errorf("concurrencyRamp: failureRate %.2f out of range (0, 100]", r.FailureRate)
+	}
+	return nil
+}
+
+// rampState is the progress of a concurrency ramp over the blocks of a run
+type rampState struct {
+	config    ConcurrencyRamp
+	level     uint // concurrency used for the next block
+	lastGood  uint // highest level with a failure rate under the threshold, 0 if none yet
+	saturated bool // a level exceeded the failure rate, the ramp holds at lastGood
+	maxed     bool // the ramp reached max without saturating
+}
+
+// newRampState starts a ramp at its first level, nil when the ramp is disabled
+func newRampState(config ConcurrencyRamp) *rampState {
+	if !config.Enabled {
+		return nil
+	}
+	return &rampState{config: config, level: config.Start}
+}
+
+// Concurrency returns the level to use for the next block
+func (r *rampState) Concurrency() uint {
+	return r.level
+}
+
+// Record logs the failure rate of the block sent at the current level and moves to the next level,
+// logging the saturation concurrency when the failure rate spikes
+func (r *rampState) Record(log *slog.Logger, height uint64, success, failure int) {
+	total := success + failure
+	if total == 0 {
+		return
+	}
+	rate := float64(failure) * 100 / float64(total)
+	log.Info("concurrency ramp level",
+		slog.Uint64("concurrency", uint64(r.level)),
+		slog.Uint64("height", height),
+		slog.Int("success", success),
+		slog.Int("failure", failure),
+		slog.Float64("failure_rate", rate),
+	)
+	if r.saturated || r.maxed {
+		return
+	}
+	if rate > r.config.FailureRate {
+		r.saturated = true
+		log.Warn("concurrency ramp saturated",
+			slog.Uint64("saturation_concurrency", uint64(r.level)),
+			slog.Uint64("last_good_concurrency", uint64(r.lastGood)),
+			slog.Float64("failure_rate", rate),
+			slog.Float64("threshold", r.config.FailureRate),
+		)
+		// hold at the last healthy level, or keep the first level if none was
+		if r.lastGood > 0 {
+			r.level = r.lastGood
+		}
+		return
+	}
+	r.lastGood = r.level
+	if r.level >= r.config.Max {
+		r.maxed = true
+		log.Info("concurrency ramp reached max without saturating",
+			slog.Uint64("concurrency", uint64(r.level)))
+		return
+	}
+	r.level = min(r.level+r.config.Step, r.config.Max)
+}
@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+var (
+	// seed is the base seed of every random source, from General.Seed or random when unset
+	seed uint64
+	// streams counts the random sources handed out, each one draws from its own stream of seed
+	streams atomic.Uint64
+)
+
+// SetSeed sets the seed of the random sources, a zero seed picks a random one. It returns the seed in
+// use so a run can be reproduced
+func SetSeed(s uint64) uint64 {
+	if s == 0 {
+		s = rand.Uint64()
+	}
+	seed = s
+	streams.Store(0)
+	return seed
+}
+
+// newRand returns a random source that isn't shared with other goroutines, so it needs no locking.
+// With a fixed seed, sources handed out in the same order produce the same values
+func newRand() *rand.Rand {
+	return rand.New(rand.NewPCG(seed, streams.Add(1)))
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// TxResult is the outcome of a single scheduled transaction (or batch) sent by the handlers, it
+// allows programs embedding the populator to react to individual results instead of parsing logs
+type TxResult struct {
+	Kind    TxType        `json:"kind"`    // type of the transaction
+	Height  uint64        `json:"height"`  // height the transaction was scheduled at
+	Batched bool          `json:"batched"` // whether the transaction was sent in batches
+	Hashes  []string      `json:"hashes"`  // hashes of the transactions accepted by the node
+	Success int           `json:"success"` // number of transactions successfully sent
+	Errors  int           `json:"errors"`  // number of transactions that failed
+	Err     error         `json:"-"`       // last error returned while sending, if any
+	Latency time.Duration `json:"latency"` // time taken to send the transaction(s)
+}
+
+// emitResult sends the result to the results channel, a nil channel disables emission.
+// The send blocks, so an embedding program must keep consuming the channel while handlers run
+func emitResult(results chan<- TxResult, result TxResult) {
+	if results == nil {
+		return
+	}
+	results <- result
+}
+
+// TxStats are the transactions of a tx type the handlers sent and failed to send
+type TxStats struct {
+	Success int `json:"success"`
+	Errors  int `json:"errors"`
+}
+
+// RunStats sums the handlers' results per tx type
+type RunStats map[TxType]TxStats
+
+// Add sums a handler's result into the stats
+func (s RunStats) Add(result TxResult) {
+	stats := s[result.Kind]
+	stats.Success += result.Success
+	stats.Errors += result.Errors
+	s[result.Kind] = stats
+}
+
+// Merge sums other stats into the stats, e.g. the ones of another chain
+func (s RunStats) Merge(other RunStats) {
+	for kind, stats := range other {
+		sum := s[kind]
+		sum.Success += stats.Success
+		sum.Errors += stats.Errors
+		s[kind] = sum
+	}
+}
+
+// Log logs the stats with attrs, a group per tx type in name order
+func (s RunStats) Log(log *slog.Logger, msg string, attrs ...any) {
+	kinds := make([]string, 0, len(s))
+	for kind := range s {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		stats := s[TxType(kind)]
+		attrs = append(attrs, slog.Group(kind, slog.Int("success", stats.Success), slog.Int("errors", stats.Errors)))
+	}
+	log.Info(msg, attrs...)
+}
@@ -0,0 +1,604 @@
+// Package engine is the populator's load engine, the populator command is a thin consumer of it. Programs
+// embedding it can read every tx result of RunChain from its out channel
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+	"golang.org/x/sync/semaphore"
+	"gopkg.in/yaml.v3"
+)
+
+// RunChain runs the send, txs and heartbeat handlers of the profile on its chain until its notifier stops,
+// returning the results of the handlers per tx type and why the notifier stopped early, if it did. Every
+// result is also emitted onto out, a nil out disables emission
+func RunChain(ctx context.Context, log *slog.Logger, profile *Profile, accounts []shared.Account,
+	confirmations *Confirmations, out chan<- TxResult) (RunStats, error) {
+	// setup the block notifier
+	notifier, notifierErr := BlockNotifier(ctx, log, profile.General,
+		time.Duration(profile.General.TimeoutMs)*time.Millisecond,
+		time.Duration(profile.General.BlockCheckIntervalMs)*time.Millisecond,
+		profile.General.Retries)
+	// sum the handlers' results
+	stats := RunStats{}
+	results := make(chan TxResult)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for result := range results {
+			stats.Add(result)
+			emitResult(out, result)
+		}
+	}()
+	// fan-out: listen for new blocks to broadcast
+	b := NewBroadcaster(log, notifier, "send", "txs", "heartbeat")
+	// start the tx handlers. A handler that returns stops listening, so the blocks after it aren't
+	// reported as dropped
+	wg := sync.WaitGroup{}
+	wg.Go(func() {
+		defer b.Done(0)
+		HandleSendTxs(ctx, log, b.Channels()[0], profile, accounts, results)
+	})
+	wg.Go(func() {
+		defer b.Done(1)
+		HandleTxs(ctx, log, b.Channels()[1], profile, accounts, confirmations, results)
+	})
+	wg.Go(func() {
+		defer b.Done(2)
+		HandleHeartbeat(log, b.Channels()[2], profile, accounts, results)
+	})
+	wg.Wait()
+	close(results)
+	<-collected
+	b.LogDropped()
+	return stats, notifierErr()
+}
+
+// RunContext returns the context of the run, which expires after general.maxDuration when set
+func RunContext(config General) (context.Context, context.CancelFunc) {
+	if config.MaxDurationMs == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(config.MaxDurationMs)*time.Millisecond)
+}
+
+// LogDeadline warns when the run was cut short by general.maxDuration, so its results are partial
+func LogDeadline(ctx context.Context, log *slog.Logger, config General) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Warn("run stopped at maxDuration, results are partial",
+			slog.Uint64("max_duration_ms", uint64(config.MaxDurationMs)))
+	}
+}
+
+// HandleSendTxs handles the sending of bulk `send` transactions per block, optionally emitting
+// each block's result onto results
+func HandleSendTxs(ctx context.Context, log *slog.Logger, notifier <-chan HeightCh, profile *Profile,
+	accounts []shared.Account, results chan<- TxResult) {
+	if profile.Send.Spike.Enabled {
+		runSpike(ctx, log, notifier, profile, accounts, results)
+		return
+	}
+	if profile.Send.Count() == 0 {
+		return
+	}
+	ramp := newRampState(profile.Send.ConcurrencyRamp)
+	lastBlockTime := time.Now()
+	for height := range notifier {
+		start := time.Now()
+		// execute the transactions
+		concurrency := profile.Send.Concurrency
+		if ramp != nil {
+			concurrency = ramp.Concurrency()
+		}
+		hashes, success, errors, err := executeSendTxs(ctx, profile, accounts, height.Height, concurrency, log)
+		duration := time.Since(start)
+		if ramp != nil {
+			ramp.Record(log, height.Height, success, errors)
+		}
+		emitResult(results, TxResult{
+			Kind:    TxSend,
+			Height:  height.Height,
+			Batched: profile.Send.IsBatch(),
+			Hashes:  hashes,
+			Success: success,
+			Errors:  errors,
+			Err:     err,
+			Latency: duration,
+		})
+		// get block
+		block, err := profile.General.Client().BlockByHeight(0)
+		if err != nil {
+			log.Error("error getting block", slog.Uint64("height", height.Height),
+				slog.String("error", err.Error()))
+			continue
+		}
+		// calculate block duration
+		blockTime := time.UnixMicro(int64(block.BlockHeader.Time))
+		lastBlockDuration := blockTime.Sub(lastBlockTime)
+		lastBlockTime = blockTime
+		// log data
+		log.Info("finished sending SEND txs",
+			slog.Int("success", success),
+			slog.Int("failure", errors),
+			slog.Uint64("count", uint64(profile.Send.Count())),
+			slog.Uint64("concurrency", uint64(concurrency)),
+			slog.Uint64("height", height.Height),
+			slog.String("duration", duration.String()),
+			slog.Uint64("last_block_txs", block.BlockHeader.NumTxs),
+			slog.String("last_block_duration", lastBlockDuration.String()),
+		)
+	}
+}
+
+// HandleHeartbeat sends a minimal send transaction from the configured account to itself per block
+func HandleHeartbeat(log *slog.Logger, notifier <-chan HeightCh, profile *Profile, accounts []shared.Account,
+	results chan<- TxResult) {
+	if !profile.Heartbeat.Enabled {
+		return
+	}
+	heartbeat := profile.Heartbeat.SendTx()
+	for height := range notifier {
+		start := time.Now()
+		hashes, success, errors, err := executeTx(heartbeat, profile, accounts, height.Height)
+		emitResult(results, TxResult{
+			Kind:    TxSend,
+			Height:  height.Height,
+			Hashes:  hashes,
+			Success: success,
+			Errors:  errors,
+			Err:     err,
+			Latency: time.Since(start),
+		})
+		if err != nil {
+			log.Warn("heartbeat failed", slog.Uint64("height", height.Height),
+				slog.String("error", err.Error()))
+			continue
+		}
+		log.Debug("heartbeat sent", slog.Uint64("height", height.Height),
+			slog.String("address", accounts[heartbeat.From].Address))
+	}
+}
+
+// HandleTxs handles the sending of most transactions per defined block, optionally tracking their
+// confirmation latency (nil disables it) and emitting each transaction's result onto results. Cancelling
+// ctx stops waiting for stake confirmations
+func HandleTxs(ctx context.Context, log *slog.Logger, notifier <-chan HeightCh, profile *Profile, accounts []shared.Account,
+	confirmations *Confirmations, results chan<- TxResult) {
+	deps, err := NewDependencies(profile)
+	if err != nil {
+		log.Error("failed to build tx dependencies", slog.String("error", err.Error()))
+		return
+	}
+	first := true
+	for heightInfo := range notifier {
+		// stagger the first height so it doesn't fire in the same instant as the send handler
+		if first {
+			first = false
+			time.Sleep(startOffset(profile.General))
+		}
+		if confirmations != nil {
+			confirmations.Check()
+		}
+		height := heightInfo.Height
+		if profile.General.Incremental {
+			height = heightInfo.Counter
+		}
+		// with dependencies, txs run in order once their prerequisites are confirmed
+		var due []ScheduledTx
+		if deps != nil {
+			due = deps.Due(log, height)
+		} else {
+			for _, tx := range GatherAtHeight(profile, height) {
+				due = append(due, ScheduledTx{Tx: tx})
+			}
+		}
+		var staked []string
+		for _, scheduled := range due {
+			// the run was interrupted, leave the remaining txs unscheduled
+			if shutdown.Stopping() {
+				break
+			}
+			tx := scheduled.Tx
+			txLog := log.With(slog.String("type", string(tx.Kind())),
+				slog.Uint64("height", height), slog.Bool("batched", tx.IsBatch()),
+				slog.String("address", accounts[tx.Sender()].Address))
+			txLog.Info("sending transaction")
+			start := time.Now()
+			hashes, success, errors, err := executeTx(tx, profile, accounts, heightInfo.Height)
+			logTxResult(txLog, hashes, success, errors, err)
+			if deps != nil {
+				deps.Done(scheduled.Key, hashes, err)
+			}
+			if confirmations != nil && len(hashes) > 0 {
+				confirmations.Track(profile.General.Client(), tx.Kind(), heightInfo.Height, hashes)
+			}
+			emitResult(results, TxResult{
+				Kind:    tx.Kind(),
+				Height:  height,
+				Batched: tx.IsBatch(),
+				Hashes:  hashes,
+				Success: success,
+				Errors:  errors,
+				Err:     err,
+				Latency: time.Since(start),
+			})
+			if err == nil && tx.Kind() == TxStake {
+				staked = append(staked, stakedAddresses(log, tx, accounts, profile.General)...)
+			}
+		}
+		// block until the stakes are registered so dependent txs run against the updated set
+		if profile.General.ConfirmStakes.Enabled && len(staked) > 0 {
+			missing := ConfirmStakes(ctx, log, profile.General.Client(), profile.General.ConfirmStakes, staked)
+			if len(missing) > 0 {
+				log.Warn("stakes not registered in the validator set",
+					slog.Uint64("height", height), slog.Int("staked", len(staked)),
+					slog.Any("missing", missing))
+			} else {
+				log.Info("stakes confirmed in the validator set",
+					slog.Uint64("height", height), slog.Int("staked", len(staked)))
+			}
+		}
+	}
+}
+
+// WriteLatencyReport checks the pending transactions one last time and writes the latency report
+func WriteLatencyReport(path string, confirmations *Confirmations) error {
+	confirmations.Check()
+	raw, err := json.MarshalIndent(confirmations.Report(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode latency report: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		return fmt.Errorf("write latency report %s: %w", path, err)
+	}
+	return nil
+}
+
+// logTxResult logs the success/error split of an executed transaction, batch or single. A batch with
+// any failed transaction is logged as a warning even when the batch itself didn't error
+func logTxResult(log *slog.Logger, hashes []string, success, errors int, err error) {
+	attrs := []any{slog.Int("success", success), slog.Int("errors", errors)}
+	if len(hashes) > 0 {
+		attrs = append(attrs, slog.String("hash", hashes[0]), slog.Int("hashes", len(hashes)))
+	}
+	switch {
+	case err != nil:
+		log.Error("failed to send transaction", append(attrs, slog.String("error", err.Error()))...)
+	case errors > 0:
+		log.Warn("transaction partially sent", attrs...)
+	default:
+		log.Info("transaction sent", attrs...)
+	}
+}
+
+// startOffset returns the configured start offset plus a random jitter
+func startOffset(config General) time.Duration {
+	offset := time.Duration(config.StartOffsetMs) * time.Millisecond
+	if config.StartJitterMs > 0 {
+		offset += time.Duration(newRand().Int64N(int64(config.StartJitterMs))) * time.Millisecond
+	}
+	return offset
+}
+
+// executeTx sends a single transaction (batch or non-batch) and returns the result
+func executeTx(tx Tx, profile *Profile, accounts []shared.Account, height uint64) (
+	hashes []string, success, errors int, err error) {
+	if tx.IsBatch() {
+		return doExecuteBulkTxs(tx, profile, accounts, height)
+	} else {
+		hashes, err = sendTx(tx, accounts[tx.Sender()], accounts[tx.Receiver()],
+			profile.General, height, false, 0)
+		if err == nil {
+			success++
+		} else {
+			errors++
+		}
+		return hashes, success, errors, err
+	}
+}
+
+// LoadConfigs loads the configuration and accounts from the given paths
+func LoadConfigs(configPath, profile string, accountsPath string) (*Profile, []shared.Account, error) {
+	// retrieve the accounts and the chain names of their ids.json
+	accounts, chainNames, err := loadAccounts(accountsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	// retrieve the populator config
+	path := filepath.Clean(configPath)
+	rawConfig, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config %s: %w", path, err)
+	}
+	var profiles map[string]Profile
+	if err := yaml.Unmarshal(rawConfig, &profiles); err != nil {
+		return nil, nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	pf, ok := profiles[profile]
+	if !ok {
+		return nil, nil, fmt.Errorf("profile %s not found", profile)
+	}
+	// add the txs of the csv schedule
+	if pf.Schedule != "" {
+		schedule := pf.Schedule
+		if !filepath.IsAbs(schedule) {
+			schedule = filepath.Join(filepath.Dir(path), schedule)
+		}
+		if err := LoadSchedule(schedule, &pf.Transactions); err != nil {
+			return nil, nil, fmt.Errorf("profile %s: %w", profile, err)
+		}
+	}
+	if err := pf.ExpandPhases(); err != nil {
+		return nil, nil, fmt.Errorf("profile %s: %w", profile, err)
+	}
+	pf.ExpandOrderLifecycles()
+	// read the order data and subsidy op codes given as file references
+	if err := pf.LoadFileRefs(filepath.Dir(path)); err != nil {
+		return nil, nil, fmt.Errorf("profile %s: %w", profile, err)
+	}
+	if err := pf.ResolveCommittees(chainNames); err != nil {
+		return nil, nil, fmt.Errorf("profile %s: %w", profile, err)
+	}
+	// validate the profile configuration
+	if err := pf.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("validate profile %s: %w", profile, err)
+	}
+	// validate there's the minimun number of accounts enforced by the config
+	min := max(2, pf.General.Accounts)
+	if len(accounts) < min {
+		return nil, nil, fmt.Errorf("not enough accounts, min: %d, actual: %d",
+			min, len(accounts))
+	}
+	// flag the self transfers of the tx types that reject them
+	if err := pf.CheckSelfTxs(accounts); err != nil {
+		return nil, nil, fmt.Errorf("validate profile %s: %w", profile, err)
+	}
+	// resolve the key of the warmup funding source
+	if fund := pf.Warmup.Fund; fund != nil {
+		keystore := fund.Keystore
+		if keystore != "" && !filepath.IsAbs(keystore) {
+			keystore = filepath.Join(filepath.Dir(path), keystore)
+		}
+		password := fund.Password
+		if password == "" {
+			password = pf.General.DefaultPassword
+		}
+		source, err := loadFundingSource(fund.Source, accountsPath, keystore, password)
+		if err != nil {
+			return nil, nil, fmt.Errorf("profile %s: warmup fund: %w", profile, err)
+		}
+		fund.account = source
+	}
+	return &pf, accounts, nil
+}
+
+// loadAccounts loads and merges the main accounts of the comma-separated accounts files, sorted by
+// address, and the chain name -> id map of the files generated with general.idsChainNames. An address
+// present in more than one file, or a chain name given different ids, is an error
+func loadAccounts(accountsPaths string) ([]shared.Account, map[string]uint64, error) {
+	var accounts []shared.Account
+	sources := make(map[string]string) // address -> file it was loaded from
+	chainNames := make(map[string]uint64)
+	for _, path := range strings.Split(accountsPaths, ",") {
+		path = filepath.Clean(strings.TrimSpace(path))
+		rawAccounts, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load accounts %s: %w", path, err)
+		}
+		var accountsMap struct {
+			Chains   map[uint64]string         `json:"chains"`
+			Accounts map[string]shared.Account `json:"main-accounts"`
+		}
+		if err := json.Unmarshal(rawAccounts, &accountsMap); err != nil {
+			return nil, nil, fmt.Errorf("parse accounts: %s: %w", path, err)
+		}
+		for id, name := range accountsMap.Chains {
+			if known, ok := chainNames[name]; ok && known != id {
+				return nil, nil, fmt.Errorf("merge accounts %s: chain %s has id %d, already loaded as %d",
+					path, name, id, known)
+			}
+			chainNames[name] = id
+		}
+		for nickname, account := range accountsMap.Accounts {
+			if source, ok := sources[account.Address]; ok {
+				return nil, nil, fmt.Errorf("merge accounts %s: %s address %s already loaded from %s",
+					path, nickname, account.Address, source)
+			}
+			sources[account.Address] = path
+			accounts = append(accounts, account)
+		}
+	}
+	// sort the accounts lexicographically for deterministic order
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Address < accounts[j].Address
+	})
+	return accounts, chainNames, nil
+}
+
+// GatherAtHeight returns all scheduled transactions due at height, in the profile's tx order
+// SendPlan is excluded (handled separately).
+func GatherAtHeight(p *Profile, height uint64) []Tx {
+	due := map[TxType][]Tx{
+		TxStake:       filterDue(p.Transactions.Stake, height),
+		TxEditStake:   filterDue(p.Transactions.EditStake, height),
+		TxPause:       filterDue(p.Transactions.Pause, height),
+		TxUnstake:     filterDue(p.Transactions.Unstake, height),
+		TxChangeParam: filterDue(p.Transactions.ChangeParam, height),
+		TxDaoTransfer: filterDue(p.Transactions.DaoTransfer, height),
+		TxSubsidy:     filterDue(p.Transactions.Subsidy, height),
+		TxCreateOrder: filterDue(p.Transactions.CreateOrder, height),
+		TxEditOrder:   filterDue(p.Transactions.EditOrder, height),
+		TxDeleteOrder: filterDue(p.Transactions.DeleteOrder, height),
+		TxLockOrder:   filterDue(p.Transactions.LockOrder, height),
+		TxCloseOrder:  filterDue(p.Transactions.CloseOrder, height),
+		TxStartPoll:   filterDue(p.Transactions.StartPoll, height),
+		TxLimitOrder:  filterDue(p.Transactions.DexLimitOrder, height),
+		TxDexDeposit:  filterDue(p.Transactions.DexDeposit, height),
+		TxDexWithdraw: filterDue(p.Transactions.DexWithdraw, height),
+	}
+	var out []Tx
+	for _, kind := range p.TxOrder() {
+		out = append(out, due[kind]...)
+	}
+	return out
+}
+
+// filterDue is a helper that filters a slice of DueAt items by height
+func filterDue[T DueAt](items []T, height uint64) []Tx {
+	var out []Tx
+	for _, v := range items {
+		if v.Due(height) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// RunConcurrentTxs runs concurrent tx for a total of count.
+// The do function should perform the work for a single idempotent job.
+func RunConcurrentTxs(ctx context.Context, count, concurrency uint,
+	do func() (string, error), log *slog.Logger) (int, int, error) {
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	// create a semaphore to limit concurrency
+	sem := semaphore.NewWeighted(int64(concurrency))
+	var wg sync.WaitGroup
+	var successes atomic.Int32
+	var errors atomic.Int32
+	// run the tx N times
+	var err error
+	for i := range count {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			// only fails once ctx is done, the remaining txs are left unsent and the launched ones complete
+			log.Warn("run stopped, leaving the remaining txs unsent", slog.Uint64("skipped", uint64(count-i)),
+				slog.String("reason", err.Error()))
+			break
+		}
+		wg.Add(1)
+		// only save the last error
+		go func() {
+			defer sem.Release(1)
+			defer wg.Done()
+
+			if _, txErr := do(); txErr != nil {
+				err = txErr
+				errors.Add(1)
+				return
+			}
+			successes.Add(1)
+		}()
+	}
+	// wait for all txs to complete
+	wg.Wait()
+	return int(successes.Load()), int(errors.Load()), err
+}
+
+// executeSendTxs runs the send transactions for a given height, at most concurrency at a time, until ctx is done
+func executeSendTxs(ctx context.Context, config *Profile, accounts []shared.Account, height uint64, concurrency uint,
+	log *slog.Logger) (hashes []string, success, errors int, errs error) {
+	if config.Send.IsBatch() {
+		return doExecuteBulkTxs(&config.Send, config, accounts, height)
+	}
+	var hashMu sync.Mutex
+	send := func() (string, error) {
+		sent, err := sendTx(&config.Send,
+			accounts[0], accounts[1], config.General, uint64(height), false, 0)
+		if err != nil {
+			return "", err
+		}
+		hashMu.Lock()
+		hashes = append(hashes, sent[0])
+		hashMu.Unlock()
+		return sent[0], nil
+	}
+	success, errors, errs = RunConcurrentTxs(ctx, config.Send.Count(), concurrency, send, log)
+	return hashes, success, errors, errs
+}
+
+// doExecuteBulkTxs sends bulk transactions in parallel batches
+func doExecuteBulkTxs(tx Tx, config *Profile, accounts []shared.Account,
+	height uint64) (hashes []string, success, errs int, err error) {
+	bulkTx, ok := tx.(BulkTx)
+	if !ok {
+		return nil, 0, 1, errors.New("tx does not support bulk transactions")
+	}
+
+	total := bulkTx.Count()
+	batchSize := max(bulkTx.BatchSize(), 1)
+	numBatches := (total + batchSize - 1) / batchSize
+
+	var wg sync.WaitGroup
+	var hashMu sync.Mutex
+	var successCount, errorCount atomic.Int32
+
+	for i := range numBatches {
+		toSend := min(batchSize, total-i*batchSize)
+		// continue the committee cycle of the batches before this one
+		batchTx := bulkTx
+		if cycling, ok := bulkTx.(CyclingBulkTx); ok {
+			batchTx = cycling.WithOffset(i * batchSize)
+		}
+		wg.Add(1)
+		go func(count uint) {
+			defer wg.Done()
+			batchHashes, txErr := sendTx(batchTx, accounts[tx.Sender()],
+				accounts[tx.Receiver()], config.General, height, true, count)
+			if txErr != nil {
+				err = txErr
+				errorCount.Add(int32(count))
+				return
+			}
+			hashMu.Lock()
+			hashes = append(hashes, batchHashes...)
+			hashMu.Unlock()
+			successCount.Add(int32(count))
+		}(toSend)
+	}
+	wg.Wait()
+	return hashes, int(successCount.Load()), int(errorCount.Load()), err
+}
+
+// txTimeout returns the configured timeout for the tx kind, defaulting to the global timeout
+func txTimeout(config General, kind TxType) time.Duration {
+	if ms, ok := config.TimeoutsMs[kind]; ok && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return time.Duration(config.TimeoutMs) * time.Millisecond
+}
+
+// sendTx is an util to build and send a transaction
+func sendTx(tx Tx, from, to shared.Account, config General, height uint64,
+	bulk bool, count uint) (hashes []string, err error) {
+	defer func() { shutdown.Done(err) }()
+	ctx, cancel := context.WithTimeout(shutdown.txCtx, txTimeout(config, tx.Kind()))
+	defer cancel()
+	req, err := BuildTxRequest(from, to, config, height, count)
+	if err != nil {
+		return nil, fmt.Errorf("build tx request: %w", err)
+	}
+	if bulk {
+		bulkTx, ok := tx.(BulkTx)
+		if !ok {
+			return nil, fmt.Errorf("tx [%T] does not implement BulkTx", tx)
+		}
+		return bulkTx.DoBulk(ctx, req, config.AdminRpcURL)
+	}
+	hash, err := tx.Do(ctx, req, config.AdminRpcURL)
+	if err != nil {
+		return nil, err
+	}
+	return []string{hash}, nil
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scheduleListColumns are the schedule columns holding lists, their items are separated by ';'
+var scheduleListColumns = map[string]bool{"committees": true, "dependsOn": true}
+
+// scheduleRequired are the columns a schedule row of each tx type must fill besides type, height and from
+var scheduleRequired = map[TxType][]string{
+	TxStake:       {"amount"},
+	TxEditStake:   {"amount"},
+	TxPause:       {},
+	TxUnstake:     {},
+	TxChangeParam: {"paramSpace", "paramKey", "paramValue"},
+	TxDaoTransfer: {"amount"},
+	TxSubsidy:     {"amount"},
+	TxCreateOrder: {"sellAmount", "receiveAmount", "chainID"},
+	TxEditOrder:   {"orderId"},
+	TxDeleteOrder: {"orderId"},
+	TxLockOrder:   {"orderId"},
+	TxCloseOrder:  {"orderId"},
+	TxStartPoll:   {"pollJSON"},
+	TxLimitOrder:  {"sellAmount", "receiveAmount", "committees"},
+	TxDexDeposit:  {"amount", "committees"},
+	TxDexWithdraw: {"percent", "committees"},
+}
+
+// LoadSchedule appends the txs of a CSV schedule to txs. The header row names the columns: `type` is the
+// tx type and the rest are the yaml keys of its config (e.g. height,from,to,amount,committees), empty
+// cells are left unset. Every row is checked and all row-level errors are returned
+func LoadSchedule(path string, txs *Transactions) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("load schedule %s: %w", path, err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("parse schedule %s header: %w", path, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	var errs error
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("parse schedule %s: %w", path, err))
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if err := addScheduleRow(header, record, txs); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("schedule %s line %d: %w", path, line, err))
+		}
+	}
+	return errs
+}
+
+// addScheduleRow decodes a schedule row into the tx of its type and appends it to txs
+func addScheduleRow(header, record []string, txs *Transactions) error {
+	if len(record) > len(header) {
+		return fmt.Errorf("%d cells for %d columns", len(record), len(header))
+	}
+	// build the yaml mapping of the row, so it decodes exactly like the tx in the config file
+	var kind TxType
+	filled := make(map[string]bool, len(record))
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for i, value := range record {
+		column, value := header[i], strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if column == "type" {
+			kind = TxType(value)
+			continue
+		}
+		filled[column] = true
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if scheduleListColumns[column] {
+			node = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for _, item := range strings.Split(value, ";") {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(item)})
+			}
+		}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: column}, node)
+	}
+	if kind == "" {
+		return errors.New("missing type")
+	}
+	required, ok := scheduleRequired[kind]
+	if !ok {
+		return fmt.Errorf("unknown scheduled tx type %q", kind)
+	}
+	var missing []string
+	for _, column := range append([]string{"height", "from"}, required...) {
+		if !filled[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: missing required columns: %s", kind, strings.Join(missing, ", "))
+	}
+	raw, err := yaml.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("%s: %w", kind, err)
+	}
+	decode := func(out any) error {
+		decoder := yaml.NewDecoder(bytes.NewReader(raw))
+		decoder.KnownFields(true)
+		err := decoder.Decode(out)
+		// the line numbers of the yaml errors refer to the generated mapping, not the schedule
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for i, msg := range typeErr.Errors {
+				if strings.HasPrefix(msg, "line ") {
+					_, typeErr.Errors[i], _ = strings.Cut(msg, ": ")
+				}
+			}
+			return fmt.Errorf("%s: %s", kind, strings.Join(typeErr.Errors, ", "))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", kind, err)
+		}
+		return nil
+	}
+	switch kind {
+	case TxStake:
+		return appendScheduled(decode, &txs.Stake)
+	case TxEditStake:
+		return appendScheduled(decode, &txs.EditStake)
+	case TxPause:
+		return appendScheduled(decode, &txs.Pause)
+	case TxUnstake:
+		return appendScheduled(decode, &txs.Unstake)
+	case TxChangeParam:
+		return appendScheduled(decode, &txs.ChangeParam)
+	case TxDaoTransfer:
+		return appendScheduled(decode, &txs.DaoTransfer)
+	case TxSubsidy:
+		return appendScheduled(decode, &txs.Subsidy)
+	case TxCreateOrder:
+		return appendScheduled(decode, &txs.CreateOrder)
+	case TxEditOrder:
+		return appendScheduled(decode, &txs.EditOrder)
+	case TxDeleteOrder:
+		return appendScheduled(decode, &txs.DeleteOrder)
+	case TxLockOrder:
+		return appendScheduled(decode, &txs.LockOrder)
+	case TxCloseOrder:
+		return appendScheduled(decode, &txs.CloseOrder)
+	case TxStartPoll:
+		return appendScheduled(decode, &txs.StartPoll)
+	case TxLimitOrder:
+		return appendScheduled(decode, &txs.DexLimitOrder)
+	case TxDexDeposit:
+		return appendScheduled(decode, &txs.DexDeposit)
+	default:
+		return appendScheduled(decode, &txs.DexWithdraw)
+	}
+}
+
+// appendScheduled is a helper that decodes a schedule row into a new tx appended to txs
+func appendScheduled[T DueAt](decode func(any) error, txs *[]T) error {
+	var tx T
+	if err := decode(&tx); err != nil {
+		return err
+	}
+	*txs = append(*txs, tx)
+	return nil
+}
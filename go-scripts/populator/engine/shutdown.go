@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// shutdown is the graceful shutdown of the run, shared by every tx sent
+var shutdown = newGracefulShutdown()
+
+// gracefulShutdown stops a run on SIGINT or SIGTERM: no new txs are scheduled, and the txs in flight get
+// general.drainTimeout to finish before they are cancelled
+type gracefulShutdown struct {
+	stop      context.Context // cancelled on the first signal
+	stopRun   context.CancelFunc
+	txCtx     context.Context // parent of every tx request, cancelled when the drain times out
+	cancelTxs context.CancelFunc
+	finished  atomic.Int64 // txs that returned after the signal, successfully or not
+	cancelled atomic.Int64 // txs cancelled at the drain timeout
+}
+
+func newGracefulShutdown() *gracefulShutdown {
+	stop, stopRun := context.WithCancel(context.Background())
+	txCtx, cancelTxs := context.WithCancel(context.Background())
+	return &gracefulShutdown{stop: stop, stopRun: stopRun, txCtx: txCtx, cancelTxs: cancelTxs}
+}
+
+// Listen returns a child of ctx cancelled on the first SIGINT or SIGTERM, which stops the block notifiers and
+// so the scheduling of txs. Later signals terminate the process as usual
+func (s *gracefulShutdown) Listen(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			s.stopRun()
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Stopping reports whether a signal was received, so no new txs are scheduled
+func (s *gracefulShutdown) Stopping() bool {
+	return s.stop.Err() != nil
+}
+
+// Done records the result of a tx that returned, counting those that returned after the signal
+func (s *gracefulShutdown) Done(err error) {
+	if !s.Stopping() {
+		return
+	}
+	if err != nil && s.txCtx.Err() != nil {
+		s.cancelled.Add(1)
+		return
+	}
+	s.finished.Add(1)
+}
+
+// Wait waits for wg. When a signal is received first, the txs in flight get up to timeout to finish, then
+// the rest is cancelled and the number of txs finished and cancelled is logged
+func (s *gracefulShutdown) Wait(log *slog.Logger, wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-s.stop.Done():
+	}
+	log.Warn("run interrupted, waiting for the txs in flight", slog.String("drain_timeout", timeout.String()))
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		// the rpc client can't abort a request already sent, those still finish on their request timeout
+		log.Warn("drain timeout reached, cancelling the txs in flight")
+		s.cancelTxs()
+		<-done
+	}
+	log.Warn("run interrupted, results are partial", slog.Int64("finished", s.finished.Load()),
+		slog.Int64("cancelled", s.cancelled.Load()))
+}
+
+// ListenShutdown stops the run on the first SIGINT or SIGTERM, see gracefulShutdown.Listen
+func ListenShutdown(ctx context.Context) (context.Context, context.CancelFunc) {
+	return shutdown.Listen(ctx)
+}
+
+// WaitShutdown waits for wg, draining the txs in flight for up to timeout once the run was interrupted,
+// see gracefulShutdown.Wait
+func WaitShutdown(log *slog.Logger, wg *sync.WaitGroup, timeout time.Duration) {
+	shutdown.Wait(log, wg, timeout)
+}
@@ -0,0 +1,295 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/canopy-network/canopy/fsm"
+	"github.com/canopy-network/canopy/lib"
+)
+
+// snapshotPageSize is the number of validators queried per page when taking a snapshot
+const snapshotPageSize = 1000
+
+// Snapshot is the state of the profile's chains written with -snapshot, to compare the state before and
+// after a run with -diff
+type Snapshot struct {
+	Chains []ChainSnapshot `json:"chains"`
+}
+
+// ChainSnapshot is the state of a chain at a single height
+type ChainSnapshot struct {
+	ChainId    uint64           `json:"chainId"`
+	Height     uint64           `json:"height"`
+	Supply     *fsm.Supply      `json:"supply"`
+	Validators []*fsm.Validator `json:"validators"`
+	OrderBooks *lib.OrderBooks  `json:"orderBooks"`
+}
+
+// TakeSnapshot queries the validators, supply and order books of every chain at its current height
+func TakeSnapshot(chains []*Profile) (*Snapshot, error) {
+	snapshot := new(Snapshot)
+	for _, chain := range chains {
+		chainSnapshot, err := takeChainSnapshot(chain.General)
+		if err != nil {
+			return nil, fmt.Errorf("chain %d: %w", chain.General.ChainId, err)
+		}
+		snapshot.Chains = append(snapshot.Chains, *chainSnapshot)
+	}
+	return snapshot, nil
+}
+
+// takeChainSnapshot queries the state of the chain of config, every query at the same height
+func takeChainSnapshot(config General) (*ChainSnapshot, error) {
+	client := config.Client()
+	height, err := client.Height()
+	if err != nil {
+		return nil, fmt.Errorf("get height: %w", err)
+	}
+	snapshot := &ChainSnapshot{ChainId: config.ChainId, Height: height.Height}
+	if snapshot.Supply, err = client.Supply(height.Height); err != nil {
+		return nil, fmt.Errorf("get supply: %w", err)
+	}
+	// chain id 0 returns the order books of every chain
+	if snapshot.OrderBooks, err = client.Orders(height.Height, 0); err != nil {
+		return nil, fmt.Errorf("get order books: %w", err)
+	}
+	for pageNumber := 1; ; pageNumber++ {
+		page, err := client.Validators(height.Height, lib.PageParams{PageNumber: pageNumber, PerPage: snapshotPageSize},
+			lib.ValidatorFilters{})
+		if err != nil {
+			return nil, fmt.Errorf("get validators page %d: %w", pageNumber, err)
+		}
+		validators, ok := page.Results.(*fsm.ValidatorPage)
+		if !ok {
+			return nil, fmt.Errorf("get validators page %d: unexpected page type %s", pageNumber, page.Type)
+		}
+		snapshot.Validators = append(snapshot.Validators, *validators...)
+		if pageNumber >= page.TotalPages {
+			break
+		}
+	}
+	return snapshot, nil
+}
+
+// WriteSnapshot writes a snapshot as indented JSON
+func WriteSnapshot(path string, snapshot *Snapshot) error {
+	raw, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		return fmt.Errorf("write snapshot %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot written by WriteSnapshot
+func LoadSnapshot(path string) (*Snapshot, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load snapshot %s: %w", path, err)
+	}
+	snapshot := new(Snapshot)
+	if err := json.Unmarshal(raw, snapshot); err != nil {
+		return nil, fmt.Errorf("parse snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// RunDiff logs the changes between the two snapshots at paths
+func RunDiff(log *slog.Logger, paths []string) error {
+	if len(paths) != 2 {
+		return fmt.Errorf("expected the paths of two snapshots, got %d arguments", len(paths))
+	}
+	a, err := LoadSnapshot(paths[0])
+	if err != nil {
+		return err
+	}
+	b, err := LoadSnapshot(paths[1])
+	if err != nil {
+		return err
+	}
+	changes := DiffSnapshots(a, b)
+	for _, change := range changes {
+		log.Info("snapshot change", slog.String("change", change))
+	}
+	log.Info("compared snapshots", slog.String("before", paths[0]), slog.String("after", paths[1]),
+		slog.Int("changes", len(changes)))
+	return nil
+}
+
+// DiffSnapshots returns the changes from snapshot a to b per chain: the height, the supply, the validators
+// staked, unstaked or changed and the orders created, removed or changed
+func DiffSnapshots(a, b *Snapshot) []string {
+	var changes []string
+	before := make(map[uint64]*ChainSnapshot, len(a.Chains))
+	for i := range a.Chains {
+		before[a.Chains[i].ChainId] = &a.Chains[i]
+	}
+	after := make(map[uint64]*ChainSnapshot, len(b.Chains))
+	for i := range b.Chains {
+		after[b.Chains[i].ChainId] = &b.Chains[i]
+	}
+	for _, chain := range a.Chains {
+		if _, ok := after[chain.ChainId]; !ok {
+			changes = append(changes, fmt.Sprintf("chain %d: only in the first snapshot", chain.ChainId))
+		}
+	}
+	for _, chain := range b.Chains {
+		old, ok := before[chain.ChainId]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("chain %d: only in the second snapshot", chain.ChainId))
+			continue
+		}
+		prefix := fmt.Sprintf("chain %d: ", chain.ChainId)
+		for _, change := range diffChain(old, &chain) {
+			changes = append(changes, prefix+change)
+		}
+	}
+	return changes
+}
+
+// diffChain returns the changes between two snapshots of the same chain
+func diffChain(a, b *ChainSnapshot) []string {
+	var changes []string
+	if a.Height != b.Height {
+		changes = append(changes, fmt.Sprintf("height %d -> %d", a.Height, b.Height))
+	}
+	changes = append(changes, diffSupply(a.Supply, b.Supply)...)
+	changes = append(changes, diffValidators(a.Validators, b.Validators)...)
+	changes = append(changes, diffOrders(a.OrderBooks, b.OrderBooks)...)
+	return changes
+}
+
+// diffSupply returns the changes of the supply totals and of the staked amounts per committee
+func diffSupply(a, b *fsm.Supply) []string {
+	if a == nil {
+		a = new(fsm.Supply)
+	}
+	if b == nil {
+		b = new(fsm.Supply)
+	}
+	var changes []string
+	amount := func(name string, x, y uint64) {
+		if x != y {
+			changes = append(changes, fmt.Sprintf("supply %s %d -> %d (%+d)", name, x, y, int64(y-x)))
+		}
+	}
+	amount("total", a.Total, b.Total)
+	amount("staked", a.Staked, b.Staked)
+	amount("delegated only", a.DelegatedOnly, b.DelegatedOnly)
+	pools := func(name string, x, y []*fsm.Pool) {
+		amounts := make(map[uint64][2]uint64)
+		for _, pool := range x {
+			amounts[pool.Id] = [2]uint64{pool.Amount, amounts[pool.Id][1]}
+		}
+		for _, pool := range y {
+			amounts[pool.Id] = [2]uint64{amounts[pool.Id][0], pool.Amount}
+		}
+		ids := make([]uint64, 0, len(amounts))
+		for id := range amounts {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		for _, id := range ids {
+			amount(fmt.Sprintf("%s of committee %d", name, id), amounts[id][0], amounts[id][1])
+		}
+	}
+	pools("committee staked", a.CommitteeStaked, b.CommitteeStaked)
+	pools("committee delegated only", a.CommitteeDelegatedOnly, b.CommitteeDelegatedOnly)
+	return changes
+}
+
+// diffValidators returns the validators staked, unstaked or changed, by address
+func diffValidators(a, b []*fsm.Validator) []string {
+	before := make(map[string]*fsm.Validator, len(a))
+	for _, v := range a {
+		before[hex.EncodeToString(v.Address)] = v
+	}
+	after := make(map[string]*fsm.Validator, len(b))
+	for _, v := range b {
+		after[hex.EncodeToString(v.Address)] = v
+	}
+	var changes []string
+	for address, v := range before {
+		if _, ok := after[address]; !ok {
+			changes = append(changes, fmt.Sprintf("validator %s: removed, had %d staked", address, v.StakedAmount))
+		}
+	}
+	for address, v := range after {
+		old, ok := before[address]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("validator %s: added with %d staked for committees %v",
+				address, v.StakedAmount, v.Committees))
+			continue
+		}
+		if old.StakedAmount != v.StakedAmount {
+			changes = append(changes, fmt.Sprintf("validator %s: staked %d -> %d", address, old.StakedAmount, v.StakedAmount))
+		}
+		if !slices.Equal(old.Committees, v.Committees) {
+			changes = append(changes, fmt.Sprintf("validator %s: committees %v -> %v", address, old.Committees, v.Committees))
+		}
+		if old.UnstakingHeight != v.UnstakingHeight {
+			changes = append(changes, fmt.Sprintf("validator %s: unstaking height %d -> %d", address, old.UnstakingHeight, v.UnstakingHeight))
+		}
+		if old.MaxPausedHeight != v.MaxPausedHeight {
+			changes = append(changes, fmt.Sprintf("validator %s: max paused height %d -> %d", address, old.MaxPausedHeight, v.MaxPausedHeight))
+		}
+		if old.NetAddress != v.NetAddress {
+			changes = append(changes, fmt.Sprintf("validator %s: net address %s -> %s", address, old.NetAddress, v.NetAddress))
+		}
+		if old.Compound != v.Compound {
+			changes = append(changes, fmt.Sprintf("validator %s: compound %t -> %t", address, old.Compound, v.Compound))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// diffOrders returns the sell orders created, removed or changed, by order id
+func diffOrders(a, b *lib.OrderBooks) []string {
+	orders := func(books *lib.OrderBooks) map[string]*lib.SellOrder {
+		m := make(map[string]*lib.SellOrder)
+		if books == nil {
+			return m
+		}
+		for _, book := range books.OrderBooks {
+			for _, order := range book.Orders {
+				m[hex.EncodeToString(order.Id)] = order
+			}
+		}
+		return m
+	}
+	before, after := orders(a), orders(b)
+	var changes []string
+	for id, order := range before {
+		if _, ok := after[id]; !ok {
+			changes = append(changes, fmt.Sprintf("order %s: removed from committee %d, was selling %d",
+				id, order.Committee, order.AmountForSale))
+		}
+	}
+	for id, order := range after {
+		old, ok := before[id]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("order %s: created on committee %d, selling %d for %d",
+				id, order.Committee, order.AmountForSale, order.RequestedAmount))
+			continue
+		}
+		if old.AmountForSale != order.AmountForSale || old.RequestedAmount != order.RequestedAmount {
+			changes = append(changes, fmt.Sprintf("order %s: selling %d for %d -> %d for %d", id,
+				old.AmountForSale, old.RequestedAmount, order.AmountForSale, order.RequestedAmount))
+		}
+		if !bytes.Equal(old.BuyerReceiveAddress, order.BuyerReceiveAddress) {
+			changes = append(changes, fmt.Sprintf("order %s: buyer %x -> %x", id, old.BuyerReceiveAddress, order.BuyerReceiveAddress))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/canopy-network/canopy/cmd/rpc"
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+)
+
+const (
+	defaultSpikeBaselineBlocks = 5  // default blocks before the burst that make the baseline block duration
+	defaultSpikeRecoveryBlocks = 50 // default blocks observed after the burst for the recovery
+	defaultSpikeTolerance      = 20 // default % above the baseline a block duration still counts as recovered
+)
+
+// Spike replaces the per block send txs with a one-shot burst of Count send txs at Height, then only
+// observes the block durations, reporting the blocks it takes them to return to the baseline measured
+// over the BaselineBlocks before the burst. The other handlers keep sending their txs as scheduled
+type Spike struct {
+	Enabled        bool    `yaml:"enabled"`
+	Height         uint64  `yaml:"height"`         // height of the burst, a counter in incremental mode
+	Count          uint    `yaml:"count"`          // send txs of the burst, required
+	BaselineBlocks uint64  `yaml:"baselineBlocks"` // blocks before the burst averaged into the baseline (default: 5)
+	RecoveryBlocks uint64  `yaml:"recoveryBlocks"` // blocks observed after the burst before giving up (default: 50)
+	Tolerance      float64 `yaml:"tolerance"`      // % above the baseline a block duration still counts as recovered (default: 20)
+}
+
+// setDefaults fills the unset spike parameters with their defaults and checks they are in sane ranges
+func (s *Spike) setDefaults() error {
+	if s.BaselineBlocks == 0 {
+		s.BaselineBlocks = defaultSpikeBaselineBlocks
+	}
+	if s.RecoveryBlocks == 0 {
+		s.RecoveryBlocks = defaultSpikeRecoveryBlocks
+	}
+	if s.Tolerance == 0 {
+		s.Tolerance = defaultSpikeTolerance
+	}
+	var errs error
+	if s.Count == 0 {
+		errs = errors.Join(errs, errors.New("spike: count is required"))
+	}
+	if s.Tolerance < 0 {
+		errs = errors.Join(errs, fmt.Errorf("spike: tolerance %.2f must be positive", s.Tolerance))
+	}
+	return errs
+}
+
+// runSpike sends the burst once the spike height is reached, then follows the block durations until
+// they return to the baseline or the recovery window ends
+func runSpike(ctx context.Context, log *slog.Logger, notifier <-chan HeightCh, profile *Profile,
+	accounts []shared.Account, results chan<- TxResult) {
+	spike := profile.Send.Spike
+	client := profile.General.Client()
+	var burstHeight, lastObserved uint64
+	var threshold time.Duration
+	for height := range notifier {
+		if burstHeight == 0 {
+			scheduled := height.Height
+			if profile.General.Incremental {
+				scheduled = height.Counter
+			}
+			if scheduled < spike.Height {
+				continue
+			}
+			baseline, err := baselineBlockDuration(client, height.Height, spike.BaselineBlocks)
+			if err != nil {
+				log.Error("spike baseline failed", slog.Uint64("height", height.Height),
+					slog.String("error", err.Error()))
+				return
+			}
+			threshold = time.Duration(float64(baseline) * (1 + spike.Tolerance/100))
+			// the burst is the configured send with the spike's count
+			burst := *profile
+			burst.Send.batchOptions.Count = spike.Count
+			start := time.Now()
+			hashes, success, errors, err := executeSendTxs(ctx, &burst, accounts, height.Height,
+				profile.Send.Concurrency, log)
+			emitResult(results, TxResult{
+				Kind:    TxSend,
+				Height:  height.Height,
+				Batched: profile.Send.IsBatch(),
+				Hashes:  hashes,
+				Success: success,
+				Errors:  errors,
+				Err:     err,
+				Latency: time.Since(start),
+			})
+			log.Info("sent spike burst",
+				slog.Int("success", success),
+				slog.Int("failure", errors),
+				slog.Uint64("height", height.Height),
+				slog.String("duration", time.Since(start).String()),
+				slog.String("baseline_block_duration", baseline.String()),
+				slog.String("recovered_block_duration", threshold.String()),
+			)
+			burstHeight, lastObserved = height.Height, height.Height
+			continue
+		}
+		// the notifier skips the blocks the handler was busy for, observe every block since the last one
+		for h := lastObserved + 1; h <= height.Height; h++ {
+			duration, err := blockDuration(client, h)
+			if err != nil {
+				log.Error("error getting block", slog.Uint64("height", h), slog.String("error", err.Error()))
+				return
+			}
+			lastObserved = h
+			blocks := h - burstHeight
+			log.Info("spike recovery block", slog.Uint64("height", h), slog.Uint64("blocks", blocks),
+				slog.String("block_duration", duration.String()))
+			if duration <= threshold {
+				log.Info("spike recovered", slog.Uint64("burst_height", burstHeight),
+					slog.Uint64("blocks_to_recover", blocks), slog.String("block_duration", duration.String()),
+					slog.String("recovered_block_duration", threshold.String()))
+				return
+			}
+			if blocks >= spike.RecoveryBlocks {
+				log.Warn("spike did not recover within recoveryBlocks", slog.Uint64("burst_height", burstHeight),
+					slog.Uint64("recovery_blocks", spike.RecoveryBlocks), slog.String("block_duration", duration.String()),
+					slog.String("recovered_block_duration", threshold.String()))
+				return
+			}
+		}
+	}
+}
+
+// baselineBlockDuration returns the average duration of the last blocks blocks up to height, or of the
+// ones since the first block when there are fewer
+func baselineBlockDuration(client *rpc.Client, height, blocks uint64) (time.Duration, error) {
+	last := height
+	first := max(last-min(blocks, last), 1)
+	if first >= last {
+		return 0, fmt.Errorf("no blocks up to height %d to measure the baseline", height)
+	}
+	firstTime, err := blockTime(client, first)
+	if err != nil {
+		return 0, err
+	}
+	lastTime, err := blockTime(client, last)
+	if err != nil {
+		return 0, err
+	}
+	return lastTime.Sub(firstTime) / time.Duration(last-first), nil
+}
+
+// blockDuration returns the time between the block at height and the one before it
+func blockDuration(client *rpc.Client, height uint64) (time.Duration, error) {
+	previous, err := blockTime(client, height-1)
+	if err != nil {
+		return 0, err
+	}
+	current, err := blockTime(client, height)
+	if err != nil {
+		return 0, err
+	}
+	return current.Sub(previous), nil
+}
+
+// blockTime returns the time of the block at height
+func blockTime(client *rpc.Client, height uint64) (time.Time, error) {
+	block, err := client.BlockByHeight(height)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMicro(int64(block.BlockHeader.Time)), nil
+}
@@ -1,4 +1,4 @@
-package main
+package engine
 
 import (
 	"bytes"
@@ -7,7 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
+	"math/rand/v2"
 	"net/http"
 	"strings"
 	"sync"
@@ -40,16 +40,32 @@ const (
 	TxDexWithdraw TxType = "dexWithdraw"
 	TxDexDeposit  TxType = "dexDeposit"
 
-	subsidyRoute = "/v1/admin/tx-subsidy"
+	heartbeatAmount = uint64(1) // minimum amount accepted for a send transaction
 )
 
+// orderIdLength is the hex length of an order id, the first 20 bytes of its create order tx hash
+const orderIdLength = 40
+
+// TxTypes are all the supported transaction types
+var TxTypes = []TxType{TxSend, TxStake, TxEditStake, TxPause, TxUnstake, TxChangeParam, TxDaoTransfer,
+	TxSubsidy, TxCreateOrder, TxEditOrder, TxDeleteOrder, TxLockOrder, TxCloseOrder, TxStartPoll,
+	TxLimitOrder, TxDexWithdraw, TxDexDeposit}
+
+// DefaultTxOrder is the default execution order of the scheduled transaction types due at the same height
+var DefaultTxOrder = []TxType{TxStake, TxEditStake, TxPause, TxUnstake, TxChangeParam, TxDaoTransfer,
+	TxSubsidy, TxCreateOrder, TxEditOrder, TxDeleteOrder, TxLockOrder, TxCloseOrder, TxStartPoll,
+	TxLimitOrder, TxDexDeposit, TxDexWithdraw}
+
 var (
 	ErrAlreadyStaked        = errors.New("validator already staked")
 	ErrNotStaked            = errors.New("validator not staked")
 	ErrInsufficientStake    = errors.New("insufficient stake")
 	ErrNotValidator         = errors.New("not a validator")
+	ErrNotDelegator         = errors.New("not a delegator")
 	ErrInvalidJSON          = errors.New("invalid JSON")
 	ErrInvalidPollEndHeight = errors.New("invalid poll end height")
+	ErrInvalidPercent       = errors.New("percent must be between 1 and 100")
+	ErrOrderNotCreated      = errors.New("order lifecycle: order not created")
 	PrivateKeyRequired      = errors.New("private key required")
 )
 
@@ -81,6 +97,26 @@ type BulkTx interface {
 type DueAt interface {
 	Tx
 	Due(h uint64) bool
+	// Schedule returns the height and dependency options of the transaction
+	Schedule() heightBatch
+}
+
+// MsgTx is the interface to represent a transaction whose message can be built locally
+type MsgTx interface {
+	Tx
+	// Msg builds the transaction message for the given request
+	Msg(req *TxRequest) (proto.Message, error)
+}
+
+// CyclingBulkTx is a bulk transaction whose messages cycle through its committees, e.g. dex txs spreading
+// a batch over several pools
+type CyclingBulkTx interface {
+	BulkTx
+	// Msgs builds count messages, the i-th one for committee i % len(committees)
+	Msgs(req *TxRequest, count uint) ([]proto.Message, error)
+	// WithOffset returns the tx with its committees rotated by offset, so a batch starting at message
+	// offset continues the cycle of the batches before it
+	WithOffset(offset uint) BulkTx
 }
 
 // Kind implementations
@@ -102,9 +138,28 @@ func (DexLimitOrderTx) Kind() TxType { return TxLimitOrder }
 func (DexWithdrawTx) Kind() TxType   { return TxDexWithdraw }
 func (DexDepositTx) Kind() TxType    { return TxDexDeposit }
 
+// SendTx builds the send-to-self transaction for the heartbeat
+func (h HeartbeatTx) SendTx() *SendTx {
+	amount := h.Amount
+	if amount == 0 {
+		amount = heartbeatAmount
+	}
+	tx := &SendTx{}
+	tx.From, tx.To = h.From, h.From
+	tx.Amount = amount
+	tx.UsePrivateKey = h.UsePrivateKey
+	return tx
+}
+
 // Due returns true if the height is due
 func (s heightBatch) Due(h uint64) bool { return s.Height == h }
 
+// Schedule returns the scheduling options
+func (s heightBatch) Schedule() heightBatch { return s }
+
+// scheduling returns the scheduling options for modification
+func (s *heightBatch) scheduling() *heightBatch { return s }
+
 // Due implementations
 func (tx StakeTx) Due(h uint64) bool         { return tx.heightBatch.Due(h) }
 func (tx EditStakeTx) Due(h uint64) bool     { return tx.heightBatch.Due(h) }
@@ -192,7 +247,7 @@ func (tx CloseOrderTx) Validate(ctx context.Context, req *TxRequest) error  { re
 
 // Validate ensures that the sender is not already staked
 func (tx StakeTx) Validate(ctx context.Context, req *TxRequest) error {
-	staked, _, err := isStaked(req.FromAddr.String())
+	staked, _, err := isStaked(req.Client, req.FromAddr.String())
 	if err != nil {
 		return err
 	}
@@ -202,19 +257,22 @@ func (tx StakeTx) Validate(ctx context.Context, req *TxRequest) error {
 	return nil
 }
 
-// Validate ensures that the sender is already staked and the new stake is higher than the
-// current stake
+// Validate ensures that the sender is already staked with its role, if set, and the new stake is
+// higher than the current stake
 func (tx EditStakeTx) Validate(ctx context.Context, req *TxRequest) error {
 	// validate that is staked
-	staked, _, err := isStaked(req.FromAddr.String())
+	staked, delegator, err := isStaked(req.Client, req.FromAddr.String())
 	if err != nil {
 		return err
 	}
 	if !staked {
 		return ErrNotStaked
 	}
+	if err := tx.check(delegator); err != nil {
+		return err
+	}
 	// confirm new stake is higher than the current stake
-	val, err := cnpyClient.Validator(0, req.FromAddr.String())
+	val, err := req.Client.Validator(0, req.FromAddr.String())
 	if err != nil {
 		return err
 	}
@@ -224,21 +282,21 @@ func (tx EditStakeTx) Validate(ctx context.Context, req *TxRequest) error {
 	return nil
 }
 
-// Validate ensures that the sender is staked
+// Validate ensures that the sender is staked, with its role if set
 func (tx UnstakeTx) Validate(ctx context.Context, req *TxRequest) error {
-	staked, _, err := isStaked(req.FromAddr.String())
+	staked, delegator, err := isStaked(req.Client, req.FromAddr.String())
 	if err != nil {
 		return err
 	}
 	if !staked {
 		return ErrNotStaked
 	}
-	return nil
+	return tx.check(delegator)
 }
 
 // Validate ensures that the sender is stake and not a delegator
 func (tx PauseTx) Validate(ctx context.Context, req *TxRequest) error {
-	staked, delegator, err := isStaked(req.FromAddr.String())
+	staked, delegator, err := isStaked(req.Client, req.FromAddr.String())
 	if err != nil {
 		return err
 	}
@@ -264,26 +322,48 @@ func (tx StartPollTx) Validate(ctx context.Context, req *TxRequest) error {
 }
 
 func (tx DexLimitOrderTx) Validate(ctx context.Context, req *TxRequest) error {
-	if len(tx.Committees) != 1 {
-		return fmt.Errorf("only exactly one committee is required")
-	}
-	return nil
+	return validateDexCommittees(tx.Batch, tx.Committees)
 }
 
+// Validate ensures there's a single committee, or at least one in batch mode, and the percent to withdraw
+// is within bounds, as 0 is a no-op
 func (tx DexWithdrawTx) Validate(ctx context.Context, req *TxRequest) error {
-	if len(tx.Committees) != 1 {
-		return fmt.Errorf("only exactly one committee is required")
+	if err := validateDexCommittees(tx.Batch, tx.Committees); err != nil {
+		return err
+	}
+	if tx.Percent < 1 || tx.Percent > 100 {
+		return fmt.Errorf("%w [percent: %d]", ErrInvalidPercent, tx.Percent)
 	}
 	return nil
 }
 
 func (tx DexDepositTx) Validate(ctx context.Context, req *TxRequest) error {
-	if len(tx.Committees) != 1 {
+	return validateDexCommittees(tx.Batch, tx.Committees)
+}
+
+// validateDexCommittees ensures a dex tx targets a single committee, or at least one in batch mode where
+// the messages of a batch cycle through them
+func validateDexCommittees(batch bool, committees []uint64) error {
+	if batch {
+		if len(committees) == 0 {
+			return fmt.Errorf("at least one committee is required")
+		}
+		return nil
+	}
+	if len(committees) != 1 {
 		return fmt.Errorf("only exactly one committee is required")
 	}
 	return nil
 }
 
+// validateTx runs the tx's pre-send checks, unless its skipValidate option sends it unchecked
+func validateTx(ctx context.Context, tx DueAt, req *TxRequest) error {
+	if tx.Schedule().SkipValidate {
+		return nil
+	}
+	return tx.Validate(ctx, req)
+}
+
 // Do implementation
 
 // Do sends a send transaction
@@ -291,18 +371,14 @@ func (tx SendTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string
 	var hash *string
 	var err error
 	if tx.UsePrivateKey {
-		sendMsg := &fsm.MessageSend{
-			FromAddress: req.FromAddr.Bytes(),
-			ToAddress:   req.ToAddr.Bytes(),
-			Amount:      tx.Amount,
-		}
+		sendMsg, _ := tx.Msg(req) // send messages always build
 		hash, err = SendRawTx(ctx, req, sendMsg)
 		if err != nil {
 			return "", err
 		}
 	} else {
 		from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-		hash, _, err = cnpyClient.TxSend(from, req.ToAddr.String(), tx.Amount, req.Password, true, req.Fee)
+		hash, _, err = req.Client.TxSend(from, req.ToAddr.String(), tx.Amount, req.Password, true, req.Fee)
 	}
 	return *hash, err
 }
@@ -311,11 +387,11 @@ func (tx SendTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string
 
 // Do sends a stake transaction
 func (tx StakeTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
-	if err := tx.Validate(ctx, req); err != nil {
+	if err := validateTx(ctx, tx, req); err != nil {
 		return "", fmt.Errorf("stake: [%s] %w", req.From, err)
 	}
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxStake(from,
+	hash, _, err := req.Client.TxStake(from,
 		tx.NetAddr,
 		tx.Amount,
 		tx.committees.String(),
@@ -334,12 +410,12 @@ func (tx StakeTx) Do(ctx context.Context, req *TxRequest, baseURL string) (strin
 
 // Do sends an edit stake transaction
 func (tx EditStakeTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
-	if err := tx.Validate(ctx, req); err != nil {
+	if err := validateTx(ctx, tx, req); err != nil {
 		return "", fmt.Errorf("edit stake: [%s] %w", req.FromAddr, err)
 	}
 	// send transaction
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxEditStake(from,
+	hash, _, err := req.Client.TxEditStake(from,
 		tx.NetAddr,
 		tx.Amount,
 		tx.committees.String(),
@@ -358,28 +434,28 @@ func (tx EditStakeTx) Do(ctx context.Context, req *TxRequest, baseURL string) (s
 
 // Do sends a pause transaction
 func (tx PauseTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
-	if err := tx.Validate(ctx, req); err != nil {
+	if err := validateTx(ctx, tx, req); err != nil {
 		return "", fmt.Errorf("pause: [%s] %w", req.From, err)
 	}
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxPause(from, from, req.Password, true, req.Fee)
+	hash, _, err := req.Client.TxPause(from, from, req.Password, true, req.Fee)
 	return *hash, err
 }
 
 // Do sends an unstake transaction
 func (tx UnstakeTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
-	if err := tx.Validate(ctx, req); err != nil {
+	if err := validateTx(ctx, tx, req); err != nil {
 		return "", fmt.Errorf("unstake: [%s] %w", req.FromAddr, err)
 	}
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxUnstake(from, from, req.Password, true, req.Fee)
+	hash, _, err := req.Client.TxUnstake(from, from, req.Password, true, req.Fee)
 	return *hash, err
 }
 
 // Do sends a change parameter transaction
 func (tx ChangeParamTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxChangeParam(
+	hash, _, err := req.Client.TxChangeParam(
 		from,
 		tx.ParamSpace,
 		tx.ParamKey,
@@ -395,7 +471,7 @@ func (tx ChangeParamTx) Do(ctx context.Context, req *TxRequest, baseURL string)
 // Do sends a DAO transfer transaction
 func (tx DaoTransferTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxDaoTransfer(
+	hash, _, err := req.Client.TxDaoTransfer(
 		from,
 		tx.Amount,
 		tx.StartBlock,
@@ -408,7 +484,7 @@ func (tx DaoTransferTx) Do(ctx context.Context, req *TxRequest, baseURL string)
 
 // Do sends a subsidy transaction
 func (tx SubsidyTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
-	return postTx(ctx, baseURL+subsidyRoute, txRequest{
+	return postTx(ctx, baseURL+req.AdminRoutes.Subsidy, txRequest{
 		Address:    req.FromAddr.String(),
 		Amount:     tx.Amount,
 		Committees: tx.committees.String(),
@@ -421,7 +497,7 @@ func (tx SubsidyTx) Do(ctx context.Context, req *TxRequest, baseURL string) (str
 // CreateOrderTx sends a create order transaction
 func (tx CreateOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxCreateOrder(
+	hash, _, err := req.Client.TxCreateOrder(
 		from,
 		tx.SellAmount,
 		tx.ReceiveAmount,
@@ -431,13 +507,19 @@ func (tx CreateOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string)
 		lib.HexBytes(tx.Data),
 		true,
 		req.Fee)
-	return *hash, err
+	if err != nil {
+		return "", err
+	}
+	if tx.lifecycle != nil {
+		tx.lifecycle.setOrderId(*hash)
+	}
+	return *hash, nil
 }
 
 // EditOrderTx sends an edit order transaction
 func (tx EditOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxEditOrder(
+	hash, _, err := req.Client.TxEditOrder(
 		from,
 		tx.SellAmount,
 		tx.ReceiveAmount,
@@ -453,7 +535,7 @@ func (tx EditOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (s
 // DeleteOrderTx sends a delete order transaction
 func (tx DeleteOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxDeleteOrder(
+	hash, _, err := req.Client.TxDeleteOrder(
 		from,
 		tx.OrderId,
 		tx.ChainId,
@@ -466,10 +548,14 @@ func (tx DeleteOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string)
 // LockOrderTx sends a lock order transaction
 func (tx LockOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxLockOrder(
+	orderId, err := tx.orderId()
+	if err != nil {
+		return "", fmt.Errorf("lock order: %w", err)
+	}
+	hash, _, err := req.Client.TxLockOrder(
 		from,
 		req.ToAddr.String(),
-		tx.OrderId,
+		orderId,
 		req.Password,
 		true,
 		req.Fee)
@@ -479,22 +565,52 @@ func (tx LockOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (s
 // CloseOrderTx sends a close order transaction
 func (tx CloseOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxCloseOrder(
+	orderId, err := tx.orderId()
+	if err != nil {
+		return "", fmt.Errorf("close order: %w", err)
+	}
+	hash, _, err := req.Client.TxCloseOrder(
 		from,
-		tx.OrderId,
+		orderId,
 		req.Password,
 		true,
 		req.Fee)
 	return *hash, err
 }
 
+// orderLifecycle holds the id of the order created by an order lifecycle
+type orderLifecycle struct {
+	mu      sync.Mutex
+	orderId string
+}
+
+// setOrderId records the id of the created order, the first 20 bytes of the create order tx hash
+func (l *orderLifecycle) setOrderId(txHash string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.orderId = txHash[:min(len(txHash), orderIdLength)]
+}
+
+// orderId returns the configured order id, or the id of the order created by the order's lifecycle
+func (o order) orderId() (string, error) {
+	if o.lifecycle == nil {
+		return o.OrderId, nil
+	}
+	o.lifecycle.mu.Lock()
+	defer o.lifecycle.mu.Unlock()
+	if o.lifecycle.orderId == "" {
+		return "", ErrOrderNotCreated
+	}
+	return o.lifecycle.orderId, nil
+}
+
 // Do StartPollTx sends a start poll transaction
 func (tx StartPollTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
-	if err := tx.Validate(ctx, req); err != nil {
+	if err := validateTx(ctx, tx, req); err != nil {
 		return "", err
 	}
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxStartPoll(
+	hash, _, err := req.Client.TxStartPoll(
 		from,
 		json.RawMessage(tx.PollJSON),
 		req.Password,
@@ -505,8 +621,11 @@ func (tx StartPollTx) Do(ctx context.Context, req *TxRequest, baseURL string) (s
 
 // Do LimitOrderTx sends a limit order transaction
 func (tx DexLimitOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
+	if err := validateTx(ctx, tx, req); err != nil {
+		return "", fmt.Errorf("limit order: [%s] %w", req.FromAddr, err)
+	}
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxDexLimitOrder(
+	hash, _, err := req.Client.TxDexLimitOrder(
 		from,
 		tx.SellAmount,
 		tx.ReceiveAmount,
@@ -519,8 +638,11 @@ func (tx DexLimitOrderTx) Do(ctx context.Context, req *TxRequest, baseURL string
 
 // Do DexWithdrawTx sends a dex withdraw transaction
 func (tx DexWithdrawTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
+	if err := validateTx(ctx, tx, req); err != nil {
+		return "", fmt.Errorf("dex withdraw: [%s] %w", req.FromAddr, err)
+	}
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxDexLiquidityWithdraw(
+	hash, _, err := req.Client.TxDexLiquidityWithdraw(
 		from,
 		tx.Percent,
 		tx.Committees[0],
@@ -531,8 +653,11 @@ func (tx DexWithdrawTx) Do(ctx context.Context, req *TxRequest, baseURL string)
 }
 
 func (tx DexDepositTx) Do(ctx context.Context, req *TxRequest, baseURL string) (string, error) {
+	if err := validateTx(ctx, tx, req); err != nil {
+		return "", fmt.Errorf("dex deposit: [%s] %w", req.FromAddr, err)
+	}
 	from := rpc.AddrOrNickname{Address: req.FromAddr.String()}
-	hash, _, err := cnpyClient.TxDexLiquidityDeposit(
+	hash, _, err := req.Client.TxDexLiquidityDeposit(
 		from,
 		tx.Amount,
 		tx.Committees[0],
@@ -560,53 +685,206 @@ func (tx SendTx) DoBulk(ctx context.Context, req *TxRequest, baseURL string) ([]
 	if !tx.UsePrivateKey {
 		return []string{}, PrivateKeyRequired
 	}
-	return doBulk(ctx, req, tx.Count(), &fsm.MessageSend{
-		FromAddress: req.FromAddr.Bytes(),
-		ToAddress:   req.ToAddr.Bytes(),
-		Amount:      tx.Amount,
-	})
+	msg, err := tx.Msg(req)
+	if err != nil {
+		return nil, err
+	}
+	return doBulk(ctx, req, tx.Count(), msg)
 }
 
 func (tx DexLimitOrderTx) DoBulk(ctx context.Context, req *TxRequest, baseURL string) ([]string, error) {
 	if !tx.UsePrivateKey {
 		return []string{}, PrivateKeyRequired
 	}
-	return doBulk(ctx, req, req.Count, &fsm.MessageDexLimitOrder{
-		ChainId:         uint64(tx.Committees[0]),
-		AmountForSale:   tx.SellAmount,
-		RequestedAmount: tx.ReceiveAmount,
-		Address:         req.FromAddr.Bytes(),
-	})
+	msgs, err := tx.Msgs(req, req.Count)
+	if err != nil {
+		return nil, err
+	}
+	return sendBulk(ctx, req, msgs)
 }
 
 func (tx DexDepositTx) DoBulk(ctx context.Context, req *TxRequest, baseURL string) ([]string, error) {
 	if !tx.UsePrivateKey {
 		return []string{}, PrivateKeyRequired
 	}
-	return doBulk(ctx, req, req.Count, &fsm.MessageDexLiquidityDeposit{
-		ChainId: uint64(tx.Committees[0]),
-		Amount:  tx.Amount,
-		Address: req.FromAddr.Bytes(),
-	})
+	msgs, err := tx.Msgs(req, req.Count)
+	if err != nil {
+		return nil, err
+	}
+	return sendBulk(ctx, req, msgs)
 }
 
 func (tx DexWithdrawTx) DoBulk(ctx context.Context, req *TxRequest, baseURL string) ([]string, error) {
 	if !tx.UsePrivateKey {
 		return []string{}, PrivateKeyRequired
 	}
-	return doBulk(ctx, req, req.Count, &fsm.MessageDexLiquidityWithdraw{
-		ChainId: uint64(tx.Committees[0]),
+	msgs, err := tx.Msgs(req, req.Count)
+	if err != nil {
+		return nil, err
+	}
+	return sendBulk(ctx, req, msgs)
+}
+
+// Msgs implementations
+
+func (tx DexLimitOrderTx) Msgs(req *TxRequest, count uint) ([]proto.Message, error) {
+	if err := tx.Validate(context.Background(), req); err != nil {
+		return nil, fmt.Errorf("limit order: [%s] %w", req.FromAddr, err)
+	}
+	return cycleCommittees(count, tx.Committees, func(committee uint64) proto.Message {
+		return tx.msg(req, committee)
+	}), nil
+}
+
+func (tx DexDepositTx) Msgs(req *TxRequest, count uint) ([]proto.Message, error) {
+	if err := tx.Validate(context.Background(), req); err != nil {
+		return nil, fmt.Errorf("dex deposit: [%s] %w", req.FromAddr, err)
+	}
+	return cycleCommittees(count, tx.Committees, func(committee uint64) proto.Message {
+		return tx.msg(req, committee)
+	}), nil
+}
+
+func (tx DexWithdrawTx) Msgs(req *TxRequest, count uint) ([]proto.Message, error) {
+	if err := tx.Validate(context.Background(), req); err != nil {
+		return nil, fmt.Errorf("dex withdraw: [%s] %w", req.FromAddr, err)
+	}
+	return cycleCommittees(count, tx.Committees, func(committee uint64) proto.Message {
+		return tx.msg(req, committee)
+	}), nil
+}
+
+// cycleCommittees builds count messages, the i-th one for committee i % len(committees)
+func cycleCommittees(count uint, committees []uint64, msg func(committee uint64) proto.Message) []proto.Message {
+	msgs := make([]proto.Message, 0, count)
+	for i := range count {
+		msgs = append(msgs, msg(committees[i%uint(len(committees))]))
+	}
+	return msgs
+}
+
+// WithOffset implementations
+
+func (tx DexLimitOrderTx) WithOffset(offset uint) BulkTx {
+	tx.order.committees = tx.order.committees.rotated(offset)
+	return tx
+}
+
+func (tx DexDepositTx) WithOffset(offset uint) BulkTx {
+	tx.committees = tx.committees.rotated(offset)
+	return tx
+}
+
+func (tx DexWithdrawTx) WithOffset(offset uint) BulkTx {
+	tx.committees = tx.committees.rotated(offset)
+	return tx
+}
+
+// Msg implementations
+
+func (tx SendTx) Msg(req *TxRequest) (proto.Message, error) {
+	return &fsm.MessageSend{
+		FromAddress: req.FromAddr.Bytes(),
+		ToAddress:   req.ToAddr.Bytes(),
+		Amount:      tx.Amount,
+	}, nil
+}
+
+func (tx StakeTx) Msg(req *TxRequest) (proto.Message, error) {
+	pub, err := crypto.NewPublicKeyFromString(req.From.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("stake: [%s] public key: %w", req.FromAddr, err)
+	}
+	return &fsm.MessageStake{
+		PublicKey:     pub.Bytes(),
+		Amount:        tx.Amount,
+		Committees:    tx.Committees,
+		NetAddress:    tx.NetAddr,
+		OutputAddress: req.ToAddr.Bytes(),
+		Delegate:      tx.Delegate,
+		Compound:      !tx.EarlyWithdrawal,
+	}, nil
+}
+
+func (tx EditStakeTx) Msg(req *TxRequest) (proto.Message, error) {
+	return &fsm.MessageEditStake{
+		Address:       req.FromAddr.Bytes(),
+		Amount:        tx.Amount,
+		Committees:    tx.Committees,
+		NetAddress:    tx.NetAddr,
+		OutputAddress: req.ToAddr.Bytes(),
+		Compound:      !tx.EarlyWithdrawal,
+	}, nil
+}
+
+func (tx PauseTx) Msg(req *TxRequest) (proto.Message, error) {
+	return &fsm.MessagePause{Address: req.FromAddr.Bytes()}, nil
+}
+
+func (tx UnstakeTx) Msg(req *TxRequest) (proto.Message, error) {
+	return &fsm.MessageUnstake{Address: req.FromAddr.Bytes()}, nil
+}
+
+func (tx DexLimitOrderTx) Msg(req *TxRequest) (proto.Message, error) {
+	if err := tx.Validate(context.Background(), req); err != nil {
+		return nil, fmt.Errorf("limit order: [%s] %w", req.FromAddr, err)
+	}
+	return tx.msg(req, tx.Committees[0]), nil
+}
+
+// msg builds the limit order message for committee
+func (tx DexLimitOrderTx) msg(req *TxRequest, committee uint64) proto.Message {
+	return &fsm.MessageDexLimitOrder{
+		ChainId:         committee,
+		AmountForSale:   tx.SellAmount,
+		RequestedAmount: tx.ReceiveAmount,
+		Address:         req.FromAddr.Bytes(),
+	}
+}
+
+func (tx DexDepositTx) Msg(req *TxRequest) (proto.Message, error) {
+	if err := tx.Validate(context.Background(), req); err != nil {
+		return nil, fmt.Errorf("dex deposit: [%s] %w", req.FromAddr, err)
+	}
+	return tx.msg(req, tx.Committees[0]), nil
+}
+
+// msg builds the liquidity deposit message for committee
+func (tx DexDepositTx) msg(req *TxRequest, committee uint64) proto.Message {
+	return &fsm.MessageDexLiquidityDeposit{
+		ChainId: committee,
+		Amount:  tx.Amount,
+		Address: req.FromAddr.Bytes(),
+	}
+}
+
+func (tx DexWithdrawTx) Msg(req *TxRequest) (proto.Message, error) {
+	if err := tx.Validate(context.Background(), req); err != nil {
+		return nil, fmt.Errorf("dex withdraw: [%s] %w", req.FromAddr, err)
+	}
+	return tx.msg(req, tx.Committees[0]), nil
+}
+
+// msg builds the liquidity withdraw message for committee
+func (tx DexWithdrawTx) msg(req *TxRequest, committee uint64) proto.Message {
+	return &fsm.MessageDexLiquidityWithdraw{
+		ChainId: committee,
 		Percent: uint64(tx.Percent),
 		Address: req.FromAddr.Bytes(),
-	})
+	}
 }
 
-// doBulk sends multiple transactions built by the provided message builder
+// doBulk sends count transactions of the same message
 func doBulk(ctx context.Context, req *TxRequest, count uint, msg proto.Message) ([]string, error) {
 	msgs := make([]proto.Message, 0, count)
 	for range count {
 		msgs = append(msgs, msg)
 	}
+	return sendBulk(ctx, req, msgs)
+}
+
+// sendBulk sends a transaction per message
+func sendBulk(ctx context.Context, req *TxRequest, msgs []proto.Message) ([]string, error) {
 	hashes, err := SendRawTxs(ctx, req, msgs)
 	if err != nil {
 		return nil, err
@@ -630,13 +908,21 @@ func BuildTxRequest(from, to shared.Account, config General, height uint64, coun
 	if err != nil {
 		return nil, fmt.Errorf("create TO address: %w", err)
 	}
-	fee := baseFee
-	if config.Fee != 0 {
+	// chain-specific fees override the profile fee
+	fee := config.BaseFee
+	if chainFee, ok := config.ChainFees[config.ChainId]; ok && chainFee != 0 {
+		fee = chainFee
+	} else if config.Fee != 0 {
 		fee = config.Fee
 	}
+	// account-level passwords override the profile default
+	password := from.Password
+	if password == "" {
+		password = config.DefaultPassword
+	}
 	req := TxRequest{
 		Fee:       fee,
-		Password:  from.Password,
+		Password:  password,
 		From:      from,
 		FromAddr:  fromAddr,
 		ToAddr:    toAddr,
@@ -645,6 +931,9 @@ func BuildTxRequest(from, to shared.Account, config General, height uint64, coun
 		ChainId:   config.ChainId,
 		NetworkId: config.NetworkId,
 		Count:     count,
+		// admin routes of the txs posted without the canopy client
+		AdminRoutes: config.AdminRoutes,
+		Client:      config.Client(),
 	}
 	return &req, nil
 }
@@ -665,8 +954,12 @@ func SendRawTxs(ctx context.Context, req *TxRequest, msgs []proto.Message) ([]*s
 	if err != nil {
 		return nil, err
 	}
-	// send the transaction to the node
-	hashes, err := cnpyClient.Transactions(txs)
+	return SubmitTransactions(req.Client, txs)
+}
+
+// SubmitTransactions sends signed transactions to the node
+func SubmitTransactions(client *rpc.Client, txs []lib.TransactionI) ([]*string, error) {
+	hashes, err := client.Transactions(txs)
 	if err != nil {
 		return nil, fmt.Errorf("raw: send tx: %w", err)
 	}
@@ -677,6 +970,12 @@ func SendRawTxs(ctx context.Context, req *TxRequest, msgs []proto.Message) ([]*s
 func BuildTransactions(req *TxRequest, msgs []proto.Message) ([]lib.TransactionI, error) {
 	wg, txErr := sync.WaitGroup{}, error(nil)
 	transactions := make([]lib.TransactionI, len(msgs))
+	// generate the memos upfront from an unshared source, keeping the goroutines off any shared lock
+	r := newRand()
+	memos := make([]string, len(msgs))
+	for i := range memos {
+		memos[i] = randomCharacters(r, 20)
+	}
 	// iterate over the messages
 	for i, msg := range msgs {
 		wg.Add(1)
@@ -703,7 +1002,7 @@ func BuildTransactions(req *TxRequest, msgs []proto.Message) ([]lib.TransactionI
 				Time:          uint64(time.Now().UnixMicro()),
 				Fee:           req.Fee,
 				// prevent duplicate transactions on burst transactions
-				Memo:      randomCharacters(20),
+				Memo:      memos[idx],
 				NetworkId: req.ChainId,
 				ChainId:   req.NetworkId,
 			}
@@ -730,12 +1029,12 @@ func BuildTransactions(req *TxRequest, msgs []proto.Message) ([]lib.TransactionI
 }
 
 // randomCharacters generates a random hex string
-func randomCharacters(maxLength int) string {
+func randomCharacters(r *rand.Rand, maxLength int) string {
 	const chars = "0123456789abcdefghijklmnopqrstuvwxyz"
-	length := 1 + rand.Intn(maxLength) // 1-maxLength characters
+	length := 1 + r.IntN(maxLength) // 1-maxLength characters
 	b := make([]byte, length)
 	for i := range b {
-		b[i] = chars[rand.Intn(len(chars))]
+		b[i] = chars[r.IntN(len(chars))]
 	}
 	return string(b)
 }
@@ -794,6 +1093,10 @@ type TxRequest struct {
 	ChainId   uint64          // Chain ID of the transaction
 	NetworkId uint64          // Network ID of the transaction
 	Count     uint            // Number of transactions to send for batch transaction
+	// AdminRoutes are the admin RPC paths of the txs posted without the canopy client
+	AdminRoutes AdminRoutes
+	// Client is the canopy client of the chain the transaction is sent to
+	Client *rpc.Client
 }
 
 // txRequest represents a full transaction request
@@ -832,11 +1135,11 @@ type txRequest struct {
 
 // network utils
 
-func isStaked(address string) (staked, delegator bool, err error) {
+func isStaked(client *rpc.Client, address string) (staked, delegator bool, err error) {
 	if address == "" {
 		return false, false, errors.New("address is empty")
 	}
-	validator, err := cnpyClient.Validator(0, address)
+	validator, err := client.Validator(0, address)
 	if err != nil {
 		// client error handling is broken, need to handle errors by looking at the error message string
 		if strings.Contains(err.Error(), "validator does not exist") {
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/canopy-network/canopy/lib"
+	"github.com/canopy-network/canopy/lib/crypto"
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+	"google.golang.org/protobuf/proto"
+)
+
+// verifyHeight is the created height used for offline transactions, which must be non-zero
+const verifyHeight = uint64(1)
+
+// ErrNotBuiltLocally is returned for transactions whose message is built by the node
+var ErrNotBuiltLocally = errors.New("message is built by the node")
+
+// VerifyProfile builds and signs every configured transaction offline, without a running node,
+// returning the number of transactions that failed to build, sign or verify
+func VerifyProfile(log *slog.Logger, profile *Profile, accounts []shared.Account) (failed int) {
+	for _, tx := range allTxs(profile) {
+		attrs := []any{
+			slog.String("kind", string(tx.Kind())),
+			slog.Int("from", tx.Sender()),
+			slog.Int("to", tx.Receiver()),
+		}
+		err := verifyTx(tx, profile.General, accounts)
+		switch {
+		case errors.Is(err, ErrNotBuiltLocally):
+			log.Warn("skipped tx verification", append(attrs, slog.String("reason", err.Error()))...)
+		case err != nil:
+			failed++
+			log.Error("tx failed verification", append(attrs, slog.String("error", err.Error()))...)
+		default:
+			log.Info("tx verified", attrs...)
+		}
+	}
+	return failed
+}
+
+// verifyTx builds the tx request and transaction for tx, signs it with the sender's private key,
+// and checks the result serializes and its signature verifies
+func verifyTx(tx Tx, config General, accounts []shared.Account) error {
+	if tx.Sender() < 0 || tx.Sender() >= len(accounts) || tx.Receiver() < 0 || tx.Receiver() >= len(accounts) {
+		return fmt.Errorf("account index out of range, accounts: %d", len(accounts))
+	}
+	req, err := BuildTxRequest(accounts[tx.Sender()], accounts[tx.Receiver()], config, verifyHeight, 1)
+	if err != nil {
+		return fmt.Errorf("build tx request: %w", err)
+	}
+	msgTx, ok := tx.(MsgTx)
+	if !ok {
+		return ErrNotBuiltLocally
+	}
+	msg, err := msgTx.Msg(req)
+	if err != nil {
+		return fmt.Errorf("build msg: %w", err)
+	}
+	if m, ok := msg.(lib.MessageI); ok {
+		if err := m.Check(); err != nil {
+			return fmt.Errorf("check msg: %w", err)
+		}
+	}
+	txs, err := BuildTransactions(req, []proto.Message{msg})
+	if err != nil {
+		return err
+	}
+	return verifyTransaction(txs[0], req)
+}
+
+// verifyTransaction checks a signed transaction round-trips through its proto encoding and was
+// signed by the request's sender
+func verifyTransaction(txI lib.TransactionI, req *TxRequest) error {
+	tx, ok := txI.(*lib.Transaction)
+	if !ok {
+		return fmt.Errorf("unexpected transaction type %T", txI)
+	}
+	if err := tx.CheckBasic(); err != nil {
+		return fmt.Errorf("check tx: %w", err)
+	}
+	bz, err := lib.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("marshal tx: %w", err)
+	}
+	decoded := new(lib.Transaction)
+	if err := lib.Unmarshal(bz, decoded); err != nil {
+		return fmt.Errorf("unmarshal tx: %w", err)
+	}
+	pub, pubErr := crypto.NewPublicKeyFromBytes(decoded.Signature.PublicKey)
+	if pubErr != nil {
+		return fmt.Errorf("signature public key: %w", pubErr)
+	}
+	if !bytes.Equal(pub.Address().Bytes(), req.FromAddr.Bytes()) {
+		return fmt.Errorf("private key belongs to %s, not sender %s", pub.Address(), req.FromAddr)
+	}
+	signBytes, err := decoded.GetSignBytes()
+	if err != nil {
+		return fmt.Errorf("sign bytes: %w", err)
+	}
+	if !pub.VerifyBytes(signBytes, decoded.Signature.Signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// allTxs returns every transaction configured in the profile, regardless of height
+func allTxs(p *Profile) []Tx {
+	var out []Tx
+	if p.Send.Count() > 0 {
+		out = append(out, &p.Send)
+	}
+	if p.Heartbeat.Enabled {
+		out = append(out, p.Heartbeat.SendTx())
+	}
+	out = append(out, asTxs(p.Warmup.Stake)...)
+	out = append(out, asTxs(p.DexSetup.Deposit)...)
+	out = append(out, asTxs(p.Transactions.Stake)...)
+	out = append(out, asTxs(p.Transactions.EditStake)...)
+	out = append(out, asTxs(p.Transactions.Pause)...)
+	out = append(out, asTxs(p.Transactions.Unstake)...)
+	out = append(out, asTxs(p.Transactions.ChangeParam)...)
+	out = append(out, asTxs(p.Transactions.DaoTransfer)...)
+	out = append(out, asTxs(p.Transactions.Subsidy)...)
+	out = append(out, asTxs(p.Transactions.CreateOrder)...)
+	out = append(out, asTxs(p.Transactions.EditOrder)...)
+	out = append(out, asTxs(p.Transactions.DeleteOrder)...)
+	out = append(out, asTxs(p.Transactions.LockOrder)...)
+	out = append(out, asTxs(p.Transactions.CloseOrder)...)
+	out = append(out, asTxs(p.Transactions.StartPoll)...)
+	out = append(out, asTxs(p.Transactions.DexLimitOrder)...)
+	out = append(out, asTxs(p.Transactions.DexDeposit)...)
+	out = append(out, asTxs(p.Transactions.DexWithdraw)...)
+	return out
+}
+
+// asTxs is a helper that converts a slice of concrete transactions to a slice of Tx
+func asTxs[T Tx](items []T) []Tx {
+	out := make([]Tx, 0, len(items))
+	for _, v := range items {
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/canopy-network/canopy/lib/crypto"
+	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
+)
+
+// RunWarmup funds the accounts from the profile's funding source, waiting until the funds are included,
+// then stakes the profile's warmup accounts and waits until all of them are in the validator set, so the
+// height-driven txs start against funded accounts and an already staked set. Accounts that are already
+// staked are only confirmed. Waiting for the confirmations stops once ctx is done
+func RunWarmup(ctx context.Context, log *slog.Logger, profile *Profile, accounts []shared.Account) error {
+	if profile.Warmup.Fund != nil {
+		if err := fundAccounts(ctx, log, profile, accounts); err != nil {
+			return err
+		}
+	}
+	if len(profile.Warmup.Stake) == 0 {
+		return nil
+	}
+	var errs error
+	addresses := make([]string, 0, len(profile.Warmup.Stake))
+	for i, tx := range profile.Warmup.Stake {
+		address := accounts[tx.Sender()].Address
+		hashes, err := sendTx(tx, accounts[tx.Sender()], accounts[tx.Receiver()], profile.General, 0, false, 0)
+		switch {
+		case errors.Is(err, ErrAlreadyStaked):
+			log.Info("warmup: already staked", slog.String("address", address))
+		case err != nil:
+			errs = errors.Join(errs, fmt.Errorf("warmup stake %d: %w", i, err))
+			continue
+		default:
+			log.Info("warmup: staked", slog.String("address", address), slog.String("hash", hashes[0]))
+		}
+		addresses = append(addresses, address)
+	}
+	if errs != nil {
+		return errs
+	}
+	confirmation := StakeConfirmation{
+		Enabled:    true,
+		TimeoutMs:  profile.Warmup.TimeoutMs,
+		IntervalMs: profile.Warmup.IntervalMs,
+	}
+	if missing := ConfirmStakes(ctx, log, profile.General.Client(), confirmation, addresses); len(missing) > 0 {
+		return fmt.Errorf("warmup: %d of %d stakes not registered in the validator set: %v",
+			len(missing), len(addresses), missing)
+	}
+	log.Info("warmup: stakes confirmed in the validator set", slog.Int("staked", len(addresses)))
+	return nil
+}
+
+// fundAccounts sends the warmup fund amount from the funding source to every other account, signed with
+// the source's private key, and waits until every send is included in a block
+func fundAccounts(ctx context.Context, log *slog.Logger, profile *Profile, accounts []shared.Account) error {
+	fund := profile.Warmup.Fund
+	send := SendTx{amount: amount{Amount: fund.Amount}, batchOptions: batchOptions{UsePrivateKey: true}}
+	var errs error
+	hashes := make([]string, 0, len(accounts))
+	for _, to := range accounts {
+		if to.Address == fund.account.Address {
+			continue
+		}
+		sent, err := sendTx(send, fund.account, to, profile.General, 0, false, 0)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("warmup fund %s: %w", to.Address, err))
+			continue
+		}
+		hashes = append(hashes, sent[0])
+	}
+	if errs != nil {
+		return errs
+	}
+	if missing := ConfirmTxs(ctx, log, profile.General.Client(), profile.Warmup.TimeoutMs, profile.Warmup.IntervalMs, hashes); len(missing) > 0 {
+		return fmt.Errorf("warmup: %d of %d fund sends not included in a block: %v", len(missing), len(hashes), missing)
+	}
+	log.Info("warmup: accounts funded", slog.String("source", fund.Source),
+		slog.String("address", fund.account.Address), slog.Int("funded", len(hashes)), slog.Uint64("amount", fund.Amount))
+	return nil
+}
+
+// loadFundingSource returns the account of the nickname from the keystore.json at keystorePath, decrypted
+// with password, or when keystorePath is empty from the main accounts and node keys of the comma-separated
+// accounts files
+func loadFundingSource(nickname, accountsPaths, keystorePath, password string) (shared.Account, error) {
+	if keystorePath != "" {
+		raw, err := os.ReadFile(keystorePath)
+		if err != nil {
+			return shared.Account{}, fmt.Errorf("load keystore %s: %w", keystorePath, err)
+		}
+		keystore := new(crypto.Keystore)
+		if err := json.Unmarshal(raw, keystore); err != nil {
+			return shared.Account{}, fmt.Errorf("parse keystore %s: %w", keystorePath, err)
+		}
+		address, ok := keystore.NicknameMap[nickname]
+		if !ok {
+			return shared.Account{}, fmt.Errorf("source %s not found in keystore %s", nickname, keystorePath)
+		}
+		addressBytes, err := crypto.NewAddressFromString(address)
+		if err != nil {
+			return shared.Account{}, fmt.Errorf("source %s address: %w", nickname, err)
+		}
+		key, err := keystore.GetKey(addressBytes.Bytes(), password)
+		if err != nil {
+			return shared.Account{}, fmt.Errorf("decrypt source %s: %w", nickname, err)
+		}
+		return shared.Account{
+			Address:    key.PublicKey().Address().String(),
+			PublicKey:  key.PublicKey().String(),
+			PrivateKey: key.String(),
+		}, nil
+	}
+	for _, path := range strings.Split(accountsPaths, ",") {
+		path = filepath.Clean(strings.TrimSpace(path))
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return shared.Account{}, fmt.Errorf("load accounts %s: %w", path, err)
+		}
+		var ids struct {
+			Accounts map[string]shared.Account `json:"main-accounts"`
+			Keys     map[string]shared.Account `json:"keys"`
+		}
+		if err := json.Unmarshal(raw, &ids); err != nil {
+			return shared.Account{}, fmt.Errorf("parse accounts: %s: %w", path, err)
+		}
+		if account, ok := ids.Accounts[nickname]; ok {
+			return account, nil
+		}
+		if account, ok := ids.Keys[nickname]; ok {
+			return account, nil
+		}
+	}
+	return shared.Account{}, fmt.Errorf("source %s not found in the accounts files", nickname)
+}
@@ -1,36 +1,29 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
 	"errors"
 	"flag"
-	"fmt"
 	"log/slog"
 	"os"
-	"path/filepath"
-	"sort"
 	"sync"
-	"sync/atomic"
 	"time"
 
-	"github.com/canopy-network/k8s-node-tester/go-scripts/shared"
-	"golang.org/x/sync/semaphore"
-	"gopkg.in/yaml.v3"
+	"github.com/canopy-network/k8s-node-tester/go-scripts/populator/engine"
 )
 
 var (
 	path          = flag.String("path", "../config.yml", "Path to the configuration file")
 	profileConfig = flag.String("profile", "default", "Profile to use from the configuration file")
-	accounts      = flag.String("accounts", "", "path to the accounts file")
-)
-
-const (
-	baseFee = uint64(10_000) // base fee for transactions
-	// TODO: should this be configurable?
-	retries            = 5                      // number of retries for failed requests
-	timeout            = 5 * time.Second        // timeout for each request
-	blockCheckInterval = 500 * time.Millisecond // interval to check for new blocks
+	accounts      = flag.String("accounts", "", "path to the accounts file, comma-separated paths are merged")
+	verify        = flag.Bool("verify", false, "Build and sign every configured tx offline and exit")
+	drain         = flag.Bool("drain", false, "Unstake every staked account and exit")
+	report        = flag.String("report", "", "Path to write the JSON confirmation latency report to, requires general.confirmTxs")
+	build         = flag.String("build", "", "Build and sign the scheduled txs offline into a bundle at this path and exit")
+	buildHeight   = flag.Uint64("buildHeight", 0, "Chain height the -submit run of a -build bundle starts at, required to sign the txs of incremental profiles")
+	submit        = flag.String("submit", "", "Submit the signed txs of a -build bundle at their scheduled heights and exit")
+	strict        = flag.Bool("strict", false, "Fail instead of warning on scheduled txs due after general.maxHeight")
+	snapshot      = flag.String("snapshot", "", "Write a JSON snapshot of the validators, supply and order books of every chain to this path and exit")
+	diff          = flag.Bool("diff", false, "Report the changes between the two -snapshot files given as arguments and exit")
 )
 
 func main() {
@@ -48,296 +41,190 @@ func main() {
 		},
 	}))
 	log.Debug("starting populator")
+	// compare two snapshots without loading the config
+	if *diff {
+		if err := engine.RunDiff(log, flag.Args()); err != nil {
+			log.Error("failed to diff snapshots", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
 	// load the accounts and config
-	profile, accounts, err := LoadConfigs(*path, *profileConfig, *accounts)
+	profile, accounts, err := engine.LoadConfigs(*path, *profileConfig, *accounts)
 	if err != nil {
 		log.Error("failed to load configs", "error", err)
 		os.Exit(1)
 	}
-	// set the client urls
-	SetCanopyClient(profile.General.RpcURL, profile.General.AdminRpcURL)
-	// setup the block notifier
-	notifier := BlockNotifier(log, profile.General, timeout, blockCheckInterval, retries)
-	// fan-out: listen for new blocks to broadcast
-	b := NewBroadcaster(notifier, 2)
-	// start the tx handlers
-	wg := sync.WaitGroup{}
-	wg.Go(func() {
-		HandleSendTxs(log, b.Channels()[0], profile, accounts)
-	})
-	wg.Go(func() {
-		HandleTxs(log, b.Channels()[1], profile, accounts)
-	})
-	wg.Wait()
-	log.Info("finished running populator")
-}
-
-// HandleSendTxs handles the sending of bulk `send` transactions per block
-func HandleSendTxs(log *slog.Logger, notifier <-chan HeightCh, profile *Profile, accounts []shared.Account) {
-	if profile.Send.Count() == 0 {
-		return
+	profile.DefaultCommittees(log)
+	// warn about the txs the notifier stops before
+	if unreachable := profile.WarnUnreachableTxs(log); unreachable > 0 && *strict {
+		log.Error("unreachable scheduled txs", slog.Int("count", unreachable))
+		os.Exit(1)
 	}
-	lastBlockTime := time.Now()
-	for height := range notifier {
-		start := time.Now()
-		// execute the transactions
-		success, errors, _ := executeSendTxs(profile, accounts, height.Height, log)
-		duration := time.Since(start)
-		// get block
-		block, err := cnpyClient.BlockByHeight(0)
-		if err != nil {
-			log.Error("error getting block", slog.Uint64("height", height.Height),
-				slog.String("error", err.Error()))
-			continue
+	log.Info("random seed", slog.Uint64("seed", engine.SetSeed(profile.General.Seed)))
+	// verify the profile's txs without a running node
+	if *verify {
+		if failed := engine.VerifyProfile(log, profile, accounts); failed > 0 {
+			log.Error("profile verification failed", slog.Int("failed", failed))
+			os.Exit(1)
 		}
-		// calculate block duration
-		blockTime := time.UnixMicro(int64(block.BlockHeader.Time))
-		lastBlockDuration := blockTime.Sub(lastBlockTime)
-		lastBlockTime = blockTime
-		// log data
-		log.Info("finished sending SEND txs",
-			slog.Int("success", success),
-			slog.Int("failure", errors),
-			slog.Uint64("count", uint64(profile.Send.Count())),
-			slog.Uint64("height", height.Height),
-			slog.String("duration", duration.String()),
-			slog.Uint64("last_block_txs", block.BlockHeader.NumTxs),
-			slog.String("last_block_duration", lastBlockDuration.String()),
-		)
+		log.Info("profile verification succeeded")
+		return
 	}
-}
-
-// HandleTxs handles the sending of most transactions per defined block
-func HandleTxs(log *slog.Logger, notifier <-chan HeightCh, profile *Profile, accounts []shared.Account) {
-	for heightInfo := range notifier {
-		height := heightInfo.Height
-		if profile.General.Incremental {
-			height = heightInfo.Counter
+	// sign the scheduled txs offline for a later -submit
+	if *build != "" {
+		if profile.General.Incremental && *buildHeight == 0 {
+			log.Error("-build of an incremental profile requires -buildHeight, the txs are signed with chain heights")
+			os.Exit(1)
 		}
-		for _, tx := range GatherAtHeight(profile, height) {
-			txLog := log.With(slog.String("type", string(tx.Kind())),
-				slog.Uint64("height", height), slog.Bool("batched", tx.IsBatch()),
-				slog.String("address", accounts[tx.Sender()].Address))
-			txLog.Info("sending transaction")
-			success, errors, err := executeTx(tx, profile, accounts, heightInfo.Height)
-			txLog.Info("transaction sent", slog.Int("success", success), slog.Int("errors", errors),
-				slog.Any("error", err))
+		bundle, failed := engine.BuildBundle(log, profile, accounts, *buildHeight)
+		if failed > 0 {
+			log.Error("failed to build bundle", slog.Int("failed", failed))
+			os.Exit(1)
 		}
-	}
-}
-
-// executeTx sends a single transaction (batch or non-batch) and logs the result
-func executeTx(tx Tx, profile *Profile, accounts []shared.Account, height uint64) (
-	success, errors int, err error) {
-	if tx.IsBatch() {
-		return doExecuteBulkTxs(tx, profile, accounts, height)
-	} else {
-		_, err = sendTx(tx, accounts[tx.Sender()], accounts[tx.Receiver()],
-			profile.General, height, false, 0)
-		if err == nil {
-			success++
-		} else {
-			errors++
+		if err := engine.WriteBundle(*build, bundle); err != nil {
+			log.Error("failed to write bundle", slog.String("error", err.Error()))
+			os.Exit(1)
 		}
-		return success, errors, err
-	}
-}
-
-// LoadConfigs loads the configuration and accounts from the given paths
-func LoadConfigs(configPath, profile string, accountsPath string) (*Profile, []shared.Account, error) {
-	// retrieve the accounts
-	path := filepath.Clean(accountsPath)
-	rawAccounts, err := os.ReadFile(path)
-	if err != nil {
-		return nil, nil, fmt.Errorf("load accounts %s: %w", path, err)
-	}
-	var accountsMap struct {
-		Accounts map[string]shared.Account `json:"main-accounts"`
-	}
-	if err := json.Unmarshal(rawAccounts, &accountsMap); err != nil {
-		return nil, nil, fmt.Errorf("parse accounts: %s: %w", path, err)
-	}
-	accounts := make([]shared.Account, 0, len(accountsMap.Accounts))
-	for _, account := range accountsMap.Accounts {
-		accounts = append(accounts, account)
-	}
-	// sort the accounts lexicographically for deterministic order
-	sort.Slice(accounts, func(i, j int) bool {
-		return accounts[i].Address < accounts[j].Address
-	})
-	// retrieve the populator config
-	path = filepath.Clean(configPath)
-	rawConfig, err := os.ReadFile(path)
-	if err != nil {
-		return nil, nil, fmt.Errorf("load config %s: %w", path, err)
-	}
-	var profiles map[string]Profile
-	if err := yaml.Unmarshal(rawConfig, &profiles); err != nil {
-		return nil, nil, fmt.Errorf("parse config %s: %w", path, err)
+		log.Info("wrote bundle", slog.String("path", *build), slog.Int("entries", len(bundle.Entries)))
+		return
 	}
-	pf, ok := profiles[profile]
-	if !ok {
-		return nil, nil, fmt.Errorf("profile %s not found", profile)
+	// set the client urls, every client sharing a single transport tuned by general.transport. The canopy
+	// clients only send through http.DefaultTransport, so the tuned transport replaces it process-wide
+	tuned := profile.General.Transport.New()
+	engine.SetDefaultTransport(tuned)
+	engine.SetCanopyClient(profile.General.RpcURL, profile.General.AdminRpcURL, tuned)
+	// bound the run by its wall-clock deadline
+	ctx, cancel := engine.RunContext(profile.General)
+	defer cancel()
+	// stop scheduling txs on SIGINT or SIGTERM, letting the txs in flight drain
+	ctx, stop := engine.ListenShutdown(ctx)
+	defer stop()
+	// unstake all the validators to reset the network
+	if *drain {
+		unstaked, failed := engine.Drain(log, profile, accounts)
+		log.Info("finished draining validators", slog.Int("unstaked", unstaked), slog.Int("failed", failed))
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
 	}
-	// validate the profile configuration
-	if err := pf.Validate(); err != nil {
-		return nil, nil, fmt.Errorf("validate profile %s: %w", profile, err)
+	// submit a bundle of pre-signed txs instead of building them on the fly
+	if *submit != "" {
+		bundle, err := engine.LoadBundle(*submit)
+		if err != nil {
+			log.Error("failed to load bundle", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		if bundle.ChainId != profile.General.ChainId || bundle.NetworkId != profile.General.NetworkId {
+			log.Error("bundle was built for another chain",
+				slog.Uint64("bundle_chain_id", bundle.ChainId), slog.Uint64("chain_id", profile.General.ChainId),
+				slog.Uint64("bundle_network_id", bundle.NetworkId), slog.Uint64("network_id", profile.General.NetworkId))
+			os.Exit(1)
+		}
+		notifier, notifierErr := engine.BlockNotifier(ctx, log, profile.General,
+			time.Duration(profile.General.TimeoutMs)*time.Millisecond,
+			time.Duration(profile.General.BlockCheckIntervalMs)*time.Millisecond,
+			profile.General.Retries)
+		submitted, failed := engine.SubmitBundle(log, profile.General.Client(), notifier, bundle, profile.General.Incremental)
+		engine.LogDeadline(ctx, log, profile.General)
+		log.Info("finished submitting bundle", slog.Int("submitted", submitted), slog.Int("failed", failed))
+		if failed > 0 || notifierErr() != nil {
+			os.Exit(1)
+		}
+		return
 	}
-	// validate there's the minimun number of accounts enforced by the config
-	min := max(2, pf.General.Accounts)
-	if len(accounts) < min {
-		return nil, nil, fmt.Errorf("not enough accounts, min: %d, actual: %d",
-			min, len(accounts))
+	// the profile runs on its chain and every chain of chainRpcs, each against its own RPC and height
+	chains := []*engine.Profile{profile}
+	for _, chain := range profile.General.ChainRPCs {
+		chains = append(chains, profile.ForChain(chain))
 	}
-	return &pf, accounts, nil
-}
-
-// GatherAtHeight returns all scheduled transactions due at height
-// SendPlan is excluded (handled separately).
-func GatherAtHeight(p *Profile, height uint64) []Tx {
-	var out []Tx
-	out = append(out, filterDue(p.Transactions.Stake, height)...)
-	out = append(out, filterDue(p.Transactions.EditStake, height)...)
-	out = append(out, filterDue(p.Transactions.Pause, height)...)
-	out = append(out, filterDue(p.Transactions.Unstake, height)...)
-	out = append(out, filterDue(p.Transactions.ChangeParam, height)...)
-	out = append(out, filterDue(p.Transactions.DaoTransfer, height)...)
-	out = append(out, filterDue(p.Transactions.Subsidy, height)...)
-	out = append(out, filterDue(p.Transactions.CreateOrder, height)...)
-	out = append(out, filterDue(p.Transactions.EditOrder, height)...)
-	out = append(out, filterDue(p.Transactions.DeleteOrder, height)...)
-	out = append(out, filterDue(p.Transactions.LockOrder, height)...)
-	out = append(out, filterDue(p.Transactions.CloseOrder, height)...)
-	out = append(out, filterDue(p.Transactions.StartPoll, height)...)
-	out = append(out, filterDue(p.Transactions.DexLimitOrder, height)...)
-	out = append(out, filterDue(p.Transactions.DexDeposit, height)...)
-	out = append(out, filterDue(p.Transactions.DexWithdraw, height)...)
-	return out
-}
-
-// filterDue is a helper that filters a slice of DueAt items by height
-func filterDue[T DueAt](items []T, height uint64) []Tx {
-	var out []Tx
-	for _, v := range items {
-		if v.Due(height) {
-			out = append(out, v)
+	// record the state of the chains to compare it with -diff
+	if *snapshot != "" {
+		s, err := engine.TakeSnapshot(chains)
+		if err != nil {
+			log.Error("failed to take snapshot", slog.String("error", err.Error()))
+			os.Exit(1)
 		}
+		if err := engine.WriteSnapshot(*snapshot, s); err != nil {
+			log.Error("failed to write snapshot", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		for _, chain := range s.Chains {
+			log.Info("wrote snapshot", slog.String("path", *snapshot), slog.Uint64("chain_id", chain.ChainId),
+				slog.Uint64("height", chain.Height), slog.Int("validators", len(chain.Validators)))
+		}
+		return
 	}
-	return out
-}
-
-// RunConcurrentTxs runs concurrent tx for a total of count.
-// The do function should perform the work for a single idempotent job.
-func RunConcurrentTxs(ctx context.Context, count, concurrency uint,
-	do func() (string, error), log *slog.Logger) (int, int, error) {
-	if concurrency == 0 {
-		concurrency = 1
-	}
-	// create a semaphore to limit concurrency
-	sem := semaphore.NewWeighted(int64(concurrency))
-	var wg sync.WaitGroup
-	var successes atomic.Int32
-	var errors atomic.Int32
-	// run the tx N times
-	var err error
-	for range count {
-		if err := sem.Acquire(ctx, 1); err != nil {
-			// typically only fails if ctx is canceled
-			if errors.Add(1) == 1 {
-				log.Error("semaphore acquire failed", slog.String("error", err.Error()))
+	// check the networks are ready to be loaded
+	if profile.General.Preflight.Enabled {
+		for _, chain := range chains {
+			if err := engine.RunPreflight(ctx, log, chain.General); err != nil {
+				log.Error("network not ready, preflight failed", slog.Uint64("chain_id", chain.General.ChainId),
+					slog.String("error", err.Error()))
+				os.Exit(1)
 			}
-			break
 		}
-		wg.Add(1)
-		// only save the last error
-		go func() {
-			defer sem.Release(1)
-			defer wg.Done()
-
-			if _, txErr := do(); txErr != nil {
-				err = txErr
-				errors.Add(1)
-				return
-			}
-			successes.Add(1)
-		}()
 	}
-	// wait for all txs to complete
-	wg.Wait()
-	return int(successes.Load()), int(errors.Load()), err
-}
-
-// executeSendTxs runs the send transactions for a given height
-func executeSendTxs(config *Profile, accounts []shared.Account, height uint64,
-	log *slog.Logger) (success, errors int, errs error) {
-	if config.Send.IsBatch() {
-		return doExecuteBulkTxs(&config.Send, config, accounts, height)
+	// fund and stake the warmup accounts before any height-driven tx
+	if profile.Warmup.Fund != nil || len(profile.Warmup.Stake) > 0 {
+		if err := engine.RunWarmup(ctx, log, profile, accounts); err != nil {
+			log.Error("warmup failed", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
 	}
-	send := func() (string, error) {
-		hashes, err := sendTx(&config.Send,
-			accounts[0], accounts[1], config.General, uint64(height), false, 0)
-		if err != nil {
-			return "", err
+	// seed the dex liquidity before any height-driven tx
+	if len(profile.DexSetup.Deposit) > 0 {
+		if err := engine.RunDexSetup(ctx, log, profile, accounts); err != nil {
+			log.Error("dex setup failed", slog.String("error", err.Error()))
+			os.Exit(1)
 		}
-		return hashes[0], nil
 	}
-	return RunConcurrentTxs(context.Background(),
-		config.Send.Count(), config.Send.Concurrency, send, log)
-}
-
-// doExecuteBulkTxs sends bulk transactions in parallel batches
-func doExecuteBulkTxs(tx Tx, config *Profile, accounts []shared.Account,
-	height uint64) (success, errs int, err error) {
-	bulkTx, ok := tx.(BulkTx)
-	if !ok {
-		return 0, 1, errors.New("tx does not support bulk transactions")
+	// track the inclusion of the scheduled txs of every chain
+	var confirmations *engine.Confirmations
+	if *report != "" && !profile.General.ConfirmTxs {
+		log.Warn("latency report disabled, it requires general.confirmTxs")
 	}
-
-	total := bulkTx.Count()
-	batchSize := max(bulkTx.BatchSize(), 1)
-	numBatches := (total + batchSize - 1) / batchSize
-
-	var wg sync.WaitGroup
-	var successCount, errorCount atomic.Int32
-
-	for i := range numBatches {
-		toSend := min(batchSize, total-i*batchSize)
-		wg.Add(1)
-		go func(count uint) {
-			defer wg.Done()
-			_, txErr := sendTx(bulkTx, accounts[tx.Sender()],
-				accounts[tx.Receiver()], config.General, height, true, count)
-			if txErr != nil {
-				err = txErr
-				errorCount.Add(int32(count))
-				return
-			}
-			successCount.Add(int32(count))
-		}(toSend)
+	if profile.General.ConfirmTxs {
+		confirmations = engine.NewConfirmations(log, profile.General.ConfirmConcurrency)
+		if profile.General.MetricsAddress != "" {
+			confirmations.Serve(log, profile.General.MetricsAddress)
+		}
 	}
-	wg.Wait()
-	return int(successCount.Load()), int(errorCount.Load()), err
-}
-
-// sendTx is an util to build and send a transaction
-func sendTx(tx Tx, from, to shared.Account, config General, height uint64,
-	bulk bool, count uint) ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	req, err := BuildTxRequest(from, to, config, height, count)
-	if err != nil {
-		return nil, fmt.Errorf("build tx request: %w", err)
+	// run the handlers of every chain at once, each driven by its own chain's height
+	stats := make([]engine.RunStats, len(chains))
+	errs := make([]error, len(chains))
+	var chainsWg sync.WaitGroup
+	for i, chain := range chains {
+		chainLog := log
+		if len(chains) > 1 {
+			chainLog = log.With(slog.Uint64("chain_id", chain.General.ChainId))
+		}
+		chainsWg.Go(func() {
+			stats[i], errs[i] = engine.RunChain(ctx, chainLog, chain, accounts, confirmations, nil)
+		})
+	}
+	engine.WaitShutdown(log, &chainsWg, time.Duration(profile.General.DrainTimeoutMs)*time.Millisecond)
+	engine.LogDeadline(ctx, log, profile.General)
+	total := engine.RunStats{}
+	for i, chain := range chains {
+		if len(chains) > 1 {
+			stats[i].Log(log, "chain summary", slog.Uint64("chain_id", chain.General.ChainId))
+		}
+		total.Merge(stats[i])
 	}
-	if bulk {
-		bulkTx, ok := tx.(BulkTx)
-		if !ok {
-			return nil, fmt.Errorf("tx [%T] does not implement BulkTx", tx)
+	total.Log(log, "run summary", slog.Int("chains", len(chains)))
+	if confirmations != nil && *report != "" {
+		if err := engine.WriteLatencyReport(*report, confirmations); err != nil {
+			log.Error("failed to write latency report", slog.String("error", err.Error()))
+			os.Exit(1)
 		}
-		return bulkTx.DoBulk(ctx, req, config.AdminRpcURL)
+		log.Info("wrote latency report", slog.String("path", *report))
 	}
-	hash, err := tx.Do(ctx, req, config.AdminRpcURL)
-	if err != nil {
-		return nil, err
+	// a chain whose notifier stopped early fails the run, once the other chains and the reports finished
+	if err := errors.Join(errs...); err != nil {
+		log.Error("finished running populator with errors", slog.String("error", err.Error()))
+		os.Exit(1)
 	}
-	return []string{hash}, nil
+	log.Info("finished running populator")
 }